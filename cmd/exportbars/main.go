@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/jwtly10/tradebook/internal/cache"
+	"github.com/jwtly10/tradebook/internal/data"
+	"github.com/jwtly10/tradebook/internal/oanda"
+	"github.com/jwtly10/tradebook/internal/types"
+)
+
+// exportbars dumps Oanda bars to CSV, eg:
+//
+//	go run ./cmd/exportbars -instrument NAS100_USD -granularity M15 -from 2025-10-01 -to 2025-10-24 -out nas100_m15.csv
+func main() {
+	instrument := flag.String("instrument", string(oanda.NAS100), "Oanda instrument name")
+	granularity := flag.String("granularity", string(types.M15), "Bar granularity, eg M1, M15, H1")
+	price := flag.String("price", string(types.MID), "Price component, any combination of M, B and A")
+	fromFlag := flag.String("from", "", "Start date (YYYY-MM-DD or RFC3339)")
+	toFlag := flag.String("to", "", "End date (YYYY-MM-DD or RFC3339), defaults to now")
+	out := flag.String("out", "", "Output CSV file, defaults to <instrument>_<granularity>.csv")
+	noCache := flag.Bool("no-cache", false, "Always fetch from Oanda rather than the local bar cache")
+	flag.Parse()
+
+	from, err := parseDate(*fromFlag)
+	if err != nil {
+		slog.Error("Invalid -from date", "error", err)
+		os.Exit(1)
+	}
+	to := time.Now()
+	if *toFlag != "" {
+		to, err = parseDate(*toFlag)
+		if err != nil {
+			slog.Error("Invalid -to date", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	accountId := os.Getenv("OANDA_ACCOUNT_ID")
+	apiKey := os.Getenv("OANDA_API_KEY")
+	if accountId == "" || apiKey == "" {
+		slog.Error("OANDA_ACCOUNT_ID and OANDA_API_KEY must be set")
+		os.Exit(1)
+	}
+
+	var source data.DataSource = oanda.NewSource(oanda.NewOandaService(accountId, apiKey, ""))
+	if !*noCache {
+		cacheDir := os.Getenv("TRADEBOOK_CACHE_DIR")
+		if cacheDir == "" {
+			cacheDir = ".cache/bars"
+		}
+		source = cache.NewCache(cacheDir, source)
+	}
+
+	req := data.Request{
+		Instrument:  *instrument,
+		Granularity: types.Granularity(*granularity),
+		Price:       types.PriceComponent(*price),
+		From:        from,
+		To:          to,
+	}
+
+	bars, err := source.FetchBars(context.Background(), req)
+	if err != nil {
+		slog.Error("Failed to fetch bars", "error", err)
+		os.Exit(1)
+	}
+
+	path := *out
+	if path == "" {
+		path = req.Instrument + "_" + string(req.Granularity) + ".csv"
+	}
+
+	if err := data.WriteCSVFile(path, bars); err != nil {
+		slog.Error("Failed to write bars", "error", err)
+		os.Exit(1)
+	}
+
+	slog.Info("Exported bars", "count", len(bars), "path", path)
+}
+
+func parseDate(value string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
@@ -7,35 +7,55 @@ import (
 	"os"
 	"time"
 
+	"github.com/jwtly10/tradebook/internal/account"
 	"github.com/jwtly10/tradebook/internal/backtest"
+	"github.com/jwtly10/tradebook/internal/cache"
+	"github.com/jwtly10/tradebook/internal/data"
+	"github.com/jwtly10/tradebook/internal/instrument"
 	"github.com/jwtly10/tradebook/internal/oanda"
 	"github.com/jwtly10/tradebook/internal/strategy"
+	"github.com/jwtly10/tradebook/internal/types"
 )
 
 func main() {
+	// OFFLINE=1 runs purely from previously cached bars
+	offline := os.Getenv("OFFLINE") == "1"
+
 	accountId := os.Getenv("OANDA_ACCOUNT_ID")
-	if accountId == "" {
+	if accountId == "" && !offline {
 		slog.Error("OANDA_ACCOUNT_ID not set")
 		return
 	}
 
 	apiKey := os.Getenv("OANDA_API_KEY")
-	if apiKey == "" {
+	if apiKey == "" && !offline {
 		slog.Error("OANDA_API_KEY not set")
 	}
 	client := oanda.NewOandaService(accountId, apiKey, "")
 
+	cacheDir := os.Getenv("TRADEBOOK_CACHE_DIR")
+	if cacheDir == "" {
+		cacheDir = ".cache/bars"
+	}
+	barCache := cache.NewCache(cacheDir, oanda.NewSource(client))
+	barCache.Offline = offline
+
+	// Any data.DataSource can feed the engine, the cached Oanda source is used by default
+	var source data.DataSource = barCache
+
 	from := time.Date(2025, 10, 23, 0, 0, 0, 0, time.UTC)
 	to := time.Date(2025, 10, 24, 0, 0, 0, 0, time.UTC)
 
-	req := oanda.CandleRequest{
-		Instrument:  oanda.NAS100,
-		Granularity: oanda.M15,
-		From:        from,
-		To:          to,
+	req := data.Request{
+		Instrument:  string(oanda.NAS100),
+		Granularity: types.M15,
+		// Bid and ask prices let fills and exits happen on the correct side of the spread
+		Price: types.BID_ASK,
+		From:  from,
+		To:    to,
 	}
 
-	bars, err := client.FetchBars(
+	bars, err := source.FetchBars(
 		context.Background(),
 		req,
 	)
@@ -46,10 +66,56 @@ func main() {
 
 	slog.Info("Loaded bars", "count", len(bars))
 
-	strat := strategy.NewDJATRStrategy(string(req.Instrument), string(req.Granularity), strategy.DefaultDJATRParams())
+	// Instrument metadata comes from the account, falling back to the bundled file when offline
+	registry := instrument.Default()
+	if !offline {
+		if err := registry.Load(context.Background(), source); err != nil {
+			slog.Warn("Failed to load instruments, using bundled metadata", "error", err)
+		}
+	}
+
+	// PnL is converted into the account currency with rates from the same source as the bars
+	currency := os.Getenv("ACCOUNT_CURRENCY")
+	if currency == "" {
+		currency = "GBP"
+	}
+	rates := data.NewRateTable()
+	meta, err := registry.Get(req.Instrument)
+	if err != nil {
+		slog.Error("Failed to look up instrument", "error", err)
+		return
+	}
+	if meta.QuoteCurrency != currency {
+		rateReq := req
+		rateReq.Instrument = currency + "_" + meta.QuoteCurrency
+		if err := rates.Load(context.Background(), source, rateReq); err != nil {
+			slog.Error("Failed to load conversion rates", "error", err)
+			return
+		}
+	}
+
+	calendar, err := data.CalendarFor(req.Instrument)
+	if err != nil {
+		slog.Error("Failed to build trading calendar", "error", err)
+		return
+	}
+
+	strat := strategy.NewDJATRStrategy(req.Instrument, string(req.Granularity), strategy.DefaultDJATRParams())
 
 	engine := backtest.NewEngine(bars, 10000)
-	results := engine.Run(strat)
+	engine.Granularity = req.Granularity
+	engine.Instrument = req.Instrument
+	engine.Margin = &account.Margin{Rates: registry.MarginRates(), CloseoutLevel: 0.5}
+	engine.Conversion = &account.Conversion{Home: currency, QuoteCurrencies: registry.QuoteCurrencies(), Rates: rates}
+	engine.Instruments = registry.Map()
+	engine.Validator = data.NewValidator(req.Granularity, calendar)
+	engine.StrictData = os.Getenv("STRICT_DATA") == "1"
+
+	results, err := engine.Run(strat)
+	if err != nil {
+		slog.Error("Failed to run backtest", "error", err)
+		return
+	}
 
 	stats := results.Calculate()
 	stats.Print()
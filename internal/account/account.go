@@ -16,23 +16,57 @@ const (
 type Direction string
 
 type Account struct {
-	Balance        float64
+	Balance float64
+	// Resolver decides the exit for bars that touch both SL and TP, defaults to PessimisticResolver
+	Resolver ExitResolver
+	// CostModel prices spread, commission and slippage on each fill, nil means trading is free
+	CostModel CostModel
+	// Margin rejects positions the account can't afford and closes out positions when equity runs low, nil disables margin
+	Margin *Margin
+	// Financing accrues interest on positions held over the daily rollover, nil means holding is free
+	Financing *Financing
+	// Conversion turns quote currency PnL into the home currency Balance is held in, nil treats every instrument as quoted in it
+	Conversion *Conversion
+	// Instruments round units and prices to what the broker accepts and reject signals that break their limits,
+	// signals for instruments missing are traded as given
+	Instruments map[string]types.Instrument
+
 	openPositions  []*Position
+	pendingOrders  []*Order
 	nextPositionID int
+	nextOrderID    int
+	rejections     []OrderRejectedError
 }
 
 type Position struct {
 	ID         int
+	Instrument string
 	OpenTime   time.Time
 	Direction  Direction
 	EntryPrice float64
 	Size       float64
 	StopLoss   float64
 	TakeProfit float64
+	EntryCosts Costs
+	Financing  float64 // Accrued over rollovers so far, negative is a charge
+
+	InitialStopLoss float64
+	Trailing        *types.TrailingStop
+	BreakEven       *types.BreakEven
+	StopMoves       []StopMove
+
+	best          float64   // Best exit side price since entry
+	mark          types.Bar // Last bar the position was checked against
+	prevClose     float64
+	atr           float64
+	breakEvenDone bool
+	financedTo    time.Time
+	rate          float64 // Last rate converting the quote currency into the home currency
 }
 
 type Trade struct {
 	ID         int
+	Instrument string
 	EntryTime  time.Time
 	ExitTime   time.Time
 	Direction  Direction
@@ -41,19 +75,32 @@ type Trade struct {
 	Size       float64
 	StopLoss   float64
 	TakeProfit float64
-	PnL        float64
+	GrossPnL   float64 // PnL from price movement alone
+	Costs      Costs   // Entry and exit costs
+	Financing  float64 // Overnight financing, negative is a charge
+	PnL        float64 // Net of costs and financing
 	PnLPercent float64
 	ExitReason string
+	Resolution string // How an ambiguous SL/TP bar was resolved, empty if only one level was hit
+	Partial    bool   // Only part of the position was closed, the rest stayed open under the same ID
+	// ConversionRate converted the PnL, costs and financing from the quote currency into the account
+	// currency at exit, all money fields are in the account currency
+	ConversionRate float64
+
+	InitialStopLoss float64
+	StopMoves       []StopMove // Every move of the stop loss, StopLoss is the final level
 }
 
-func (t Trade) Print() {
-	fmt.Printf("#%d | %s | Entry: %.5f @ %s | Exit: %.5f @ %s | P&L: £%.2f | %s\n",
+// Print prints the trade with its PnL in the given account currency
+func (t Trade) Print(currency string) {
+	fmt.Printf("#%d | %s | Entry: %.5f @ %s | Exit: %.5f @ %s | P&L: %s%.2f | %s\n",
 		t.ID,
 		t.Direction,
 		t.EntryPrice,
 		t.EntryTime.Format("2006-01-02 15:04"),
 		t.ExitPrice,
 		t.ExitTime.Format("2006-01-02 15:04"),
+		CurrencySymbol(currency),
 		t.PnL,
 		t.ExitReason,
 	)
@@ -62,106 +109,173 @@ func (t Trade) Print() {
 func NewAccount(initialBalance float64) *Account {
 	return &Account{
 		Balance:        initialBalance,
+		Resolver:       PessimisticResolver{},
 		openPositions:  []*Position{},
+		pendingOrders:  []*Order{},
 		nextPositionID: 1,
+		nextOrderID:    1,
 	}
 }
 
-func (a *Account) OpenTrade(signal types.Signal, timestamp time.Time) *Position {
-	slog.Info("Opening trade", "action", signal.Action, "id", a.nextPositionID, "price", signal.Price, "size", signal.Size, "tp", signal.TP, "sl", signal.SL, "timestamp", timestamp)
-	// TODO: Check if we have enough balance/margin
-	// TODO: Apply risk management rules
-	// OR potentially delegate this to the caller only
-
-	var dir Direction
-	switch signal.Action {
-	case types.BUY:
-		dir = LONG
-	case types.SELL:
-		dir = SHORT
+// OpenTrade opens a position from the signal at the signal price, on the given bar.
+// Returns an *OrderRejectedError if the signal breaks the instrument's limits or the account doesn't have the margin for it.
+func (a *Account) OpenTrade(signal types.Signal, bar types.Bar) (*Position, error) {
+	signal, err := a.conform(signal, bar.Timestamp)
+	if err != nil {
+		return nil, err
+	}
+	rate, err := a.homeRate(signal.Instrument, bar.Timestamp)
+	if err != nil {
+		return nil, a.reject(signal, bar.Timestamp, NO_CONVERSION_RATE, err.Error())
+	}
+	if err := a.checkMargin(signal, rate, bar.Timestamp); err != nil {
+		return nil, err
 	}
 
+	slog.Info("Opening trade", "instrument", signal.Instrument, "action", signal.Action, "id", a.nextPositionID, "price", signal.Price, "size", signal.Size, "tp", signal.TP, "sl", signal.SL, "timestamp", bar.Timestamp)
+
 	pos := &Position{
 		ID:         a.nextPositionID,
-		OpenTime:   timestamp,
-		Direction:  dir,
+		Instrument: signal.Instrument,
+		OpenTime:   bar.Timestamp,
+		Direction:  directionFromAction(signal.Action),
 		EntryPrice: signal.Price,
 		Size:       signal.Size,
 		StopLoss:   signal.SL,
 		TakeProfit: signal.TP,
+
+		InitialStopLoss: signal.SL,
+		Trailing:        signal.Trailing,
+		BreakEven:       signal.BreakEven,
+		best:            signal.Price,
+		prevClose:       signal.Price,
+		rate:            rate,
+	}
+	if signal.Trailing != nil {
+		pos.atr = signal.Trailing.ATR
 	}
+	pos.EntryCosts = a.costs(Fill{
+		Bar:       bar,
+		Direction: pos.Direction,
+		Price:     pos.EntryPrice,
+		Size:      pos.Size,
+		Entry:     true,
+	})
 
 	a.nextPositionID++
 	a.openPositions = append(a.openPositions, pos)
 
-	return pos
+	return pos, nil
 }
 
 // CheckExits checks all open positions against the given bar for stop loss or take profit hits.
+// When a bar touches both levels the account's Resolver decides which was hit first.
+// Positions still open then have their trailing stop and break-even rules applied.
 func (a *Account) CheckExits(bar types.Bar) []Trade {
+	return a.checkExits(bar, anyInstrument)
+}
+
+// CheckExitsFor is CheckExits for only the positions in the given instrument
+func (a *Account) CheckExitsFor(instrument string, bar types.Bar) []Trade {
+	return a.checkExits(bar, instrumentIs(instrument))
+}
+
+func (a *Account) checkExits(bar types.Bar, match func(instrument string) bool) []Trade {
 	var closedTrades []Trade
 	remainingPositions := []*Position{}
 
 	for _, pos := range a.openPositions {
-		closed := false
-		var trade Trade
-
-		if pos.Direction == LONG {
-			// Check stop loss
-			if bar.Low <= pos.StopLoss {
-				slog.Debug("Stop loss hit", "position_id", pos.ID, "stop_loss", pos.StopLoss, "bar_low", bar.Low, "timestamp", bar.Timestamp)
-				trade = a.closePosition(pos, pos.StopLoss, bar.Timestamp, "STOP_LOSS")
-				closed = true
-			}
-			// Check take profit
-			if bar.High >= pos.TakeProfit {
-				slog.Debug("Take profit hit", "position_id", pos.ID, "take_profit", pos.TakeProfit, "bar_high", bar.High, "timestamp", bar.Timestamp)
-				trade = a.closePosition(pos, pos.TakeProfit, bar.Timestamp, "TAKE_PROFIT")
-				closed = true
-			}
-		} else { // DIR_SHORT
-			// Check stop loss
-			if bar.High >= pos.StopLoss {
-				slog.Debug("Stop loss hit", "position_id", pos.ID, "stop_loss", pos.StopLoss, "bar_high", bar.High, "timestamp", bar.Timestamp)
-				trade = a.closePosition(pos, pos.StopLoss, bar.Timestamp, "STOP_LOSS")
-				closed = true
-			}
-			// Check take profit
-			if bar.Low <= pos.TakeProfit {
-				slog.Debug("Take profit hit", "position_id", pos.ID, "take_profit", pos.TakeProfit, "bar_low", bar.Low, "timestamp", bar.Timestamp)
-				trade = a.closePosition(pos, pos.TakeProfit, bar.Timestamp, "TAKE_PROFIT")
-				closed = true
-			}
+		if !match(pos.Instrument) {
+			remainingPositions = append(remainingPositions, pos)
+			continue
 		}
 
-		if closed {
-			closedTrades = append(closedTrades, trade)
-		} else {
+		// Anything still open at the start of this bar was held over any rollover before it
+		a.applyFinancing(pos, bar.Timestamp)
+
+		stopLossHit, takeProfitHit := pos.ExitsHit(bar)
+
+		var trade Trade
+		switch {
+		case stopLossHit && takeProfitHit:
+			stopLossFirst, resolution := a.Resolver.Resolve(pos, bar)
+			slog.Debug("Stop loss and take profit both hit", "position_id", pos.ID, "stop_loss", pos.StopLoss, "take_profit", pos.TakeProfit, "stop_loss_first", stopLossFirst, "resolution", resolution, "timestamp", bar.Timestamp)
+			if stopLossFirst {
+				trade = a.closePosition(pos, pos.StopLoss, bar, "STOP_LOSS")
+			} else {
+				trade = a.closePosition(pos, pos.TakeProfit, bar, "TAKE_PROFIT")
+			}
+			trade.Resolution = resolution
+		case stopLossHit:
+			slog.Debug("Stop loss hit", "position_id", pos.ID, "stop_loss", pos.StopLoss, "bar_high", bar.High, "bar_low", bar.Low, "timestamp", bar.Timestamp)
+			trade = a.closePosition(pos, pos.StopLoss, bar, "STOP_LOSS")
+		case takeProfitHit:
+			slog.Debug("Take profit hit", "position_id", pos.ID, "take_profit", pos.TakeProfit, "bar_high", bar.High, "bar_low", bar.Low, "timestamp", bar.Timestamp)
+			trade = a.closePosition(pos, pos.TakeProfit, bar, "TAKE_PROFIT")
+		default:
+			pos.mark = bar
+			pos.manageStops(bar)
 			remainingPositions = append(remainingPositions, pos)
+			continue
 		}
+
+		closedTrades = append(closedTrades, trade)
 	}
 
 	a.openPositions = remainingPositions
 	return closedTrades
 }
 
-func (a *Account) closePosition(pos *Position, exitPrice float64, exitTime time.Time, reason string) Trade {
-	var pnl float64
+// ExitsHit reports whether the bar traded through the position's stop loss and take profit levels.
+// Exits are checked on the side of the book the position closes on, longs on the bid and shorts on the ask.
+func (p *Position) ExitsHit(bar types.Bar) (stopLoss, takeProfit bool) {
+	prices := bar.Side(p.Direction.exitAction())
+	if p.Direction == LONG {
+		return prices.Low <= p.StopLoss, prices.High >= p.TakeProfit
+	}
+	return prices.High >= p.StopLoss, prices.Low <= p.TakeProfit
+}
+
+// ExitAction returns the action that closes the position
+func (p *Position) ExitAction() types.Action {
+	return p.Direction.exitAction()
+}
+
+func (a *Account) closePosition(pos *Position, exitPrice float64, bar types.Bar, reason string) Trade {
+	a.applyFinancing(pos, bar.Timestamp)
+
+	var grossPnL float64
+	exitTime := bar.Timestamp
 
 	if pos.Direction == LONG {
-		pnl = (exitPrice - pos.EntryPrice) * pos.Size
-		slog.Debug("Calculating PnL for LONG", "exit_price", exitPrice, "entry_price", pos.EntryPrice, "size", pos.Size, "pnl", pnl)
+		grossPnL = (exitPrice - pos.EntryPrice) * pos.Size
+		slog.Debug("Calculating PnL for LONG", "exit_price", exitPrice, "entry_price", pos.EntryPrice, "size", pos.Size, "pnl", grossPnL)
 	} else { // SHORT
-		pnl = (pos.EntryPrice - exitPrice) * pos.Size
-		slog.Debug("Calculating PnL for SHORT", "exit_price", exitPrice, "entry_price", pos.EntryPrice, "size", pos.Size, "pnl", pnl)
+		grossPnL = (pos.EntryPrice - exitPrice) * pos.Size
+		slog.Debug("Calculating PnL for SHORT", "exit_price", exitPrice, "entry_price", pos.EntryPrice, "size", pos.Size, "pnl", grossPnL)
 	}
 
+	costs := pos.EntryCosts.Add(a.costs(Fill{
+		Bar:       bar,
+		Direction: pos.Direction,
+		Price:     exitPrice,
+		Size:      pos.Size,
+	}))
+
+	// Everything so far is in the quote currency
+	rate := a.positionRate(pos, exitTime)
+	grossPnL *= rate
+	costs = costs.Scale(rate)
+	financing := pos.Financing * rate
+	pnl := grossPnL - costs.Total() + financing
+
 	a.Balance += pnl
 
-	slog.Info("Closed position", "id", pos.ID, "exit_price", exitPrice, "stop_loss", pos.StopLoss, "take_profit", pos.TakeProfit, "pnl", pnl, "reason", reason, "timestamp", exitTime)
+	slog.Info("Closed position", "id", pos.ID, "instrument", pos.Instrument, "exit_price", exitPrice, "stop_loss", pos.StopLoss, "take_profit", pos.TakeProfit, "gross_pnl", grossPnL, "costs", costs.Total(), "financing", financing, "conversion_rate", rate, "pnl", pnl, "reason", reason, "timestamp", exitTime)
 
 	return Trade{
 		ID:         pos.ID,
+		Instrument: pos.Instrument,
 		EntryTime:  pos.OpenTime,
 		ExitTime:   exitTime,
 		Direction:  pos.Direction,
@@ -170,28 +284,202 @@ func (a *Account) closePosition(pos *Position, exitPrice float64, exitTime time.
 		Size:       pos.Size,
 		StopLoss:   pos.StopLoss,
 		TakeProfit: pos.TakeProfit,
+		GrossPnL:   grossPnL,
+		Costs:      costs,
+		Financing:  financing,
 		PnL:        pnl,
 		PnLPercent: (pnl / pos.EntryPrice) * 100,
 		ExitReason: reason,
+
+		ConversionRate:  rate,
+		InitialStopLoss: pos.InitialStopLoss,
+		StopMoves:       pos.StopMoves,
+	}
+}
+
+// Targets returns the open positions a CLOSE or MODIFY signal applies to
+func (a *Account) Targets(signal types.Signal) []*Position {
+	var targets []*Position
+	for _, pos := range a.openPositions {
+		if signal.PositionID != 0 {
+			// A signal naming another instrument would be filled at that instrument's price
+			if pos.ID == signal.PositionID && (signal.Instrument == "" || pos.Instrument == signal.Instrument) {
+				targets = append(targets, pos)
+			}
+			continue
+		}
+		if signal.Instrument != "" && pos.Instrument != signal.Instrument {
+			continue
+		}
+		if signal.Action != "" && pos.Direction != directionFromAction(signal.Action) {
+			continue
+		}
+		targets = append(targets, pos)
+	}
+	return targets
+}
+
+// ClosePosition closes a fraction of the position with the given ID at price, closing all of it
+// when fraction is zero or at least 1. A partial close is rounded down to the units the instrument
+// trades in, closing all of the position if what's left would be below the minimum trade size.
+// Returns false if no position with the ID is open, or the close is rejected (see Rejections).
+func (a *Account) ClosePosition(id int, fraction float64, price float64, bar types.Bar, reason string) (Trade, bool) {
+	for i, pos := range a.openPositions {
+		if pos.ID != id {
+			continue
+		}
+
+		units := pos.Size
+		if fraction > 0 && fraction < 1 {
+			var err error
+			if units, err = a.closeUnits(pos, fraction, price, bar.Timestamp); err != nil {
+				return Trade{}, false
+			}
+		}
+		if units >= pos.Size {
+			a.openPositions = append(a.openPositions[:i], a.openPositions[i+1:]...)
+			return a.closePosition(pos, price, bar, reason), true
+		}
+
+		// Close a slice of the position, entry costs and financing are split in proportion to size
+		a.applyFinancing(pos, bar.Timestamp)
+		fraction = units / pos.Size
+		part := *pos
+		part.Size = units
+		part.EntryCosts = pos.EntryCosts.Scale(fraction)
+		part.Financing = pos.Financing * fraction
+		pos.Size -= part.Size
+		pos.EntryCosts = pos.EntryCosts.Scale(1 - fraction)
+		pos.Financing -= part.Financing
+
+		trade := a.closePosition(&part, price, bar, reason)
+		trade.Partial = true
+		return trade, true
 	}
+	return Trade{}, false
+}
+
+// ModifyPosition moves the stop loss and take profit of the position with the given ID, a zero level
+// is left unchanged. Levels are rounded to the instrument's tick, and the stop loss must be at least
+// the instrument's minimum distance from the current price. Returns false if no position with the ID
+// is open, or the change is rejected (see Rejections).
+func (a *Account) ModifyPosition(id int, stopLoss, takeProfit float64, timestamp time.Time) bool {
+	for _, pos := range a.openPositions {
+		if pos.ID != id {
+			continue
+		}
+
+		signal := types.Signal{Instrument: pos.Instrument, Type: types.MODIFY, PositionID: id, Price: pos.MarkPrice(), SL: stopLoss, TP: takeProfit}
+		signal, err := a.conformModify(pos, signal, timestamp)
+		if err != nil {
+			return false
+		}
+		stopLoss, takeProfit = signal.SL, signal.TP
+
+		slog.Info("Modifying position", "id", id, "stop_loss", pos.StopLoss, "new_stop_loss", stopLoss, "take_profit", pos.TakeProfit, "new_take_profit", takeProfit, "timestamp", timestamp)
+		if stopLoss != 0 && stopLoss != pos.StopLoss {
+			pos.StopMoves = append(pos.StopMoves, StopMove{Time: timestamp, From: pos.StopLoss, To: stopLoss, Reason: STOP_MODIFIED})
+			pos.StopLoss = stopLoss
+		}
+		if takeProfit != 0 {
+			pos.TakeProfit = takeProfit
+		}
+		return true
+	}
+	return false
 }
 
 func (a *Account) CloseAll(lastBar types.Bar) []Trade {
+	return a.closeAll(lastBar, anyInstrument)
+}
+
+// CloseAllFor closes the positions in the given instrument at the close of its last bar
+func (a *Account) CloseAllFor(instrument string, lastBar types.Bar) []Trade {
+	return a.closeAll(lastBar, instrumentIs(instrument))
+}
+
+func (a *Account) closeAll(lastBar types.Bar, match func(instrument string) bool) []Trade {
 	var trades []Trade
+	remainingPositions := []*Position{}
 
 	for _, pos := range a.openPositions {
-		trade := a.closePosition(pos, lastBar.Close, lastBar.Timestamp, "END_OF_BACKTEST")
+		if !match(pos.Instrument) {
+			remainingPositions = append(remainingPositions, pos)
+			continue
+		}
+		trade := a.closePosition(pos, lastBar.Side(pos.Direction.exitAction()).Close, lastBar, "END_OF_BACKTEST")
 		trades = append(trades, trade)
 	}
 
-	a.openPositions = []*Position{}
+	a.openPositions = remainingPositions
 	return trades
 }
 
+func (a *Account) costs(fill Fill) Costs {
+	if a.CostModel == nil {
+		return Costs{}
+	}
+	return a.CostModel.Costs(fill)
+}
+
+func anyInstrument(string) bool {
+	return true
+}
+
+func instrumentIs(name string) func(instrument string) bool {
+	return func(instrument string) bool {
+		return instrument == name
+	}
+}
+
+func directionFromAction(action types.Action) Direction {
+	if action == types.SELL {
+		return SHORT
+	}
+	return LONG
+}
+
+// entryAction returns the action that opens a position in this direction
+func (d Direction) entryAction() types.Action {
+	if d == SHORT {
+		return types.SELL
+	}
+	return types.BUY
+}
+
+// exitAction returns the action that closes a position in this direction
+func (d Direction) exitAction() types.Action {
+	if d == SHORT {
+		return types.BUY
+	}
+	return types.SELL
+}
+
 func (a *Account) OpenPositions() []*Position {
 	return a.openPositions
 }
 
+// OpenPositionsFor returns the open positions in the given instrument
+func (a *Account) OpenPositionsFor(instrument string) []*Position {
+	var positions []*Position
+	for _, pos := range a.openPositions {
+		if pos.Instrument == instrument {
+			positions = append(positions, pos)
+		}
+	}
+	return positions
+}
+
+// Position returns the open position with the given ID, false if it isn't open
+func (a *Account) Position(id int) (*Position, bool) {
+	for _, pos := range a.openPositions {
+		if pos.ID == id {
+			return pos, true
+		}
+	}
+	return nil, false
+}
+
 func (a *Account) PositionCount() int {
 	return len(a.openPositions)
 }
@@ -0,0 +1,538 @@
+package account
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jwtly10/tradebook/internal/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckExits_ResolvesBarsTouchingBothLevels(t *testing.T) {
+	ts := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	long := types.Signal{Type: types.OPEN, Action: types.BUY, Price: 100, SL: 95, TP: 110, Size: 1}
+	short := types.Signal{Type: types.OPEN, Action: types.SELL, Price: 100, SL: 105, TP: 90, Size: 1}
+
+	// Open is nearer the high, so the path heuristic visits the high first
+	bar := types.Bar{Timestamp: ts.Add(time.Minute), Open: 108, High: 111, Low: 89, Close: 100}
+
+	tests := []struct {
+		name       string
+		resolver   ExitResolver
+		signal     types.Signal
+		exitReason string
+		resolution string
+	}{
+		{"pessimistic long", PessimisticResolver{}, long, "STOP_LOSS", RESOLUTION_PESSIMISTIC},
+		{"optimistic long", OptimisticResolver{}, long, "TAKE_PROFIT", RESOLUTION_OPTIMISTIC},
+		{"ohlc path long", OHLCPathResolver{}, long, "TAKE_PROFIT", RESOLUTION_OHLC_PATH},
+		{"ohlc path short", OHLCPathResolver{}, short, "STOP_LOSS", RESOLUTION_OHLC_PATH},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			acc := NewAccount(10000)
+			acc.Resolver = tt.resolver
+			acc.OpenTrade(tt.signal, types.Bar{Timestamp: ts})
+
+			trades := acc.CheckExits(bar)
+
+			assert.Equal(t, 1, len(trades))
+			assert.Equal(t, tt.exitReason, trades[0].ExitReason)
+			assert.Equal(t, tt.resolution, trades[0].Resolution)
+			assert.Equal(t, 0, acc.PositionCount())
+		})
+	}
+}
+
+func TestCheckExits_UnambiguousBarHasNoResolution(t *testing.T) {
+	ts := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	acc := NewAccount(10000)
+	acc.OpenTrade(types.Signal{Type: types.OPEN, Action: types.BUY, Price: 100, SL: 95, TP: 110, Size: 2}, types.Bar{Timestamp: ts})
+
+	trades := acc.CheckExits(types.Bar{Timestamp: ts.Add(time.Minute), Open: 100, High: 111, Low: 99, Close: 110})
+
+	assert.Equal(t, 1, len(trades))
+	assert.Equal(t, "TAKE_PROFIT", trades[0].ExitReason)
+	assert.Equal(t, "", trades[0].Resolution)
+	assert.Equal(t, float64(20), trades[0].PnL)
+	assert.Equal(t, float64(10020), acc.Balance)
+}
+
+func TestCloseTrade_ChargesCostsOnBothSides(t *testing.T) {
+	ts := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	acc := NewAccount(10000)
+	acc.CostModel = CostModels{
+		FixedSpread{Spread: 1},
+		PerLotCommission{PerLot: 2, LotSize: 10},
+		VolatilitySlippage{Multiplier: 0.1},
+	}
+
+	acc.OpenTrade(types.Signal{Type: types.OPEN, Action: types.BUY, Price: 100, SL: 90, TP: 110, Size: 10}, types.Bar{Timestamp: ts, High: 101, Low: 99})
+	trades := acc.CheckExits(types.Bar{Timestamp: ts.Add(time.Minute), Open: 105, High: 112, Low: 104, Close: 111})
+
+	assert.Equal(t, 1, len(trades))
+	trade := trades[0]
+	assert.Equal(t, float64(100), trade.GrossPnL)
+	// Half the spread per side, per unit
+	assert.InDelta(t, 10, trade.Costs.Spread, 1e-9)
+	// One lot each side
+	assert.InDelta(t, 4, trade.Costs.Commission, 1e-9)
+	// 10% of the range of each bar, per unit
+	assert.InDelta(t, 2+8, trade.Costs.Slippage, 1e-9)
+	assert.InDelta(t, 76, trade.PnL, 1e-9)
+	assert.InDelta(t, 10076, acc.Balance, 1e-9)
+}
+
+func TestBarSpread_UsesFallbackWhenBarHasNoSpread(t *testing.T) {
+	model := BarSpread{Fallback: 2}
+
+	assert.Equal(t, float64(1.5), model.Costs(Fill{Bar: types.Bar{Spread: 0.5}, Size: 6}).Spread)
+	assert.Equal(t, float64(6), model.Costs(Fill{Bar: types.Bar{}, Size: 6}).Spread)
+}
+
+func TestCheckExits_UsesCorrectSideOfTheBook(t *testing.T) {
+	ts := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	acc := NewAccount(10000)
+
+	acc.OpenTrade(types.Signal{Type: types.OPEN, Action: types.BUY, Price: 101, SL: 95, TP: 110, Size: 1}, types.Bar{Timestamp: ts})
+	acc.OpenTrade(types.Signal{Type: types.OPEN, Action: types.SELL, Price: 99, SL: 112, TP: 95, Size: 1}, types.Bar{Timestamp: ts})
+
+	// Mid touches both take profits, but the long exits on the bid and the short on the ask
+	bar := types.Bar{
+		Timestamp: ts.Add(time.Minute),
+		Open:      100, High: 110, Low: 95, Close: 100,
+		Bid: types.OHLC{Open: 99, High: 109, Low: 95.5, Close: 99},
+		Ask: types.OHLC{Open: 101, High: 111, Low: 96, Close: 101},
+	}
+
+	trades := acc.CheckExits(bar)
+	assert.Equal(t, 0, len(trades), "Neither take profit was reached on the side the position closes on")
+	assert.Equal(t, 2, acc.PositionCount())
+
+	// Closing at the end uses the bid for longs and the ask for shorts
+	trades = acc.CloseAll(bar)
+	assert.Equal(t, float64(99), trades[0].ExitPrice)
+	assert.Equal(t, float64(101), trades[1].ExitPrice)
+}
+
+func TestRandomSlippage_ZeroValueIsUsable(t *testing.T) {
+	fill := Fill{Price: 100, Size: 10}
+	var zero RandomSlippage
+	seeded := NewRandomSlippage(0.5, 0)
+	zero.Max = 0.5
+
+	for i := 0; i < 3; i++ {
+		costs := zero.Costs(fill)
+		assert.Equal(t, seeded.Costs(fill), costs, "The zero value is seeded like NewRandomSlippage with seed 0")
+		assert.True(t, costs.Slippage >= 0 && costs.Slippage <= 5)
+	}
+}
+
+func TestClosePosition_PartialCloseSplitsSizeAndCosts(t *testing.T) {
+	ts := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	acc := NewAccount(10000)
+	acc.CostModel = PerLotCommission{PerLot: 2, LotSize: 10}
+
+	pos, _ := acc.OpenTrade(types.Signal{Type: types.OPEN, Action: types.BUY, Price: 100, SL: 90, TP: 110, Size: 10}, types.Bar{Timestamp: ts})
+	bar := types.Bar{Timestamp: ts.Add(time.Minute), Close: 104}
+
+	trade, ok := acc.ClosePosition(pos.ID, 0.25, 104, bar, "STRATEGY_EXIT")
+	assert.True(t, ok)
+	assert.True(t, trade.Partial)
+	assert.Equal(t, 2.5, trade.Size)
+	assert.Equal(t, float64(10), trade.GrossPnL)
+	// A quarter of the entry commission, plus the exit commission on a quarter lot
+	assert.InDelta(t, 1, trade.Costs.Commission, 1e-9)
+	assert.Equal(t, 7.5, pos.Size)
+	assert.InDelta(t, 1.5, pos.EntryCosts.Commission, 1e-9)
+	assert.Equal(t, 1, acc.PositionCount())
+
+	trade, ok = acc.ClosePosition(pos.ID, 0, 106, bar, "STRATEGY_EXIT")
+	assert.True(t, ok)
+	assert.False(t, trade.Partial)
+	assert.Equal(t, 0, acc.PositionCount())
+
+	_, ok = acc.ClosePosition(pos.ID, 0, 106, bar, "STRATEGY_EXIT")
+	assert.False(t, ok)
+}
+
+func TestTargets_SelectsByIDOrDirection(t *testing.T) {
+	ts := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	acc := NewAccount(10000)
+	long, _ := acc.OpenTrade(types.Signal{Type: types.OPEN, Action: types.BUY, Price: 100, Size: 1}, types.Bar{Timestamp: ts})
+	short, _ := acc.OpenTrade(types.Signal{Type: types.OPEN, Action: types.SELL, Price: 100, Size: 1}, types.Bar{Timestamp: ts})
+
+	assert.Equal(t, []*Position{short}, acc.Targets(types.Signal{Type: types.CLOSE, PositionID: short.ID}))
+	assert.Empty(t, acc.Targets(types.Signal{Type: types.CLOSE, Instrument: "GBP_USD", PositionID: short.ID}))
+	assert.Equal(t, []*Position{long}, acc.Targets(types.Signal{Type: types.CLOSE, Action: types.BUY}))
+	assert.Equal(t, 2, len(acc.Targets(types.Signal{Type: types.CLOSE})))
+
+	assert.True(t, acc.ModifyPosition(long.ID, 95, 0, ts))
+	assert.Equal(t, float64(95), long.StopLoss)
+	assert.Equal(t, float64(0), long.TakeProfit)
+}
+
+func TestCheckExits_TrailsStopBehindBestPrice(t *testing.T) {
+	ts := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	acc := NewAccount(10000)
+	acc.OpenTrade(types.Signal{
+		Type: types.OPEN, Action: types.BUY, Price: 100, SL: 95, TP: 200, Size: 1,
+		Trailing:  &types.TrailingStop{Mode: types.TRAIL_FIXED, Distance: 3},
+		BreakEven: &types.BreakEven{TriggerR: 1},
+	}, types.Bar{Timestamp: ts})
+
+	assert.Empty(t, acc.CheckExits(types.Bar{Timestamp: ts.Add(time.Minute), Open: 100, High: 104, Low: 99, Close: 103}))
+	assert.Equal(t, float64(101), acc.OpenPositions()[0].StopLoss)
+
+	// Break-even triggers at 1R, but the trailing stop is already tighter
+	assert.Empty(t, acc.CheckExits(types.Bar{Timestamp: ts.Add(2 * time.Minute), Open: 103, High: 106, Low: 102, Close: 105}))
+	assert.Equal(t, float64(103), acc.OpenPositions()[0].StopLoss)
+
+	trades := acc.CheckExits(types.Bar{Timestamp: ts.Add(3 * time.Minute), Open: 105, High: 105, Low: 102.5, Close: 103})
+	assert.Equal(t, 1, len(trades))
+	trade := trades[0]
+	assert.Equal(t, "STOP_LOSS", trade.ExitReason)
+	assert.Equal(t, float64(103), trade.ExitPrice)
+	assert.Equal(t, float64(95), trade.InitialStopLoss)
+	assert.Equal(t, float64(103), trade.StopLoss)
+	assert.Equal(t, []StopMove{
+		{Time: ts.Add(time.Minute), From: 95, To: 101, Reason: STOP_TRAILING},
+		{Time: ts.Add(2 * time.Minute), From: 101, To: 103, Reason: STOP_TRAILING},
+	}, trade.StopMoves)
+}
+
+func TestOpenTrade_RejectsBreakEvenInRWithoutStopLoss(t *testing.T) {
+	ts := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	acc := NewAccount(10000)
+	signal := types.Signal{Type: types.OPEN, Action: types.BUY, Price: 100, TP: 110, Size: 1, BreakEven: &types.BreakEven{TriggerR: 1}}
+
+	_, err := acc.OpenTrade(signal, types.Bar{Timestamp: ts})
+	var rejection *OrderRejectedError
+	assert.ErrorAs(t, err, &rejection)
+	assert.Equal(t, BREAK_EVEN_STOP_LOSS_MISSING, rejection.Reason)
+
+	signal.OrderType = types.LIMIT
+	_, err = acc.PlaceOrder(signal, ts)
+	assert.ErrorAs(t, err, &rejection, "Pending orders are checked when placed")
+	assert.Equal(t, 2, len(acc.Rejections()))
+
+	// A trigger in price doesn't need a stop loss
+	signal.OrderType = types.MARKET
+	signal.BreakEven = &types.BreakEven{Trigger: 2}
+	_, err = acc.OpenTrade(signal, types.Bar{Timestamp: ts})
+	assert.NoError(t, err)
+}
+
+func TestCheckExits_MovesStopToBreakEven(t *testing.T) {
+	ts := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	acc := NewAccount(10000)
+	acc.OpenTrade(types.Signal{
+		Type: types.OPEN, Action: types.SELL, Price: 100, SL: 105, TP: 90, Size: 1,
+		BreakEven: &types.BreakEven{Trigger: 2, Offset: 0.5},
+	}, types.Bar{Timestamp: ts})
+
+	acc.CheckExits(types.Bar{Timestamp: ts.Add(time.Minute), Open: 100, High: 100.5, Low: 98.5, Close: 99})
+	assert.Equal(t, float64(105), acc.OpenPositions()[0].StopLoss, "Not far enough in profit yet")
+
+	acc.CheckExits(types.Bar{Timestamp: ts.Add(2 * time.Minute), Open: 99, High: 99.5, Low: 97.5, Close: 98})
+	pos := acc.OpenPositions()[0]
+	assert.Equal(t, 99.5, pos.StopLoss)
+	assert.Equal(t, STOP_BREAK_EVEN, pos.StopMoves[0].Reason)
+}
+
+func TestOpenTrade_RejectsWithoutEnoughMargin(t *testing.T) {
+	ts := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	acc := NewAccount(1000)
+	acc.Margin = &Margin{Rates: map[string]float64{"NAS100_USD": 0.05}, DefaultRate: 1}
+
+	// 20:1 leverage, 400 units at 40 needs 800 margin
+	pos, err := acc.OpenTrade(types.Signal{Instrument: "NAS100_USD", Type: types.OPEN, Action: types.BUY, Price: 40, SL: 1, TP: 100, Size: 400}, types.Bar{Timestamp: ts})
+	assert.NoError(t, err)
+	assert.Equal(t, float64(800), acc.MarginUsed())
+
+	_, err = acc.OpenTrade(types.Signal{Instrument: "NAS100_USD", Type: types.OPEN, Action: types.BUY, Price: 40, SL: 1, TP: 100, Size: 400}, types.Bar{Timestamp: ts})
+	var rejected *OrderRejectedError
+	assert.ErrorAs(t, err, &rejected)
+	assert.Equal(t, INSUFFICIENT_MARGIN, rejected.Reason)
+	assert.Equal(t, 1, len(acc.Rejections()))
+
+	// Instruments without a rate use the default, here no leverage at all
+	_, err = acc.OpenTrade(types.Signal{Instrument: "GBP_USD", Type: types.OPEN, Action: types.BUY, Price: 1, SL: 0.5, TP: 2, Size: 201}, types.Bar{Timestamp: ts})
+	assert.Error(t, err)
+
+	// Marked to market at the close of the last bar checked
+	acc.CheckExits(types.Bar{Timestamp: ts.Add(time.Minute), Open: 40, High: 41, Low: 39, Close: 39.5})
+	assert.Equal(t, float64(-200), pos.UnrealisedPnL())
+	assert.Equal(t, float64(800), acc.Equity())
+}
+
+func TestCheckMarginCloseout_ClosesEverythingBelowCloseoutLevel(t *testing.T) {
+	ts := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	acc := NewAccount(1000)
+	acc.Margin = &Margin{DefaultRate: 0.05, CloseoutLevel: 0.5}
+
+	acc.OpenTrade(types.Signal{Type: types.OPEN, Action: types.BUY, Price: 100, SL: 1, TP: 200, Size: 100}, types.Bar{Timestamp: ts})
+	assert.Equal(t, float64(500), acc.MarginUsed())
+
+	acc.CheckExits(types.Bar{Timestamp: ts.Add(time.Minute), Open: 100, High: 100, Low: 96, Close: 96})
+	assert.Empty(t, acc.CheckMarginCloseout(), "Equity 600 is above half of 480 margin")
+
+	acc.CheckExits(types.Bar{Timestamp: ts.Add(2 * time.Minute), Open: 96, High: 96, Low: 91, Close: 92})
+	// Equity 200 is below half of 460 margin
+	trades := acc.CheckMarginCloseout()
+	assert.Equal(t, 1, len(trades))
+	assert.Equal(t, MARGIN_CLOSEOUT, trades[0].ExitReason)
+	assert.Equal(t, float64(92), trades[0].ExitPrice)
+	assert.Equal(t, float64(200), acc.Balance)
+	assert.Equal(t, 0, acc.PositionCount())
+}
+
+func TestFinancing_TripleDayPerInstrument(t *testing.T) {
+	// Thursday morning in New York
+	ts := time.Date(2025, 1, 9, 12, 0, 0, 0, time.UTC)
+	acc := NewAccount(10000)
+	acc.Financing = NewFinancing(map[string]FinancingRate{
+		"NAS100_USD": {Long: -0.0365, TripleDay: time.Friday},
+		"GBP_USD":    {Long: -0.0365},
+	})
+
+	acc.OpenTrade(types.Signal{Instrument: "NAS100_USD", Type: types.OPEN, Action: types.BUY, Price: 100, SL: 50, TP: 200, Size: 10}, types.Bar{Timestamp: ts})
+	acc.OpenTrade(types.Signal{Instrument: "GBP_USD", Type: types.OPEN, Action: types.BUY, Price: 100, SL: 50, TP: 200, Size: 10}, types.Bar{Timestamp: ts})
+
+	// Thursday and Friday's rollovers, 0.1 a day on 1000 notional
+	trades := acc.CloseAll(types.Bar{Timestamp: ts.Add(48 * time.Hour), Open: 100, High: 100, Low: 100, Close: 100})
+	assert.InDelta(t, -0.4, trades[0].Financing, 1e-9, "NAS100 is charged triple on Friday")
+	assert.InDelta(t, -0.2, trades[1].Financing, 1e-9, "GBP_USD falls back to Wednesday")
+}
+
+func TestFinancing_ChargesEachRolloverWithTripleDay(t *testing.T) {
+	// Tuesday morning in New York
+	ts := time.Date(2025, 1, 7, 12, 0, 0, 0, time.UTC)
+	acc := NewAccount(10000)
+	acc.Financing = NewFinancing(map[string]FinancingRate{"NAS100_USD": {Long: -0.0365, Short: 0.01}})
+
+	acc.OpenTrade(types.Signal{Instrument: "NAS100_USD", Type: types.OPEN, Action: types.BUY, Price: 100, SL: 50, TP: 200, Size: 10}, types.Bar{Timestamp: ts})
+
+	// Held over Tuesday's rollover, 0.1 a day on 1000 notional
+	acc.CheckExits(types.Bar{Timestamp: ts.Add(24 * time.Hour), Open: 100, High: 100, Low: 100, Close: 100})
+	assert.InDelta(t, -0.1, acc.OpenPositions()[0].Financing, 1e-9)
+	assert.InDelta(t, 9999.9, acc.Equity(), 1e-9)
+
+	// Wednesday is charged three days
+	trades := acc.CloseAll(types.Bar{Timestamp: ts.Add(48 * time.Hour), Open: 100, High: 100, Low: 100, Close: 100})
+	assert.InDelta(t, -0.4, trades[0].Financing, 1e-9)
+	assert.InDelta(t, -0.4, trades[0].PnL, 1e-9)
+	assert.InDelta(t, 9999.6, acc.Balance, 1e-9)
+
+	// Only Friday's rollover between Friday and Monday
+	friday := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+	assert.Equal(t, 1, len(acc.Financing.rollovers(friday, friday.Add(72*time.Hour))))
+}
+
+type rateFunc func(from, to string, at time.Time) (float64, error)
+
+func (f rateFunc) Rate(from, to string, at time.Time) (float64, error) {
+	return f(from, to, at)
+}
+
+type fixedRates map[string]float64
+
+func (r fixedRates) Rate(from, to string, at time.Time) (float64, error) {
+	if from == to {
+		return 1, nil
+	}
+	return r[from+to], nil
+}
+
+func TestConversion_Covers(t *testing.T) {
+	ts := time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC)
+	// Rates start at ts
+	rates := rateFunc(func(from, to string, at time.Time) (float64, error) {
+		if at.Before(ts) {
+			return 0, errors.New("no rate")
+		}
+		return 0.8, nil
+	})
+	c := &Conversion{Home: "GBP", QuoteCurrencies: map[string]string{"NAS100_USD": "USD", "UK100_GBP": "GBP"}, Rates: rates}
+
+	assert.NoError(t, c.Covers("NAS100_USD", []types.Bar{{Timestamp: ts}, {Timestamp: ts.Add(time.Hour)}}))
+	assert.NoError(t, c.Covers("UK100_GBP", []types.Bar{{Timestamp: ts.Add(-time.Hour)}}), "Home currency instruments need no rates")
+	assert.ErrorContains(t, c.Covers("NAS100_USD", []types.Bar{{Timestamp: ts.Add(-time.Hour)}, {Timestamp: ts}}), "failed to convert NAS100_USD from USD into GBP")
+}
+
+func TestOpenTrade_RejectsWithoutConversionRate(t *testing.T) {
+	ts := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	acc := NewAccount(10000)
+	acc.Conversion = &Conversion{Home: "GBP", QuoteCurrencies: map[string]string{"NAS100_USD": "USD"}}
+	signal := types.Signal{Instrument: "NAS100_USD", Type: types.OPEN, Action: types.BUY, Price: 100, SL: 50, TP: 200, Size: 10}
+
+	_, err := acc.OpenTrade(signal, types.Bar{Timestamp: ts})
+	var rejection *OrderRejectedError
+	assert.ErrorAs(t, err, &rejection)
+	assert.Equal(t, NO_CONVERSION_RATE, rejection.Reason)
+	_, err = acc.QuoteRate("NAS100_USD", ts)
+	assert.Error(t, err)
+
+	// Rates that stop while the position is open leave it converted at the last rate
+	acc.Conversion.Rates = rateFunc(func(from, to string, at time.Time) (float64, error) {
+		if at.After(ts) {
+			return 0, errors.New("no rate")
+		}
+		return 0.8, nil
+	})
+	_, err = acc.OpenTrade(signal, types.Bar{Timestamp: ts})
+	assert.NoError(t, err)
+
+	trades := acc.CloseAll(types.Bar{Timestamp: ts.Add(time.Hour), Close: 110})
+	assert.Equal(t, 0.8, trades[0].ConversionRate)
+	assert.InDelta(t, 80, trades[0].PnL, 1e-9)
+}
+
+func TestClosePosition_ConvertsPnLIntoAccountCurrency(t *testing.T) {
+	ts := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	acc := NewAccount(10000)
+	acc.CostModel = FixedSpread{Spread: 1}
+	acc.Conversion = &Conversion{
+		Home:            "GBP",
+		QuoteCurrencies: map[string]string{"NAS100_USD": "USD", "UK100_GBP": "GBP"},
+		Rates:           fixedRates{"USDGBP": 0.8},
+	}
+	rate, err := acc.QuoteRate("NAS100_USD", ts)
+	assert.NoError(t, err)
+	assert.Equal(t, 1.25, rate)
+
+	acc.OpenTrade(types.Signal{Instrument: "NAS100_USD", Type: types.OPEN, Action: types.BUY, Price: 100, SL: 50, TP: 200, Size: 10}, types.Bar{Timestamp: ts})
+	acc.OpenTrade(types.Signal{Instrument: "UK100_GBP", Type: types.OPEN, Action: types.BUY, Price: 100, SL: 50, TP: 200, Size: 10}, types.Bar{Timestamp: ts})
+	trades := acc.CloseAll(types.Bar{Timestamp: ts.Add(time.Hour), Close: 110})
+
+	// $100 gross less $10 spread, at 0.8
+	assert.Equal(t, 0.8, trades[0].ConversionRate)
+	assert.InDelta(t, 80, trades[0].GrossPnL, 1e-9)
+	assert.InDelta(t, 8, trades[0].Costs.Spread, 1e-9)
+	assert.InDelta(t, 72, trades[0].PnL, 1e-9)
+
+	assert.Equal(t, float64(1), trades[1].ConversionRate)
+	assert.InDelta(t, 90, trades[1].PnL, 1e-9)
+	assert.InDelta(t, 10162, acc.Balance, 1e-9)
+}
+
+func TestOpenTrade_ConformsToInstrumentLimits(t *testing.T) {
+	ts := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	acc := NewAccount(10000)
+	acc.Instruments = map[string]types.Instrument{
+		"NAS100_USD": {Name: "NAS100_USD", DisplayPrecision: 1, TradeUnitsPrecision: 1, MinimumTradeSize: 0.1, MaximumOrderUnits: 100, MinimumStopDistance: 5, MinimumTrailingStopDistance: 5},
+	}
+	signal := types.Signal{Instrument: "NAS100_USD", Type: types.OPEN, Action: types.BUY, Price: 20000.04, SL: 19990.06, TP: 20020.01, Size: 3.79}
+
+	pos, err := acc.OpenTrade(signal, types.Bar{Timestamp: ts})
+	assert.NoError(t, err)
+	assert.Equal(t, 3.7, pos.Size, "Units round down")
+	assert.Equal(t, 20000.0, pos.EntryPrice)
+	assert.Equal(t, 19990.1, pos.StopLoss)
+	assert.Equal(t, 20020.0, pos.TakeProfit)
+
+	tests := []struct {
+		name   string
+		modify func(s *types.Signal)
+		reason string
+	}{
+		{"below minimum units", func(s *types.Signal) { s.Size = 0.09 }, UNITS_MINIMUM_NOT_MET},
+		{"above maximum units", func(s *types.Signal) { s.Size = 100.1 }, UNITS_LIMIT_EXCEEDED},
+		{"stop too close", func(s *types.Signal) { s.SL = 19995.1 }, STOP_LOSS_DISTANCE_MINIMUM_NOT_MET},
+		{"trailing stop too close", func(s *types.Signal) {
+			s.Trailing = &types.TrailingStop{Mode: types.TRAIL_FIXED, Distance: 2}
+		}, TRAILING_STOP_DISTANCE_MINIMUM_NOT_MET},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rejected := signal
+			tt.modify(&rejected)
+
+			_, err := acc.OpenTrade(rejected, types.Bar{Timestamp: ts})
+			var rejection *OrderRejectedError
+			assert.ErrorAs(t, err, &rejection)
+			assert.Equal(t, tt.reason, rejection.Reason)
+
+			_, err = acc.PlaceOrder(rejected, ts)
+			assert.Error(t, err, "Pending orders are checked when placed")
+		})
+	}
+
+	// A stop exactly at the minimum distance is allowed
+	signal.SL = 19995.0
+	_, err = acc.OpenTrade(signal, types.Bar{Timestamp: ts})
+	assert.NoError(t, err)
+
+	// The trailing stop minimum doesn't apply to ordinary stop losses
+	nas := acc.Instruments["NAS100_USD"]
+	nas.MinimumStopDistance = 0
+	acc.Instruments["NAS100_USD"] = nas
+	signal.SL = 19999.0
+	_, err = acc.OpenTrade(signal, types.Bar{Timestamp: ts})
+	assert.NoError(t, err)
+}
+
+func TestModifyPosition_ConformsToInstrumentLimits(t *testing.T) {
+	ts := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	acc := NewAccount(10000)
+	acc.Instruments = map[string]types.Instrument{
+		"NAS100_USD": {Name: "NAS100_USD", DisplayPrecision: 1, TradeUnitsPrecision: 1, MinimumTradeSize: 0.1, MinimumStopDistance: 5},
+	}
+	pos, err := acc.OpenTrade(types.Signal{Instrument: "NAS100_USD", Type: types.OPEN, Action: types.BUY, Price: 20000, SL: 19990, TP: 20020, Size: 1}, types.Bar{Timestamp: ts})
+	assert.NoError(t, err)
+
+	assert.True(t, acc.ModifyPosition(pos.ID, 19993.04, 20030.06, ts))
+	assert.Equal(t, 19993.0, pos.StopLoss)
+	assert.Equal(t, 20030.1, pos.TakeProfit)
+
+	// Checked against the price the position would close at now, rather than the entry
+	acc.CheckExits(types.Bar{Timestamp: ts.Add(time.Minute), Open: 20000, High: 20010, Low: 20000, Close: 20010})
+	assert.False(t, acc.ModifyPosition(pos.ID, 20006, 0, ts))
+	assert.Equal(t, 19993.0, pos.StopLoss, "A rejected stop is left where it was")
+	assert.Equal(t, 1, len(acc.Rejections()))
+	assert.Equal(t, STOP_LOSS_DISTANCE_MINIMUM_NOT_MET, acc.Rejections()[0].Reason)
+	assert.Equal(t, pos.ID, acc.Rejections()[0].Signal.PositionID)
+
+	assert.True(t, acc.ModifyPosition(pos.ID, 20005, 0, ts))
+	assert.Equal(t, 20005.0, pos.StopLoss)
+}
+
+func TestClosePosition_PartialCloseConformsToInstrumentLimits(t *testing.T) {
+	ts := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	bar := types.Bar{Timestamp: ts.Add(time.Minute), Close: 20010}
+	acc := NewAccount(10000)
+	acc.Instruments = map[string]types.Instrument{
+		"NAS100_USD": {Name: "NAS100_USD", DisplayPrecision: 1, TradeUnitsPrecision: 1, MinimumTradeSize: 0.5},
+	}
+	open := func(size float64) *Position {
+		pos, err := acc.OpenTrade(types.Signal{Instrument: "NAS100_USD", Type: types.OPEN, Action: types.BUY, Price: 20000, Size: size}, types.Bar{Timestamp: ts})
+		assert.NoError(t, err)
+		return pos
+	}
+
+	// Units closed round down
+	pos := open(3)
+	trade, ok := acc.ClosePosition(pos.ID, 0.33, 20010, bar, "STRATEGY_EXIT")
+	assert.True(t, ok)
+	assert.True(t, trade.Partial)
+	assert.Equal(t, 0.9, trade.Size)
+	assert.InDelta(t, 2.1, pos.Size, 1e-9)
+
+	// Leaving less than the minimum closes everything
+	trade, ok = acc.ClosePosition(pos.ID, 0.9, 20010, bar, "STRATEGY_EXIT")
+	assert.True(t, ok)
+	assert.False(t, trade.Partial)
+	assert.InDelta(t, 2.1, trade.Size, 1e-9)
+	assert.Equal(t, 0, acc.PositionCount())
+
+	// Closing less than the minimum is rejected
+	pos = open(3)
+	_, ok = acc.ClosePosition(pos.ID, 0.1, 20010, bar, "STRATEGY_EXIT")
+	assert.False(t, ok)
+	assert.Equal(t, 3.0, pos.Size)
+	assert.Equal(t, 1, len(acc.Rejections()))
+	assert.Equal(t, UNITS_MINIMUM_NOT_MET, acc.Rejections()[0].Reason)
+}
@@ -0,0 +1,113 @@
+package account
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/jwtly10/tradebook/internal/types"
+)
+
+const (
+	UNITS_MINIMUM_NOT_MET                  = "UNITS_MINIMUM_NOT_MET"
+	UNITS_LIMIT_EXCEEDED                   = "UNITS_LIMIT_EXCEEDED"
+	STOP_LOSS_DISTANCE_MINIMUM_NOT_MET     = "STOP_LOSS_DISTANCE_MINIMUM_NOT_MET"
+	TRAILING_STOP_DISTANCE_MINIMUM_NOT_MET = "TRAILING_STOP_DISTANCE_MINIMUM_NOT_MET"
+	BREAK_EVEN_STOP_LOSS_MISSING           = "BREAK_EVEN_STOP_LOSS_MISSING"
+)
+
+// conform rounds the signal's units down, and its prices to the nearest tick, the way the broker
+// would accept them. Returns an *OrderRejectedError if the signal still can't be traded.
+func (a *Account) conform(signal types.Signal, timestamp time.Time) (types.Signal, error) {
+	// Without a stop loss there's no initial risk to measure the trigger in
+	if be := signal.BreakEven; be != nil && be.Trigger == 0 && be.TriggerR > 0 && signal.SL == 0 {
+		return signal, a.reject(signal, timestamp, BREAK_EVEN_STOP_LOSS_MISSING,
+			fmt.Sprintf("break-even at %vR needs a stop loss", be.TriggerR))
+	}
+
+	instrument, ok := a.Instruments[signal.Instrument]
+	if !ok {
+		return signal, nil
+	}
+
+	requested := signal.Size
+	signal.Size = instrument.RoundUnits(signal.Size)
+	signal.Price = roundPrice(instrument, signal.Price)
+	signal.LimitPrice = roundPrice(instrument, signal.LimitPrice)
+	signal.SL = roundPrice(instrument, signal.SL)
+	signal.TP = roundPrice(instrument, signal.TP)
+
+	if signal.Size <= 0 || signal.Size < instrument.MinimumTradeSize {
+		return signal, a.reject(signal, timestamp, UNITS_MINIMUM_NOT_MET,
+			fmt.Sprintf("%v units rounds to %v, minimum is %v", requested, signal.Size, instrument.MinimumTradeSize))
+	}
+	if instrument.MaximumOrderUnits > 0 && signal.Size > instrument.MaximumOrderUnits {
+		return signal, a.reject(signal, timestamp, UNITS_LIMIT_EXCEEDED,
+			fmt.Sprintf("%v units exceeds maximum of %v", signal.Size, instrument.MaximumOrderUnits))
+	}
+
+	return signal, a.checkStops(instrument, signal, timestamp)
+}
+
+// checkStops rejects a signal whose stop loss is closer to its price than the instrument allows,
+// or whose fixed trailing stop distance is smaller than the minimum
+func (a *Account) checkStops(instrument types.Instrument, signal types.Signal, timestamp time.Time) error {
+	// Allow half a tick for float error in prices that are already rounded
+	tolerance := instrument.TickSize() / 2
+	if signal.SL != 0 && instrument.MinimumStopDistance > 0 {
+		if distance := math.Abs(signal.Price - signal.SL); distance < instrument.MinimumStopDistance-tolerance {
+			return a.reject(signal, timestamp, STOP_LOSS_DISTANCE_MINIMUM_NOT_MET,
+				fmt.Sprintf("stop loss %v from price, minimum is %v", distance, instrument.MinimumStopDistance))
+		}
+	}
+	if trailing := signal.Trailing; trailing != nil && trailing.Mode == types.TRAIL_FIXED && instrument.MinimumTrailingStopDistance > 0 {
+		if trailing.Distance < instrument.MinimumTrailingStopDistance-tolerance {
+			return a.reject(signal, timestamp, TRAILING_STOP_DISTANCE_MINIMUM_NOT_MET,
+				fmt.Sprintf("trailing stop distance %v, minimum is %v", trailing.Distance, instrument.MinimumTrailingStopDistance))
+		}
+	}
+
+	return nil
+}
+
+// conformModify rounds the new levels of a MODIFY signal to the nearest tick, checking the stop
+// loss distance from the price the position would currently close at
+func (a *Account) conformModify(pos *Position, signal types.Signal, timestamp time.Time) (types.Signal, error) {
+	instrument, ok := a.Instruments[pos.Instrument]
+	if !ok {
+		return signal, nil
+	}
+
+	signal.SL = roundPrice(instrument, signal.SL)
+	signal.TP = roundPrice(instrument, signal.TP)
+	return signal, a.checkStops(instrument, signal, timestamp)
+}
+
+// closeUnits returns the units a partial close takes, rounded down to the instrument's precision.
+// The whole position closes when what's left would be below the minimum trade size, and the close
+// is rejected when the units closed would be.
+func (a *Account) closeUnits(pos *Position, fraction, price float64, timestamp time.Time) (float64, error) {
+	instrument, ok := a.Instruments[pos.Instrument]
+	if !ok {
+		return pos.Size * fraction, nil
+	}
+
+	units := instrument.RoundUnits(pos.Size * fraction)
+	if units <= 0 || units < instrument.MinimumTradeSize {
+		signal := types.Signal{Instrument: pos.Instrument, Type: types.CLOSE, PositionID: pos.ID, Price: price, Size: units, Fraction: fraction}
+		return 0, a.reject(signal, timestamp, UNITS_MINIMUM_NOT_MET,
+			fmt.Sprintf("closing %v of %v units rounds to %v, minimum is %v", fraction, pos.Size, units, instrument.MinimumTradeSize))
+	}
+	if remaining := instrument.RoundUnits(pos.Size - units); remaining <= 0 || remaining < instrument.MinimumTradeSize {
+		return pos.Size, nil
+	}
+	return units, nil
+}
+
+// roundPrice rounds a price to the instrument's tick, leaving unset prices at zero
+func roundPrice(instrument types.Instrument, price float64) float64 {
+	if price == 0 {
+		return 0
+	}
+	return instrument.RoundPrice(price)
+}
@@ -0,0 +1,148 @@
+package account
+
+import (
+	"math/rand"
+
+	"github.com/jwtly10/tradebook/internal/types"
+)
+
+// Costs are the trading costs charged on a trade, priced by a CostModel in the instrument's quote currency.
+// A closed Trade's costs have been converted into the account currency along with its PnL.
+type Costs struct {
+	Spread     float64
+	Commission float64
+	Slippage   float64
+}
+
+func (c Costs) Total() float64 {
+	return c.Spread + c.Commission + c.Slippage
+}
+
+func (c Costs) Add(other Costs) Costs {
+	return Costs{
+		Spread:     c.Spread + other.Spread,
+		Commission: c.Commission + other.Commission,
+		Slippage:   c.Slippage + other.Slippage,
+	}
+}
+
+// Scale returns the costs multiplied by f, used to split costs when part of a position is closed
+func (c Costs) Scale(f float64) Costs {
+	return Costs{
+		Spread:     c.Spread * f,
+		Commission: c.Commission * f,
+		Slippage:   c.Slippage * f,
+	}
+}
+
+// Fill describes a single entry or exit to be priced by a CostModel
+type Fill struct {
+	Bar       types.Bar
+	Direction Direction // Direction of the position being opened or closed
+	Price     float64
+	Size      float64
+	Entry     bool
+}
+
+// CostModel prices the costs of a single fill. Entries and exits are priced separately,
+// so round trip costs should be split across both sides.
+type CostModel interface {
+	Costs(fill Fill) Costs
+}
+
+// CostModels combines several cost models by summing their costs
+type CostModels []CostModel
+
+func (m CostModels) Costs(fill Fill) Costs {
+	var total Costs
+	for _, model := range m {
+		total = total.Add(model.Costs(fill))
+	}
+	return total
+}
+
+// FixedSpread charges half of a fixed spread, in price, on each side of the trade
+type FixedSpread struct {
+	Spread float64
+}
+
+func (m FixedSpread) Costs(fill Fill) Costs {
+	return Costs{Spread: m.Spread / 2 * fill.Size}
+}
+
+// BarSpread charges half of the bar's spread on each side of the trade.
+// Fallback is used as the spread for bars that don't carry one.
+//
+// Bars with bid and ask prices are already filled on the correct side of the book,
+// so the spread is paid through the fill price and nothing is charged here.
+type BarSpread struct {
+	Fallback float64
+}
+
+func (m BarSpread) Costs(fill Fill) Costs {
+	if fill.Bar.HasQuotes() {
+		return Costs{}
+	}
+
+	spread := fill.Bar.Spread
+	if spread == 0 {
+		spread = m.Fallback
+	}
+	return Costs{Spread: spread / 2 * fill.Size}
+}
+
+// PerLotCommission charges a fixed commission per lot on each side of the trade.
+// LotSize is the number of units in a lot, eg 100000 for FX, and defaults to 1.
+type PerLotCommission struct {
+	PerLot  float64
+	LotSize float64
+}
+
+func (m PerLotCommission) Costs(fill Fill) Costs {
+	lotSize := m.LotSize
+	if lotSize == 0 {
+		lotSize = 1
+	}
+	return Costs{Commission: m.PerLot * fill.Size / lotSize}
+}
+
+// NotionalCommission charges a rate of the traded notional on each side of the trade, eg 0.0001 for 1bp
+type NotionalCommission struct {
+	Rate float64
+}
+
+func (m NotionalCommission) Costs(fill Fill) Costs {
+	return Costs{Commission: m.Rate * fill.Price * fill.Size}
+}
+
+// RandomSlippage charges a uniformly random slippage between 0 and Max, in price, on each side of the trade.
+// The zero value is seeded with 0, use NewRandomSlippage to choose the seed.
+type RandomSlippage struct {
+	Max float64
+
+	rng *rand.Rand
+}
+
+// NewRandomSlippage creates a seeded RandomSlippage, so backtests stay reproducible
+func NewRandomSlippage(max float64, seed int64) *RandomSlippage {
+	return &RandomSlippage{
+		Max: max,
+		rng: rand.New(rand.NewSource(seed)),
+	}
+}
+
+func (m *RandomSlippage) Costs(fill Fill) Costs {
+	if m.rng == nil {
+		m.rng = rand.New(rand.NewSource(0))
+	}
+	return Costs{Slippage: m.rng.Float64() * m.Max * fill.Size}
+}
+
+// VolatilitySlippage charges slippage as a multiple of the fill bar's range on each side of the trade
+type VolatilitySlippage struct {
+	Multiplier float64
+}
+
+func (m VolatilitySlippage) Costs(fill Fill) Costs {
+	return Costs{Slippage: (fill.Bar.High - fill.Bar.Low) * m.Multiplier * fill.Size}
+}
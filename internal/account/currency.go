@@ -0,0 +1,109 @@
+package account
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jwtly10/tradebook/internal/types"
+)
+
+// NO_CONVERSION_RATE rejects signals for instruments whose quote currency can't be converted into the home currency
+const NO_CONVERSION_RATE = "NO_CONVERSION_RATE"
+
+// RateSource gives the exchange rate between two currencies at a point in time, data.RateTable is one
+type RateSource interface {
+	Rate(from, to string, at time.Time) (float64, error)
+}
+
+// Conversion converts PnL, margin and exposure from each instrument's quote currency into the account's home currency
+type Conversion struct {
+	Home string // Account currency, eg GBP
+	// QuoteCurrencies per instrument, eg USD for NAS100_USD. Instruments missing are assumed to be quoted in Home
+	QuoteCurrencies map[string]string
+	Rates           RateSource
+}
+
+// Covers returns an error if any of the instrument's bars can't be converted into the home currency,
+// so a run fails up front rather than leaving PnL, margin and sizing unconverted
+func (c *Conversion) Covers(instrument string, bars []types.Bar) error {
+	quote, ok := c.QuoteCurrencies[instrument]
+	if !ok || quote == c.Home {
+		return nil
+	}
+	if c.Rates == nil {
+		return fmt.Errorf("no rates to convert %s from %s into %s", instrument, quote, c.Home)
+	}
+	for _, bar := range bars {
+		if _, err := c.Rates.Rate(quote, c.Home, bar.Timestamp); err != nil {
+			return fmt.Errorf("failed to convert %s from %s into %s: %w", instrument, quote, c.Home, err)
+		}
+	}
+	return nil
+}
+
+// homeRate returns the rate converting an amount in the instrument's quote currency into the home currency.
+// Returns an error if there are no rates for it, which engines check for up front with Covers.
+func (a *Account) homeRate(instrument string, at time.Time) (float64, error) {
+	if a.Conversion == nil {
+		return 1, nil
+	}
+
+	quote, ok := a.Conversion.QuoteCurrencies[instrument]
+	if !ok || quote == a.Conversion.Home {
+		return 1, nil
+	}
+	if a.Conversion.Rates == nil {
+		return 0, fmt.Errorf("no rates to convert %s from %s into %s", instrument, quote, a.Conversion.Home)
+	}
+
+	rate, err := a.Conversion.Rates.Rate(quote, a.Conversion.Home, at)
+	if err != nil {
+		return 0, fmt.Errorf("failed to convert %s from %s into %s: %w", instrument, quote, a.Conversion.Home, err)
+	}
+	return rate, nil
+}
+
+// positionRate returns the rate converting the position's quote currency into the home currency.
+// Positions can only be opened with a rate, so if it's no longer available the last one is used.
+func (a *Account) positionRate(pos *Position, at time.Time) float64 {
+	rate, err := a.homeRate(pos.Instrument, at)
+	if err != nil {
+		slog.Warn("No conversion rate, using the position's last rate", "id", pos.ID, "instrument", pos.Instrument, "rate", pos.rate, "error", err)
+		return pos.rate
+	}
+	pos.rate = rate
+	return rate
+}
+
+// QuoteRate returns the rate converting an amount in the home currency into the instrument's quote currency,
+// so amounts at risk in the account currency can be turned into a position size
+func (a *Account) QuoteRate(instrument string, at time.Time) (float64, error) {
+	rate, err := a.homeRate(instrument, at)
+	if err != nil {
+		return 0, err
+	}
+	return 1 / rate, nil
+}
+
+// CurrencySymbol returns the symbol amounts are printed with, amounts without a currency are assumed to be in pounds
+func CurrencySymbol(currency string) string {
+	switch currency {
+	case "", "GBP":
+		return "£"
+	case "USD":
+		return "$"
+	case "EUR":
+		return "€"
+	default:
+		return currency + " "
+	}
+}
+
+// Currency returns the account's home currency, empty when no conversion is configured
+func (a *Account) Currency() string {
+	if a.Conversion == nil {
+		return ""
+	}
+	return a.Conversion.Home
+}
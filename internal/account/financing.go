@@ -0,0 +1,125 @@
+package account
+
+import (
+	"log/slog"
+	"time"
+)
+
+// Financing charges, or pays, interest on positions held over the daily rollover
+type Financing struct {
+	// Rates per instrument, anything missing uses Default
+	Rates   map[string]FinancingRate
+	Default FinancingRate
+	// Location and RolloverHour set when the daily rollover happens, Oanda uses 17:00 New York
+	Location     *time.Location
+	RolloverHour int
+	// TripleDay is charged three days to cover the weekend for instruments whose rate doesn't set one,
+	// Wednesday for FX spot settlement
+	TripleDay  time.Weekday
+	DaysInYear float64
+}
+
+// FinancingRate is an annual rate applied to the notional value of a position, negative rates are charges
+type FinancingRate struct {
+	Long  float64
+	Short float64
+	// TripleDay is charged three days to cover the weekend, eg Friday for Oanda's index CFDs.
+	// Sunday (zero) is never a rollover, so it falls back to Financing.TripleDay.
+	TripleDay time.Weekday
+}
+
+// NewFinancing returns financing with the given rates, rolling over at 17:00 New York with Wednesday charged triple
+// unless an instrument's rate sets its own triple day
+func NewFinancing(rates map[string]FinancingRate) *Financing {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		// Without tzdata fall back to EST, which is only wrong by an hour during DST
+		loc = time.FixedZone("EST", -5*60*60)
+	}
+	return &Financing{
+		Rates:        rates,
+		Location:     loc,
+		RolloverHour: 17,
+		TripleDay:    time.Wednesday,
+		DaysInYear:   365,
+	}
+}
+
+// Rate returns the financing rate for the instrument
+func (f *Financing) Rate(instrument string) FinancingRate {
+	if rate, ok := f.Rates[instrument]; ok {
+		return rate
+	}
+	return f.Default
+}
+
+// charge returns the financing for holding the position over a single rollover
+func (f *Financing) charge(pos *Position, rollover time.Time) float64 {
+	rate := f.Rate(pos.Instrument)
+	annual := rate.Long
+	if pos.Direction == SHORT {
+		annual = rate.Short
+	}
+
+	tripleDay := rate.TripleDay
+	if tripleDay == time.Sunday {
+		tripleDay = f.TripleDay
+	}
+	days := 1.0
+	if rollover.In(f.location()).Weekday() == tripleDay {
+		days = 3
+	}
+	return pos.Size * pos.MarkPrice() * annual / f.daysInYear() * days
+}
+
+// rollovers returns the weekday rollovers after from, up to and including to
+func (f *Financing) rollovers(from, to time.Time) []time.Time {
+	loc := f.location()
+	local := from.In(loc)
+	rollover := time.Date(local.Year(), local.Month(), local.Day(), f.RolloverHour, 0, 0, 0, loc)
+	if !rollover.After(from) {
+		rollover = time.Date(local.Year(), local.Month(), local.Day()+1, f.RolloverHour, 0, 0, 0, loc)
+	}
+
+	var rollovers []time.Time
+	for !rollover.After(to) {
+		// Weekend rollovers are covered by TripleDay
+		if day := rollover.Weekday(); day != time.Saturday && day != time.Sunday {
+			rollovers = append(rollovers, rollover)
+		}
+		rollover = time.Date(rollover.Year(), rollover.Month(), rollover.Day()+1, f.RolloverHour, 0, 0, 0, loc)
+	}
+	return rollovers
+}
+
+func (f *Financing) location() *time.Location {
+	if f.Location == nil {
+		return time.UTC
+	}
+	return f.Location
+}
+
+func (f *Financing) daysInYear() float64 {
+	if f.DaysInYear == 0 {
+		return 365
+	}
+	return f.DaysInYear
+}
+
+// applyFinancing accrues financing for every rollover the position was held over before timestamp
+func (a *Account) applyFinancing(pos *Position, timestamp time.Time) {
+	if a.Financing == nil {
+		return
+	}
+
+	from := pos.financedTo
+	if from.IsZero() {
+		from = pos.OpenTime
+	}
+	for _, rollover := range a.Financing.rollovers(from, timestamp) {
+		charge := a.Financing.charge(pos, rollover)
+		pos.Financing += charge
+		slog.Debug("Applied financing", "position_id", pos.ID, "instrument", pos.Instrument, "amount", charge, "total", pos.Financing, "rollover", rollover)
+	}
+	pos.financedTo = timestamp
+}
@@ -0,0 +1,160 @@
+package account
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jwtly10/tradebook/internal/types"
+)
+
+const (
+	INSUFFICIENT_MARGIN = "INSUFFICIENT_MARGIN"
+
+	MARGIN_CLOSEOUT = "MARGIN_CLOSEOUT"
+)
+
+// Margin decides how much margin positions need and when the account is closed out
+type Margin struct {
+	// Rates per instrument, 0.05 is 20:1 leverage. Oanda publishes these per account instrument
+	Rates map[string]float64
+	// DefaultRate is used for instruments without a rate, zero requires no margin
+	DefaultRate float64
+	// CloseoutLevel closes every position once equity falls below this fraction of margin used, Oanda closes out at 0.5
+	CloseoutLevel float64
+}
+
+// Rate returns the margin rate for the instrument
+func (m *Margin) Rate(instrument string) float64 {
+	if rate, ok := m.Rates[instrument]; ok {
+		return rate
+	}
+	return m.DefaultRate
+}
+
+// OrderRejectedError is returned, and recorded on the account, when a signal can't be executed
+type OrderRejectedError struct {
+	Time    time.Time
+	Reason  string
+	Signal  types.Signal
+	Message string
+}
+
+func (e *OrderRejectedError) Error() string {
+	return fmt.Sprintf("order rejected: %s: %s", e.Reason, e.Message)
+}
+
+// reject records a rejected signal
+func (a *Account) reject(signal types.Signal, timestamp time.Time, reason, message string) error {
+	err := &OrderRejectedError{
+		Time:    timestamp,
+		Reason:  reason,
+		Signal:  signal,
+		Message: message,
+	}
+	slog.Warn("Order rejected", "instrument", signal.Instrument, "action", signal.Action, "size", signal.Size, "reason", reason, "message", message, "timestamp", timestamp)
+	a.rejections = append(a.rejections, *err)
+	return err
+}
+
+// Rejections returns every signal the account refused to execute
+func (a *Account) Rejections() []OrderRejectedError {
+	return a.rejections
+}
+
+// UnrealisedPnL returns the PnL of the position, in its quote currency, at the close of the last bar
+// it was checked against, including financing accrued so far but before exit costs
+func (p *Position) UnrealisedPnL() float64 {
+	return p.favourable(p.MarkPrice())*p.Size + p.Financing
+}
+
+// MarkPrice returns the price the position would close at on the last bar it was checked against
+func (p *Position) MarkPrice() float64 {
+	if p.mark.Timestamp.IsZero() {
+		return p.EntryPrice
+	}
+	return p.mark.Side(p.ExitAction()).Close
+}
+
+// markTime returns when the position was last marked to market
+func (p *Position) markTime() time.Time {
+	if p.mark.Timestamp.IsZero() {
+		return p.OpenTime
+	}
+	return p.mark.Timestamp
+}
+
+// Equity returns the balance plus the unrealised PnL of every open position, in the account currency
+func (a *Account) Equity() float64 {
+	equity := a.Balance
+	for _, pos := range a.openPositions {
+		equity += pos.UnrealisedPnL() * a.positionRate(pos, pos.markTime())
+	}
+	return equity
+}
+
+// Exposure returns the notional value of every open position at its current price, in the account currency
+func (a *Account) Exposure() float64 {
+	var exposure float64
+	for _, pos := range a.openPositions {
+		exposure += pos.Size * pos.MarkPrice() * a.positionRate(pos, pos.markTime())
+	}
+	return exposure
+}
+
+// MarginUsed returns the margin held against open positions at their current prices, in the account currency
+func (a *Account) MarginUsed() float64 {
+	var used float64
+	for _, pos := range a.openPositions {
+		used += a.marginFor(pos.Instrument, pos.Size, pos.MarkPrice()) * a.positionRate(pos, pos.markTime())
+	}
+	return used
+}
+
+// MarginAvailable returns the equity not already held as margin
+func (a *Account) MarginAvailable() float64 {
+	return a.Equity() - a.MarginUsed()
+}
+
+func (a *Account) marginFor(instrument string, size, price float64) float64 {
+	if a.Margin == nil {
+		return 0
+	}
+	return size * price * a.Margin.Rate(instrument)
+}
+
+// checkMargin returns an error if the account can't afford the margin for the signal, converted into the home currency at rate
+func (a *Account) checkMargin(signal types.Signal, rate float64, timestamp time.Time) error {
+	required := a.marginFor(signal.Instrument, signal.Size, signal.Price) * rate
+	if required == 0 {
+		return nil
+	}
+	if available := a.MarginAvailable(); required > available {
+		return a.reject(signal, timestamp, INSUFFICIENT_MARGIN, fmt.Sprintf("requires %.2f margin, %.2f available", required, available))
+	}
+	return nil
+}
+
+// CheckMarginCloseout closes every open position at its mark price when equity has fallen
+// below the closeout level. Positions are marked at the close of the last bar they were
+// checked against, so call this after CheckExits.
+func (a *Account) CheckMarginCloseout() []Trade {
+	if a.Margin == nil || a.Margin.CloseoutLevel <= 0 || len(a.openPositions) == 0 {
+		return nil
+	}
+
+	used := a.MarginUsed()
+	equity := a.Equity()
+	if used == 0 || equity >= used*a.Margin.CloseoutLevel {
+		return nil
+	}
+
+	slog.Warn("Margin closeout", "equity", equity, "margin_used", used, "closeout_level", a.Margin.CloseoutLevel, "positions", len(a.openPositions))
+
+	var trades []Trade
+	for _, pos := range a.openPositions {
+		trades = append(trades, a.closePosition(pos, pos.MarkPrice(), pos.mark, MARGIN_CLOSEOUT))
+	}
+	a.openPositions = []*Position{}
+	return trades
+}
@@ -0,0 +1,194 @@
+package account
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/jwtly10/tradebook/internal/types"
+)
+
+// Order is a working order held by the account until price trades through its level
+type Order struct {
+	ID          int
+	Instrument  string
+	CreatedTime time.Time
+	Type        types.OrderType
+	Direction   Direction
+	Price       float64 // Limit price for LIMIT, trigger price for STOP and STOP_LIMIT
+	LimitPrice  float64 // Only used by STOP_LIMIT once triggered
+	Expiry      time.Time
+	Triggered   bool
+	Size        float64
+	StopLoss    float64
+	TakeProfit  float64
+	Trailing    *types.TrailingStop
+	BreakEven   *types.BreakEven
+}
+
+// PlaceOrder stores a pending order from the given signal, it is only eligible to fill on bars after timestamp.
+// Returns an *OrderRejectedError if the signal breaks the instrument's limits.
+func (a *Account) PlaceOrder(signal types.Signal, timestamp time.Time) (*Order, error) {
+	signal, err := a.conform(signal, timestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Info("Placing order", "instrument", signal.Instrument, "type", signal.OrderType, "action", signal.Action, "id", a.nextOrderID, "price", signal.Price, "limit_price", signal.LimitPrice, "size", signal.Size, "expiry", signal.Expiry, "timestamp", timestamp)
+
+	order := &Order{
+		ID:          a.nextOrderID,
+		Instrument:  signal.Instrument,
+		CreatedTime: timestamp,
+		Type:        signal.OrderType,
+		Direction:   directionFromAction(signal.Action),
+		Price:       signal.Price,
+		LimitPrice:  signal.LimitPrice,
+		Expiry:      signal.Expiry,
+		Size:        signal.Size,
+		StopLoss:    signal.SL,
+		TakeProfit:  signal.TP,
+		Trailing:    signal.Trailing,
+		BreakEven:   signal.BreakEven,
+	}
+
+	a.nextOrderID++
+	a.pendingOrders = append(a.pendingOrders, order)
+
+	return order, nil
+}
+
+// CancelOrder removes a pending order, returning false if no order with the given ID exists
+func (a *Account) CancelOrder(id int) bool {
+	for i, order := range a.pendingOrders {
+		if order.ID == id {
+			slog.Info("Cancelled order", "id", id)
+			a.pendingOrders = append(a.pendingOrders[:i], a.pendingOrders[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// CheckOrders checks all pending orders against the given bar, opening a position for each order that fills.
+// Orders that have expired by the start of the bar are cancelled without being checked.
+func (a *Account) CheckOrders(bar types.Bar) []*Position {
+	return a.checkOrders(bar, anyInstrument)
+}
+
+// CheckOrdersFor is CheckOrders for only the orders in the given instrument
+func (a *Account) CheckOrdersFor(instrument string, bar types.Bar) []*Position {
+	return a.checkOrders(bar, instrumentIs(instrument))
+}
+
+func (a *Account) checkOrders(bar types.Bar, match func(instrument string) bool) []*Position {
+	var opened []*Position
+	remainingOrders := []*Order{}
+
+	for _, order := range a.pendingOrders {
+		if !match(order.Instrument) {
+			remainingOrders = append(remainingOrders, order)
+			continue
+		}
+
+		if !order.Expiry.IsZero() && !bar.Timestamp.Before(order.Expiry) {
+			slog.Info("Order expired", "id", order.ID, "expiry", order.Expiry, "timestamp", bar.Timestamp)
+			continue
+		}
+
+		price, filled := order.fill(bar)
+		if !filled {
+			remainingOrders = append(remainingOrders, order)
+			continue
+		}
+
+		slog.Debug("Order filled", "id", order.ID, "type", order.Type, "price", order.Price, "fill_price", price, "timestamp", bar.Timestamp)
+
+		pos, err := a.OpenTrade(types.Signal{
+			Instrument: order.Instrument,
+			Type:       types.OPEN,
+			Action:     order.Direction.entryAction(),
+			Price:      price,
+			TP:         order.TakeProfit,
+			SL:         order.StopLoss,
+			Size:       order.Size,
+			Trailing:   order.Trailing,
+			BreakEven:  order.BreakEven,
+		}, bar)
+		if err != nil {
+			// Rejected orders are recorded on the account and not retried
+			continue
+		}
+		opened = append(opened, pos)
+	}
+
+	a.pendingOrders = remainingOrders
+	return opened
+}
+
+func (a *Account) PendingOrders() []*Order {
+	return a.pendingOrders
+}
+
+// fill returns the price the order would be filled at on the given bar, if it fills at all.
+// Gaps through the order level fill at the bar open, which is better than the level for
+// limits and worse for stops. Buy orders trade on the ask and sell orders on the bid when
+// the bar carries quotes.
+func (o *Order) fill(bar types.Bar) (float64, bool) {
+	prices := bar.Side(o.Direction.entryAction())
+
+	switch o.Type {
+	case types.LIMIT:
+		return limitFill(o.Direction, o.Price, prices)
+	case types.STOP:
+		return stopFill(o.Direction, o.Price, prices)
+	case types.STOP_LIMIT:
+		start := prices.Open
+		if !o.Triggered {
+			triggerPrice, triggered := stopFill(o.Direction, o.Price, prices)
+			if !triggered {
+				return 0, false
+			}
+			slog.Debug("Stop limit order triggered", "id", o.ID, "price", o.Price, "timestamp", bar.Timestamp)
+			o.Triggered = true
+			start = triggerPrice
+		}
+
+		// The limit is marketable straight away if price is still inside it
+		if o.Direction == LONG && start <= o.LimitPrice {
+			return start, true
+		}
+		if o.Direction == SHORT && start >= o.LimitPrice {
+			return start, true
+		}
+		return limitFill(o.Direction, o.LimitPrice, prices)
+	default:
+		slog.Warn("Unsupported pending order type", "id", o.ID, "type", o.Type)
+		return 0, false
+	}
+}
+
+func limitFill(dir Direction, price float64, prices types.OHLC) (float64, bool) {
+	if dir == LONG {
+		if prices.Low <= price {
+			return min(prices.Open, price), true
+		}
+	} else {
+		if prices.High >= price {
+			return max(prices.Open, price), true
+		}
+	}
+	return 0, false
+}
+
+func stopFill(dir Direction, price float64, prices types.OHLC) (float64, bool) {
+	if dir == LONG {
+		if prices.High >= price {
+			return max(prices.Open, price), true
+		}
+	} else {
+		if prices.Low <= price {
+			return min(prices.Open, price), true
+		}
+	}
+	return 0, false
+}
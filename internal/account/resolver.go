@@ -0,0 +1,49 @@
+package account
+
+import "github.com/jwtly10/tradebook/internal/types"
+
+const (
+	RESOLUTION_PESSIMISTIC = "PESSIMISTIC"
+	RESOLUTION_OPTIMISTIC  = "OPTIMISTIC"
+	RESOLUTION_OHLC_PATH   = "OHLC_PATH"
+)
+
+// ExitResolver decides which of the stop loss or take profit was hit first on a bar that touched both
+type ExitResolver interface {
+	// Resolve reports whether the stop loss was hit before the take profit, and how that was decided
+	Resolve(pos *Position, bar types.Bar) (stopLossFirst bool, resolution string)
+}
+
+// PessimisticResolver always assumes the stop loss was hit first
+type PessimisticResolver struct{}
+
+func (PessimisticResolver) Resolve(pos *Position, bar types.Bar) (bool, string) {
+	return true, RESOLUTION_PESSIMISTIC
+}
+
+// OptimisticResolver always assumes the take profit was hit first
+type OptimisticResolver struct{}
+
+func (OptimisticResolver) Resolve(pos *Position, bar types.Bar) (bool, string) {
+	return false, RESOLUTION_OPTIMISTIC
+}
+
+// OHLCPathResolver assumes price travelled from the open to the nearer extreme, then to the far extreme.
+// If the open is equidistant from both extremes the stop loss is assumed to be hit first.
+type OHLCPathResolver struct{}
+
+func (OHLCPathResolver) Resolve(pos *Position, bar types.Bar) (bool, string) {
+	distToHigh := bar.High - bar.Open
+	distToLow := bar.Open - bar.Low
+
+	if distToHigh == distToLow {
+		return true, RESOLUTION_OHLC_PATH
+	}
+
+	highFirst := distToHigh < distToLow
+	if pos.Direction == LONG {
+		// Longs have their stop below, so visiting the low first means the stop was hit first
+		return !highFirst, RESOLUTION_OHLC_PATH
+	}
+	return highFirst, RESOLUTION_OHLC_PATH
+}
@@ -0,0 +1,99 @@
+package account
+
+import (
+	"log/slog"
+	"math"
+	"time"
+
+	"github.com/jwtly10/tradebook/internal/types"
+)
+
+const (
+	STOP_TRAILING   = "TRAILING_STOP"
+	STOP_BREAK_EVEN = "BREAK_EVEN"
+	STOP_MODIFIED   = "MODIFIED"
+)
+
+// StopMove records a change to a position's stop loss
+type StopMove struct {
+	Time   time.Time
+	From   float64
+	To     float64
+	Reason string
+}
+
+// manageStops applies the position's break-even and trailing stop rules once bar has closed,
+// so a moved stop is first checked against the following bar
+func (p *Position) manageStops(bar types.Bar) {
+	if p.Trailing == nil && p.BreakEven == nil {
+		return
+	}
+
+	prices := bar.Side(p.ExitAction())
+	if p.Direction == LONG {
+		p.best = max(p.best, prices.High)
+	} else {
+		p.best = min(p.best, prices.Low)
+	}
+
+	if p.BreakEven != nil && !p.breakEvenDone {
+		trigger := p.BreakEven.Trigger
+		if trigger == 0 {
+			trigger = p.BreakEven.TriggerR * math.Abs(p.EntryPrice-p.InitialStopLoss)
+		}
+		if trigger > 0 && p.favourable(p.best) >= trigger {
+			p.breakEvenDone = true
+			p.moveStop(p.EntryPrice+p.sign()*p.BreakEven.Offset, bar.Timestamp, STOP_BREAK_EVEN)
+		}
+	}
+
+	if p.Trailing != nil {
+		if distance := p.trailDistance(prices); distance > 0 {
+			p.moveStop(p.best-p.sign()*distance, bar.Timestamp, STOP_TRAILING)
+		}
+	}
+	p.prevClose = prices.Close
+}
+
+// trailDistance returns how far the stop should sit from the best price
+func (p *Position) trailDistance(prices types.OHLC) float64 {
+	switch p.Trailing.Mode {
+	case types.TRAIL_FIXED:
+		return p.Trailing.Distance
+	case types.TRAIL_PERCENT:
+		return p.best * p.Trailing.Distance / 100
+	case types.TRAIL_ATR:
+		if n := p.Trailing.ATRPeriod; n > 0 {
+			trueRange := max(prices.High-prices.Low, math.Abs(prices.High-p.prevClose), math.Abs(prices.Low-p.prevClose))
+			p.atr += (trueRange - p.atr) * 2 / float64(n+1)
+		}
+		return p.atr * p.Trailing.Distance
+	default:
+		slog.Warn("Unsupported trailing stop mode", "position_id", p.ID, "mode", p.Trailing.Mode)
+		return 0
+	}
+}
+
+// moveStop moves the stop loss to level if that tightens it, recording why
+func (p *Position) moveStop(level float64, timestamp time.Time, reason string) {
+	tighter := p.StopLoss == 0 || (p.Direction == LONG && level > p.StopLoss) || (p.Direction == SHORT && level < p.StopLoss)
+	if !tighter {
+		return
+	}
+
+	slog.Debug("Moving stop loss", "position_id", p.ID, "from", p.StopLoss, "to", level, "reason", reason, "timestamp", timestamp)
+	p.StopMoves = append(p.StopMoves, StopMove{Time: timestamp, From: p.StopLoss, To: level, Reason: reason})
+	p.StopLoss = level
+}
+
+// favourable returns how far price has moved in the position's favour from entry
+func (p *Position) favourable(price float64) float64 {
+	return (price - p.EntryPrice) * p.sign()
+}
+
+func (p *Position) sign() float64 {
+	if p.Direction == SHORT {
+		return -1
+	}
+	return 1
+}
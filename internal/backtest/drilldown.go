@@ -0,0 +1,102 @@
+package backtest
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/jwtly10/tradebook/internal/account"
+	"github.com/jwtly10/tradebook/internal/data"
+	"github.com/jwtly10/tradebook/internal/types"
+)
+
+const (
+	RESOLUTION_DRILL_DOWN = "DRILL_DOWN"
+)
+
+// DrillDownResolver resolves ambiguous bars by fetching lower timeframe bars covering the same period
+// and walking them in order to find which level was traded through first. Lower bars that ended before
+// the position opened are skipped, so a position opened part way through the bar only sees prices after it.
+//
+// If the lower timeframe data can't be loaded, doesn't touch either level, or the first lower bar
+// to touch a level touches both, the Fallback resolver is used.
+type DrillDownResolver struct {
+	Source      data.DataSource
+	Instrument  string
+	Granularity types.Granularity    // Granularity of the bars being backtested
+	Lower       types.Granularity    // Granularity to drill down to, eg M1 or S5
+	Price       types.PriceComponent // Should match the bars being backtested, so exits are checked on the same side
+	Fallback    account.ExitResolver
+
+	cache map[time.Time][]types.Bar
+}
+
+func NewDrillDownResolver(source data.DataSource, instrument string, granularity types.Granularity) *DrillDownResolver {
+	return &DrillDownResolver{
+		Source:      source,
+		Instrument:  instrument,
+		Granularity: granularity,
+		Lower:       types.M1,
+		Fallback:    account.OHLCPathResolver{},
+		cache:       make(map[time.Time][]types.Bar),
+	}
+}
+
+func (r *DrillDownResolver) Resolve(pos *account.Position, bar types.Bar) (bool, string) {
+	lowerBars, err := r.lowerBars(bar)
+	if err != nil {
+		slog.Warn("Failed to drill down into ambiguous bar, using fallback", "timestamp", bar.Timestamp, "error", err)
+		return r.Fallback.Resolve(pos, bar)
+	}
+	lowerPeriod, err := r.Lower.ToDuration()
+	if err != nil {
+		slog.Warn("Failed to drill down into ambiguous bar, using fallback", "timestamp", bar.Timestamp, "error", err)
+		return r.Fallback.Resolve(pos, bar)
+	}
+
+	for _, lowerBar := range lowerBars {
+		if !lowerBar.Timestamp.Add(lowerPeriod).After(pos.OpenTime) {
+			continue
+		}
+		stopLossHit, takeProfitHit := pos.ExitsHit(lowerBar)
+		switch {
+		case stopLossHit && takeProfitHit:
+			slog.Debug("Lower timeframe bar is also ambiguous, using fallback", "timestamp", lowerBar.Timestamp, "granularity", r.Lower)
+			stopLossFirst, resolution := r.Fallback.Resolve(pos, lowerBar)
+			return stopLossFirst, RESOLUTION_DRILL_DOWN + "_" + resolution
+		case stopLossHit:
+			return true, RESOLUTION_DRILL_DOWN
+		case takeProfitHit:
+			return false, RESOLUTION_DRILL_DOWN
+		}
+	}
+
+	slog.Warn("Lower timeframe bars did not touch either level, using fallback", "timestamp", bar.Timestamp, "granularity", r.Lower, "count", len(lowerBars))
+	return r.Fallback.Resolve(pos, bar)
+}
+
+// lowerBars returns the lower timeframe bars that make up the given bar, fetching them at most once
+func (r *DrillDownResolver) lowerBars(bar types.Bar) ([]types.Bar, error) {
+	if cached, ok := r.cache[bar.Timestamp]; ok {
+		return cached, nil
+	}
+
+	period, err := r.Granularity.ToDuration()
+	if err != nil {
+		return nil, err
+	}
+
+	lowerBars, err := r.Source.FetchBars(context.Background(), data.Request{
+		Instrument:  r.Instrument,
+		Granularity: r.Lower,
+		Price:       r.Price,
+		From:        bar.Timestamp,
+		To:          bar.Timestamp.Add(period),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	r.cache[bar.Timestamp] = lowerBars
+	return lowerBars, nil
+}
@@ -0,0 +1,82 @@
+package backtest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jwtly10/tradebook/internal/account"
+	"github.com/jwtly10/tradebook/internal/data"
+	"github.com/jwtly10/tradebook/internal/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type stubSource struct {
+	*data.MemorySource
+	err   error
+	calls int
+}
+
+func (s *stubSource) FetchBars(ctx context.Context, req data.Request) ([]types.Bar, error) {
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.MemorySource.FetchBars(ctx, req)
+}
+
+func TestDrillDownResolver_UsesLowerTimeframeOrder(t *testing.T) {
+	bar := types.Bar{Timestamp: TimeFromString("2024-01-01T00:00:00Z"), Open: 100, High: 111, Low: 89, Close: 100}
+	pos := &account.Position{Direction: account.LONG, EntryPrice: 100, StopLoss: 95, TakeProfit: 110}
+
+	source := &stubSource{MemorySource: data.NewMemorySource()}
+	source.Add("NAS100_USD", types.M1, []types.Bar{
+		// Bar before the period should be ignored even though it touches the stop
+		{Timestamp: TimeFromString("2023-12-31T23:59:00Z"), Open: 100, High: 100, Low: 90, Close: 100},
+		{Timestamp: TimeFromString("2024-01-01T00:00:00Z"), Open: 100, High: 103, Low: 99, Close: 102},
+		{Timestamp: TimeFromString("2024-01-01T00:01:00Z"), Open: 102, High: 111, Low: 101, Close: 109},
+		{Timestamp: TimeFromString("2024-01-01T00:02:00Z"), Open: 109, High: 109, Low: 89, Close: 100},
+	})
+
+	resolver := NewDrillDownResolver(source, "NAS100_USD", types.M15)
+
+	stopLossFirst, resolution := resolver.Resolve(pos, bar)
+	assert.False(t, stopLossFirst, "Take profit was hit first on the lower timeframe")
+	assert.Equal(t, RESOLUTION_DRILL_DOWN, resolution)
+
+	// Lower bars are cached per bar
+	resolver.Resolve(pos, bar)
+	assert.Equal(t, 1, source.calls)
+}
+
+func TestDrillDownResolver_SkipsBarsBeforeAnIntrabarEntry(t *testing.T) {
+	bar := types.Bar{Timestamp: TimeFromString("2024-01-01T00:00:00Z"), Open: 100, High: 111, Low: 89, Close: 100}
+	// Opened by a pending order filling during the 00:01 lower bar
+	pos := &account.Position{Direction: account.LONG, OpenTime: TimeFromString("2024-01-01T00:01:30Z"), EntryPrice: 100, StopLoss: 95, TakeProfit: 110}
+
+	source := &stubSource{MemorySource: data.NewMemorySource()}
+	source.Add("NAS100_USD", types.M1, []types.Bar{
+		// Touches the stop before the position existed
+		{Timestamp: TimeFromString("2024-01-01T00:00:00Z"), Open: 100, High: 101, Low: 89, Close: 96},
+		{Timestamp: TimeFromString("2024-01-01T00:01:00Z"), Open: 96, High: 103, Low: 96, Close: 102},
+		{Timestamp: TimeFromString("2024-01-01T00:02:00Z"), Open: 102, High: 111, Low: 101, Close: 109},
+	})
+
+	resolver := NewDrillDownResolver(source, "NAS100_USD", types.M15)
+
+	stopLossFirst, resolution := resolver.Resolve(pos, bar)
+	assert.False(t, stopLossFirst, "Only the take profit was hit after the entry")
+	assert.Equal(t, RESOLUTION_DRILL_DOWN, resolution)
+}
+
+func TestDrillDownResolver_FallsBackWhenFetchFails(t *testing.T) {
+	bar := types.Bar{Timestamp: TimeFromString("2024-01-01T00:00:00Z"), Open: 100, High: 111, Low: 89, Close: 100}
+	pos := &account.Position{Direction: account.LONG, EntryPrice: 100, StopLoss: 95, TakeProfit: 110}
+
+	resolver := NewDrillDownResolver(&stubSource{MemorySource: data.NewMemorySource(), err: errors.New("offline")}, "NAS100_USD", types.M15)
+	resolver.Fallback = account.PessimisticResolver{}
+
+	stopLossFirst, resolution := resolver.Resolve(pos, bar)
+	assert.True(t, stopLossFirst)
+	assert.Equal(t, account.RESOLUTION_PESSIMISTIC, resolution)
+}
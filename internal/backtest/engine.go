@@ -1,54 +1,172 @@
 package backtest
 
 import (
+	"errors"
+	"fmt"
 	"log/slog"
+	"time"
 
 	"github.com/jwtly10/tradebook/internal/account"
+	"github.com/jwtly10/tradebook/internal/data"
 	"github.com/jwtly10/tradebook/internal/strategy"
 	"github.com/jwtly10/tradebook/internal/types"
 )
 
 const (
-	OPEN_TRADE = "OPEN_TRADE"
+	OPEN_TRADE   = "OPEN_TRADE"
+	CLOSE_TRADE  = "CLOSE_TRADE"
+	MODIFY_TRADE = "MODIFY_TRADE"
+
+	STRATEGY_EXIT = "STRATEGY_EXIT"
 )
 
+var ErrDirtyData = errors.New("bar data failed validation")
+
 type Engine struct {
-	Bars           []types.Bar
+	Bars []types.Bar
+	// Instrument the bars are for, set on signals that don't name one so per-instrument margin rates apply
+	Instrument string
+	// FillModel prices market signals on the bar after they were generated, defaults to NextOpenFill
+	FillModel FillModel
+	// ExitResolver decides bars that touch both SL and TP, defaults to the account's pessimistic resolver
+	ExitResolver account.ExitResolver
+	// CostModel charges spread, commission and slippage on every fill, nil means trading is free
+	CostModel account.CostModel
+	// Margin rejects trades the account can't afford and closes out positions, nil disables margin
+	Margin *account.Margin
+	// Financing charges positions held over the daily rollover, nil means holding is free
+	Financing *account.Financing
+	// Conversion converts PnL into the account currency, nil treats every instrument as quoted in it
+	Conversion *account.Conversion
+	// Instruments round units and prices and enforce trade size and stop distance limits, rejected signals are recorded on the results
+	Instruments map[string]types.Instrument
+	// Validator checks the bars before running, the report is attached to the results
+	Validator *data.Validator
+	// StrictData refuses to run if the Validator finds any issues or gaps
+	StrictData bool
+	// Granularity of Bars, required to run multi-timeframe strategies
+	Granularity types.Granularity
+	// Timeframes are pre-fetched higher timeframe bars, any a strategy wants that are missing are resampled from Bars
+	Timeframes map[types.Granularity][]types.Bar
+	// Alignment decides where resampled bars start and when higher timeframe bars close, defaults to Oanda's
+	Alignment data.Alignment
+
 	initialBalance float64
 }
 
 func NewEngine(bars []types.Bar, initialBalance float64) *Engine {
 	return &Engine{
 		Bars:           bars,
+		FillModel:      NextOpenFill{},
+		Alignment:      data.DefaultAlignment(),
 		initialBalance: initialBalance,
 	}
 }
 
-func (e *Engine) Run(strategy strategy.Strategy) *Results {
+// Run backtests the strategy over the engine's bars, returning an error only if the bars
+// can't be validated, or fail validation in strict mode
+func (e *Engine) Run(strategy strategy.Strategy) (*Results, error) {
+	var report *data.Report
+	if e.Validator != nil {
+		var err error
+		report, err = e.Validator.Validate(e.Bars)
+		if err != nil {
+			return nil, fmt.Errorf("failed to validate bars: %w", err)
+		}
+
+		if !report.Clean() {
+			if e.StrictData {
+				return nil, fmt.Errorf("%w: %s", ErrDirtyData, report.Summary())
+			}
+			slog.Warn("Bar data has quality issues", "summary", report.Summary())
+		}
+	}
+
+	if e.Conversion != nil {
+		if err := e.Conversion.Covers(e.Instrument, e.Bars); err != nil {
+			return nil, err
+		}
+	}
+
+	mtf := multiTimeframe(strategy)
+	var aligners map[types.Granularity]*data.Aligner
+	if mtf != nil {
+		var err error
+		aligners, err = e.aligners(mtf.Timeframes())
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	acc := account.NewAccount(e.initialBalance)
+	if e.ExitResolver != nil {
+		acc.Resolver = e.ExitResolver
+	}
+	acc.CostModel = e.CostModel
+	acc.Margin = e.Margin
+	acc.Financing = e.Financing
+	acc.Conversion = e.Conversion
+	acc.Instruments = e.Instruments
 	results := &Results{
-		InitialBalance: 10000,
+		Currency:       acc.Currency(),
+		InitialBalance: e.initialBalance,
 		Trades:         []account.Trade{},
+		DataQuality:    report,
 	}
 
 	slog.Debug("Starting backtest", "initial_balance", e.initialBalance, "total_bars", len(e.Bars))
 
 	for i, bar := range e.Bars {
 		slog.Debug("Processing bar", "index", i, "timestamp", bar.Timestamp, "open", bar.Open, "high", bar.High, "low", bar.Low, "close", bar.Close)
+		// Pending orders fill before exits are checked, so a position filled on this bar can also exit on it
+		acc.CheckOrders(bar)
 		closedTrades := acc.CheckExits(bar)
 		results.Trades = append(results.Trades, closedTrades...)
+		results.Trades = append(results.Trades, acc.CheckMarginCloseout()...)
+		// Positions are now marked at this bar's close
+		results.EquityCurve = append(results.EquityCurve, equityPoint(bar.Timestamp, acc))
 
 		// Strategy logic is done based on a completed full bar of data
-		signals := strategy.OnBar(e.Bars, i, acc)
+		var signals []types.Signal
+		if mtf != nil {
+			timeframes, err := e.closedTimeframes(aligners, bar)
+			if err != nil {
+				return nil, err
+			}
+			signals = mtf.OnBarWithTimeframes(e.Bars, i, timeframes, acc)
+		} else {
+			signals = strategy.OnBar(e.Bars, i, acc)
+		}
 
 		for _, signal := range signals {
-			if signal.Type == OPEN_TRADE {
+			if signal.Instrument == "" {
+				signal.Instrument = e.Instrument
+			}
+
+			switch signal.Type {
+			case OPEN_TRADE:
+				if signal.IsPending() {
+					// Working orders rest on the account and are only filled by later bars
+					acc.PlaceOrder(signal, bar.Timestamp)
+					continue
+				}
+
 				if i < len(e.Bars)-1 {
 					// Prefer to open the trade on the 'open', rather than the close of the bar
 					// This is just a logistical point for marrying up timestamps to TV
 					nextBar := e.Bars[i+1]
-					acc.OpenTrade(signal, nextBar.Timestamp)
+					fillPrice := e.FillModel.FillPrice(signal, nextBar)
+					slog.Debug("Filling market signal", "signal_price", signal.Price, "fill_price", fillPrice, "timestamp", nextBar.Timestamp)
+					acc.OpenTrade(Reanchor(signal, fillPrice), nextBar)
 				}
+			case CLOSE_TRADE:
+				// Exits are market orders too, so fill on the next bar like entries
+				if i < len(e.Bars)-1 {
+					results.Trades = append(results.Trades, CloseTargets(acc, e.FillModel, signal, e.Bars[i+1])...)
+				}
+			case MODIFY_TRADE:
+				// Takes effect from the next bar's exit checks
+				ModifyTargets(acc, signal, bar.Timestamp)
 			}
 		}
 	}
@@ -58,9 +176,85 @@ func (e *Engine) Run(strategy strategy.Strategy) *Results {
 		lastBar := e.Bars[len(e.Bars)-1]
 		remainingTrades := acc.CloseAll(lastBar)
 		results.Trades = append(results.Trades, remainingTrades...)
+		// The last point reflects the forced close, including its costs
+		results.EquityCurve[len(results.EquityCurve)-1] = equityPoint(lastBar.Timestamp, acc)
 	}
 
 	results.FinalBalance = acc.Balance
+	results.Rejections = acc.Rejections()
 
-	return results
+	return results, nil
+}
+
+// CloseTargets exits the positions a CLOSE signal applies to, each filled on the exit side of the next bar
+func CloseTargets(acc *account.Account, fillModel FillModel, signal types.Signal, next types.Bar) []account.Trade {
+	var trades []account.Trade
+	for _, pos := range acc.Targets(signal) {
+		exit := signal
+		exit.Action = pos.ExitAction()
+		if exit.Price == 0 {
+			// Nothing to price a SignalPriceFill from, so use the next open
+			exit.Price = next.Side(exit.Action).Open
+		}
+
+		fillPrice := fillModel.FillPrice(exit, next)
+		slog.Debug("Filling close signal", "position_id", pos.ID, "fraction", signal.Fraction, "fill_price", fillPrice, "timestamp", next.Timestamp)
+		if trade, ok := acc.ClosePosition(pos.ID, signal.Fraction, fillPrice, next, STRATEGY_EXIT); ok {
+			trades = append(trades, trade)
+		}
+	}
+	return trades
+}
+
+// ModifyTargets moves the SL/TP of the positions a MODIFY signal applies to
+func ModifyTargets(acc *account.Account, signal types.Signal, timestamp time.Time) {
+	for _, pos := range acc.Targets(signal) {
+		acc.ModifyPosition(pos.ID, signal.SL, signal.TP, timestamp)
+	}
+}
+
+func multiTimeframe(s strategy.Strategy) strategy.MultiTimeframeStrategy {
+	mtf, _ := s.(strategy.MultiTimeframeStrategy)
+	return mtf
+}
+
+// aligners prepares each higher timeframe a strategy asked for, using pre-fetched bars where we have them
+func (e *Engine) aligners(timeframes []types.Granularity) (map[types.Granularity]*data.Aligner, error) {
+	if e.Granularity == "" {
+		return nil, errors.New("engine granularity must be set to run multi-timeframe strategies")
+	}
+
+	aligners := make(map[types.Granularity]*data.Aligner, len(timeframes))
+	for _, g := range timeframes {
+		bars, ok := e.Timeframes[g]
+		if !ok {
+			resampled, err := data.Resample(e.Bars, e.Granularity, g, e.Alignment)
+			if err != nil {
+				return nil, fmt.Errorf("failed to resample %s bars to %s: %w", e.Granularity, g, err)
+			}
+			bars = resampled
+			slog.Debug("Resampled higher timeframe", "granularity", g, "bars", len(bars))
+		}
+
+		aligner, err := data.NewAligner(data.Complete(bars), g, e.Alignment)
+		if err != nil {
+			return nil, fmt.Errorf("failed to align %s bars: %w", g, err)
+		}
+		aligners[g] = aligner
+	}
+	return aligners, nil
+}
+
+// closedTimeframes returns the higher timeframe bars the strategy is allowed to see once bar has closed
+func (e *Engine) closedTimeframes(aligners map[types.Granularity]*data.Aligner, bar types.Bar) (strategy.Timeframes, error) {
+	closeTime, err := e.Alignment.End(e.Granularity, bar.Timestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	timeframes := make(strategy.Timeframes, len(aligners))
+	for g, aligner := range aligners {
+		timeframes[g] = aligner.ClosedBy(closeTime)
+	}
+	return timeframes, nil
 }
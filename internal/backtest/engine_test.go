@@ -5,6 +5,8 @@ import (
 	"time"
 
 	"github.com/jwtly10/tradebook/internal/account"
+	"github.com/jwtly10/tradebook/internal/data"
+	"github.com/jwtly10/tradebook/internal/strategy"
 	"github.com/jwtly10/tradebook/internal/types"
 	"github.com/stretchr/testify/assert"
 )
@@ -30,9 +32,11 @@ func TestEngine_RunAndCanOpenAndCloseTrades(t *testing.T) {
 	}
 
 	engine := NewEngine(bars, 10000.0)
+	engine.FillModel = SignalPriceFill{}
 	strategy := &TestStrategy{}
 
-	results := engine.Run(strategy)
+	results, err := engine.Run(strategy)
+	assert.NoError(t, err)
 
 	// Trade 1:
 	// Open BUY at 100 with quantity 1. Closed at 105 = +5 profit
@@ -52,6 +56,287 @@ func TestEngine_RunAndCanOpenAndCloseTrades(t *testing.T) {
 	assert.Equal(t, 2, len(results.Trades), "There should be 2 closed trades")
 }
 
+func TestEngine_RunFillsPendingOrdersOnlyWhenPriceTradesThrough(t *testing.T) {
+	bars := []types.Bar{
+		{Timestamp: TimeFromString("2024-01-01T00:00:00Z"), Open: 100, High: 101, Low: 99, Close: 100},
+		{Timestamp: TimeFromString("2024-01-01T00:15:00Z"), Open: 100, High: 102, Low: 98, Close: 101},
+		{Timestamp: TimeFromString("2024-01-01T00:30:00Z"), Open: 101, High: 103, Low: 96, Close: 97},
+		{Timestamp: TimeFromString("2024-01-01T00:45:00Z"), Open: 97, High: 108, Low: 97, Close: 107},
+		{Timestamp: TimeFromString("2024-01-01T01:00:00Z"), Open: 107, High: 107, Low: 106, Close: 106},
+	}
+
+	strategy := &signalStrategy{signals: map[int][]types.Signal{
+		0: {
+			// Buy limit at 97 is only reached by the third bar
+			{Type: OPEN_TRADE, Action: types.BUY, OrderType: types.LIMIT, Price: 97, TP: 200, SL: 50, Size: 1},
+			// Sell stop at 95 is never reached
+			{Type: OPEN_TRADE, Action: types.SELL, OrderType: types.STOP, Price: 95, TP: 50, SL: 200, Size: 1},
+			// Buy stop at 105 would fill on the fourth bar, but expires before then
+			{Type: OPEN_TRADE, Action: types.BUY, OrderType: types.STOP, Price: 105, TP: 200, SL: 50, Size: 1, Expiry: TimeFromString("2024-01-01T00:45:00Z")},
+		},
+	}}
+
+	results, err := engine(bars).Run(strategy)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 1, len(results.Trades), "Only the buy limit should fill")
+	assert.Equal(t, TimeFromString("2024-01-01T00:30:00Z"), results.Trades[0].EntryTime)
+	assert.Equal(t, float64(97), results.Trades[0].EntryPrice)
+	assert.Equal(t, float64(106), results.Trades[0].ExitPrice)
+}
+
+func TestEngine_RunFillsStopOrdersAtOpenOnGap(t *testing.T) {
+	bars := []types.Bar{
+		{Timestamp: TimeFromString("2024-01-01T00:00:00Z"), Open: 100, High: 101, Low: 99, Close: 100},
+		{Timestamp: TimeFromString("2024-01-01T00:15:00Z"), Open: 104, High: 106, Low: 103, Close: 105},
+		{Timestamp: TimeFromString("2024-01-01T00:30:00Z"), Open: 105, High: 105, Low: 105, Close: 105},
+	}
+
+	strategy := &signalStrategy{signals: map[int][]types.Signal{
+		0: {
+			{Type: OPEN_TRADE, Action: types.BUY, OrderType: types.STOP, Price: 102, TP: 200, SL: 50, Size: 1},
+			// Triggers at 102 but the limit of 103 can only be filled when price trades back down
+			{Type: OPEN_TRADE, Action: types.BUY, OrderType: types.STOP_LIMIT, Price: 102, LimitPrice: 103, TP: 200, SL: 50, Size: 1},
+		},
+	}}
+
+	results, err := engine(bars).Run(strategy)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 2, len(results.Trades))
+	assert.Equal(t, float64(104), results.Trades[0].EntryPrice, "Stop order gapped through should fill at the open")
+	assert.Equal(t, float64(103), results.Trades[1].EntryPrice, "Stop limit should fill at its limit")
+}
+
+func TestEngine_RunFillsAtNextOpenAndReanchorsStops(t *testing.T) {
+	bars := []types.Bar{
+		{Timestamp: TimeFromString("2024-01-01T00:00:00Z"), Open: 100, High: 101, Low: 99, Close: 100},
+		{Timestamp: TimeFromString("2024-01-01T00:15:00Z"), Open: 110, High: 112, Low: 109, Close: 111},
+		{Timestamp: TimeFromString("2024-01-01T00:30:00Z"), Open: 111, High: 116, Low: 111, Close: 115},
+	}
+
+	strategy := &signalStrategy{signals: map[int][]types.Signal{
+		0: {{Type: OPEN_TRADE, Action: types.BUY, Price: 100, TP: 105, SL: 95, Size: 1}},
+	}}
+
+	results, err := engine(bars).Run(strategy)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 1, len(results.Trades))
+	assert.Equal(t, float64(110), results.Trades[0].EntryPrice, "Entry should be the next bar open, not the signal price")
+	assert.Equal(t, float64(115), results.Trades[0].TakeProfit, "TP should keep its distance from the actual fill")
+	assert.Equal(t, float64(105), results.Trades[0].StopLoss, "SL should keep its distance from the actual fill")
+	assert.Equal(t, "TAKE_PROFIT", results.Trades[0].ExitReason)
+}
+
+func TestFillModels(t *testing.T) {
+	next := types.Bar{Open: 100, High: 110, Low: 90, Close: 104}
+	buy := types.Signal{Action: types.BUY, Price: 99}
+	sell := types.Signal{Action: types.SELL, Price: 99}
+
+	assert.Equal(t, float64(99), SignalPriceFill{}.FillPrice(buy, next))
+	assert.Equal(t, float64(100), NextOpenFill{}.FillPrice(buy, next))
+	assert.Equal(t, float64(100.5), NextOpenSlippageFill{Slippage: 0.5}.FillPrice(buy, next))
+	assert.Equal(t, float64(99.5), NextOpenSlippageFill{Slippage: 0.5}.FillPrice(sell, next))
+	assert.Equal(t, float64(304)/3, NextBarVWAPFill{}.FillPrice(buy, next))
+}
+
+func TestEngine_RunRefusesDirtyDataInStrictMode(t *testing.T) {
+	bars := []types.Bar{
+		{Timestamp: TimeFromString("2024-01-01T00:00:00Z"), Open: 100, High: 101, Low: 99, Close: 100, Volume: 10},
+		{Timestamp: TimeFromString("2024-01-01T00:00:00Z"), Open: 100, High: 101, Low: 99, Close: 100, Volume: 10},
+	}
+
+	e := engine(bars)
+	e.Validator = data.NewValidator(types.M15, nil)
+
+	results, err := e.Run(&signalStrategy{})
+	assert.NoError(t, err, "Dirty data is only reported outside of strict mode")
+	assert.Equal(t, 1, results.DataQuality.Count(data.DUPLICATE_TIMESTAMP))
+
+	e.StrictData = true
+	_, err = e.Run(&signalStrategy{})
+	assert.ErrorIs(t, err, ErrDirtyData)
+}
+
+func TestEngine_RunRefusesBarsWithoutConversionRates(t *testing.T) {
+	bars := []types.Bar{
+		{Timestamp: TimeFromString("2024-01-01T00:00:00Z"), Open: 100, High: 101, Low: 99, Close: 100},
+		{Timestamp: TimeFromString("2024-01-01T00:15:00Z"), Open: 100, High: 101, Low: 99, Close: 100},
+	}
+	// Rates only start at the second bar
+	rates := data.NewRateTable()
+	rates.Add("GBP_USD", bars[1:])
+
+	e := engine(bars)
+	e.Instrument = "NAS100_USD"
+	e.Conversion = &account.Conversion{Home: "GBP", QuoteCurrencies: map[string]string{"NAS100_USD": "USD"}, Rates: rates}
+
+	_, err := e.Run(&signalStrategy{})
+	assert.ErrorContains(t, err, "failed to convert NAS100_USD from USD into GBP")
+
+	rates.Add("GBP_USD", bars)
+	_, err = e.Run(&signalStrategy{})
+	assert.NoError(t, err)
+}
+
+func TestEngine_RunRefusesBarsAfterConversionRatesEnd(t *testing.T) {
+	var bars []types.Bar
+	for ts := TimeFromString("2024-01-01T00:00:00Z"); ts.Before(TimeFromString("2024-01-01T03:00:00Z")); ts = ts.Add(15 * time.Minute) {
+		bars = append(bars, types.Bar{Timestamp: ts, Open: 100, High: 101, Low: 99, Close: 100})
+	}
+	// Rates stop after the first hour
+	rates := data.NewRateTable()
+	rates.Add("GBP_USD", bars[:4])
+
+	e := engine(bars)
+	e.Instrument = "NAS100_USD"
+	e.Conversion = &account.Conversion{Home: "GBP", QuoteCurrencies: map[string]string{"NAS100_USD": "USD"}, Rates: rates}
+
+	_, err := e.Run(&signalStrategy{})
+	assert.ErrorContains(t, err, "failed to convert NAS100_USD from USD into GBP")
+}
+
+func TestEngine_RunExecutesPositionManagementSignals(t *testing.T) {
+	bars := []types.Bar{
+		{Timestamp: TimeFromString("2024-01-01T00:00:00Z"), Open: 100, High: 101, Low: 99, Close: 100},
+		{Timestamp: TimeFromString("2024-01-01T00:15:00Z"), Open: 100, High: 104, Low: 100, Close: 103},
+		{Timestamp: TimeFromString("2024-01-01T00:30:00Z"), Open: 104, High: 105, Low: 103, Close: 104},
+		{Timestamp: TimeFromString("2024-01-01T00:45:00Z"), Open: 106, High: 106, Low: 101, Close: 102},
+		{Timestamp: TimeFromString("2024-01-01T01:00:00Z"), Open: 102, High: 103, Low: 101, Close: 102},
+	}
+
+	strategy := &signalStrategy{signals: map[int][]types.Signal{
+		0: {
+			{Type: OPEN_TRADE, Action: types.BUY, Price: 100, TP: 200, SL: 50, Size: 2},
+			{Type: OPEN_TRADE, Action: types.SELL, Price: 100, TP: 50, SL: 200, Size: 1},
+		},
+		// Take half the long off at the next open, and close every short
+		1: {
+			{Type: CLOSE_TRADE, PositionID: 1, Fraction: 0.5, Price: 103},
+			{Type: CLOSE_TRADE, Action: types.SELL, Price: 103},
+		},
+		// Stop moved up to 102, hit by the 00:45 bar
+		2: {{Type: MODIFY_TRADE, PositionID: 1, SL: 102}},
+	}}
+
+	results, err := engine(bars).Run(strategy)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(results.Trades))
+
+	partial := results.Trades[0]
+	assert.Equal(t, 1, partial.ID)
+	assert.True(t, partial.Partial)
+	assert.Equal(t, STRATEGY_EXIT, partial.ExitReason)
+	assert.Equal(t, float64(104), partial.ExitPrice)
+	assert.Equal(t, float64(4), partial.PnL)
+
+	short := results.Trades[1]
+	assert.Equal(t, 2, short.ID)
+	assert.Equal(t, STRATEGY_EXIT, short.ExitReason)
+	assert.Equal(t, float64(-4), short.PnL)
+
+	rest := results.Trades[2]
+	assert.Equal(t, 1, rest.ID)
+	assert.Equal(t, "STOP_LOSS", rest.ExitReason)
+	assert.Equal(t, float64(102), rest.ExitPrice)
+	assert.Equal(t, float64(1), rest.Size)
+	assert.Equal(t, 10000.0+4-4+2, results.FinalBalance)
+}
+
+func TestEngine_RunRecordsEquityCurveAndDrawdownFromEquity(t *testing.T) {
+	bars := []types.Bar{
+		{Timestamp: TimeFromString("2024-01-01T00:00:00Z"), Open: 100, High: 101, Low: 99, Close: 100},
+		{Timestamp: TimeFromString("2024-01-01T00:15:00Z"), Open: 100, High: 100, Low: 80, Close: 85},
+		{Timestamp: TimeFromString("2024-01-01T00:30:00Z"), Open: 85, High: 96, Low: 84, Close: 95},
+		{Timestamp: TimeFromString("2024-01-01T00:45:00Z"), Open: 95, High: 111, Low: 95, Close: 108},
+	}
+
+	strategy := &signalStrategy{signals: map[int][]types.Signal{
+		0: {{Type: OPEN_TRADE, Action: types.BUY, Price: 100, TP: 110, SL: 50, Size: 1}},
+	}}
+
+	results, err := NewEngine(bars, 5000).Run(strategy)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(5000), results.InitialBalance)
+
+	assert.Equal(t, 4, len(results.EquityCurve))
+	assert.Equal(t, EquityPoint{Timestamp: bars[1].Timestamp, Balance: 5000, Equity: 4985, Exposure: 85}, results.EquityCurve[1])
+	assert.Equal(t, EquityPoint{Timestamp: bars[3].Timestamp, Balance: 5010, Equity: 5010}, results.EquityCurve[3])
+
+	// The only trade was a winner, but it was 15 under water on the way
+	stats := results.Calculate()
+	assert.Equal(t, float64(15), stats.MaxDrawdown)
+	assert.InDelta(t, 0.3, stats.MaxDrawdownPercent, 1e-9)
+}
+
+func TestEngine_RunGivesMultiTimeframeStrategiesOnlyClosedBars(t *testing.T) {
+	start := TimeFromString("2024-01-01T00:00:00Z")
+	var bars []types.Bar
+	for i := 0; i < 8; i++ {
+		price := float64(100 + i)
+		bars = append(bars, types.Bar{Timestamp: start.Add(time.Duration(i) * 15 * time.Minute), Open: price, High: price + 1, Low: price - 1, Close: price})
+	}
+
+	strategy := &timeframeStrategy{timeframes: []types.Granularity{types.H1}}
+	_, err := engine(bars).Run(strategy)
+	assert.Error(t, err, "Resampling needs the base granularity")
+
+	e := engine(bars)
+	e.Granularity = types.M15
+	_, err = e.Run(strategy)
+	assert.NoError(t, err)
+
+	// The first H1 bar is only visible once the 00:45 bar has closed at 01:00
+	assert.Equal(t, []int{0, 0, 0, 1, 1, 1, 1, 2}, strategy.seen)
+	assert.Equal(t, float64(107), strategy.last.Close)
+
+	// Pre-fetched bars are used as given
+	e.Timeframes = map[types.Granularity][]types.Bar{
+		types.H1: {{Timestamp: start, Open: 1, High: 1, Low: 1, Close: 1}},
+	}
+	strategy.seen = nil
+	_, err = e.Run(strategy)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{0, 0, 0, 1, 1, 1, 1, 1}, strategy.seen)
+	assert.Equal(t, float64(1), strategy.last.Close)
+}
+
+func engine(bars []types.Bar) *Engine {
+	return NewEngine(bars, 10000.0)
+}
+
+// signalStrategy returns a fixed set of signals for each bar index
+type signalStrategy struct {
+	TestStrategy
+	signals map[int][]types.Signal
+}
+
+func (s *signalStrategy) OnBar(bars []types.Bar, currentIndex int, account *account.Account) []types.Signal {
+	return s.signals[currentIndex]
+}
+
+// timeframeStrategy records how many higher timeframe bars it could see on each base bar
+type timeframeStrategy struct {
+	TestStrategy
+	timeframes []types.Granularity
+	seen       []int
+	last       types.Bar
+}
+
+func (s *timeframeStrategy) Timeframes() []types.Granularity {
+	return s.timeframes
+}
+
+func (s *timeframeStrategy) OnBarWithTimeframes(bars []types.Bar, currentIndex int, timeframes strategy.Timeframes, account *account.Account) []types.Signal {
+	higher := timeframes[s.timeframes[0]]
+	s.seen = append(s.seen, len(higher))
+	if len(higher) > 0 {
+		s.last = higher[len(higher)-1]
+	}
+	return nil
+}
+
 type TestStrategy struct{}
 
 func (s *TestStrategy) OnBar(bars []types.Bar, currentIndex int, account *account.Account) []types.Signal {
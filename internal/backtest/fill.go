@@ -0,0 +1,61 @@
+package backtest
+
+import "github.com/jwtly10/tradebook/internal/types"
+
+// FillModel decides the price a market signal is filled at, given the bar after the one it was generated on
+type FillModel interface {
+	FillPrice(signal types.Signal, next types.Bar) float64
+}
+
+// SignalPriceFill fills at the price on the signal, usually the close of the signal bar.
+// This ignores any gap between bars so will flatter gap-prone instruments.
+type SignalPriceFill struct{}
+
+func (SignalPriceFill) FillPrice(signal types.Signal, next types.Bar) float64 {
+	return signal.Price
+}
+
+// NextOpenFill fills at the open of the next bar.
+// Like all the next bar models, buys fill on the ask and sells on the bid when the bar carries quotes.
+type NextOpenFill struct{}
+
+func (NextOpenFill) FillPrice(signal types.Signal, next types.Bar) float64 {
+	return next.Side(signal.Action).Open
+}
+
+// NextOpenSlippageFill fills at the open of the next bar, moved against the trade by a fixed amount of price
+type NextOpenSlippageFill struct {
+	Slippage float64
+}
+
+func (f NextOpenSlippageFill) FillPrice(signal types.Signal, next types.Bar) float64 {
+	open := next.Side(signal.Action).Open
+	if signal.Action == types.SELL {
+		return open - f.Slippage
+	}
+	return open + f.Slippage
+}
+
+// NextBarVWAPFill fills at an approximation of the next bar's VWAP.
+// We only have OHLCV so the typical price (H+L+C)/3 is used.
+type NextBarVWAPFill struct{}
+
+func (NextBarVWAPFill) FillPrice(signal types.Signal, next types.Bar) float64 {
+	prices := next.Side(signal.Action)
+	return (prices.High + prices.Low + prices.Close) / 3
+}
+
+// Reanchor moves the signal to the given fill price, keeping the SL and TP distances the strategy asked for
+func Reanchor(signal types.Signal, price float64) types.Signal {
+	shift := price - signal.Price
+
+	signal.Price = price
+	if signal.SL != 0 {
+		signal.SL += shift
+	}
+	if signal.TP != 0 {
+		signal.TP += shift
+	}
+
+	return signal
+}
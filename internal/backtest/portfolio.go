@@ -0,0 +1,271 @@
+package backtest
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/jwtly10/tradebook/internal/account"
+	"github.com/jwtly10/tradebook/internal/strategy"
+	"github.com/jwtly10/tradebook/internal/types"
+)
+
+// PortfolioEngine backtests several instruments against one shared account.
+// Bars from every instrument are merged on a common clock, so an instrument only
+// moves forward when it has a bar at the current timestamp.
+type PortfolioEngine struct {
+	// Bars per instrument, each in ascending order
+	Bars map[string][]types.Bar
+	// FillModel prices market signals on the bar after they were generated, defaults to NextOpenFill
+	FillModel FillModel
+	// ExitResolver decides bars that touch both SL and TP, defaults to the account's pessimistic resolver
+	ExitResolver account.ExitResolver
+	// CostModel charges spread, commission and slippage on every fill, nil means trading is free
+	CostModel account.CostModel
+	// Margin rejects trades the account can't afford and closes out positions, nil disables margin
+	Margin *account.Margin
+	// Financing charges positions held over the daily rollover, nil means holding is free
+	Financing *account.Financing
+	// Conversion converts PnL into the account currency, nil treats every instrument as quoted in it
+	Conversion *account.Conversion
+	// Instruments round units and prices and enforce trade size and stop distance limits, rejected signals are recorded on the results
+	Instruments map[string]types.Instrument
+
+	initialBalance float64
+}
+
+// PortfolioResults holds the combined results of the account and a breakdown per instrument
+type PortfolioResults struct {
+	Combined    *Results
+	Instruments map[string]*Results
+}
+
+func NewPortfolioEngine(bars map[string][]types.Bar, initialBalance float64) *PortfolioEngine {
+	return &PortfolioEngine{
+		Bars:           bars,
+		FillModel:      NextOpenFill{},
+		initialBalance: initialBalance,
+	}
+}
+
+// Run backtests each instrument with its strategy. Signals without an instrument trade the
+// instrument of the strategy that generated them, signals naming another instrument are routed to it.
+func (e *PortfolioEngine) Run(strategies map[string]strategy.Strategy) (*PortfolioResults, error) {
+	instruments := make([]string, 0, len(strategies))
+	for instrument := range strategies {
+		if _, ok := e.Bars[instrument]; !ok {
+			return nil, fmt.Errorf("no bars for instrument %s", instrument)
+		}
+		instruments = append(instruments, instrument)
+	}
+	// Iterate instruments in a fixed order so runs are reproducible
+	sort.Strings(instruments)
+
+	// The clock only moves forwards, an instrument with a bar behind it would never be processed again
+	for instrument, bars := range e.Bars {
+		for i := 1; i < len(bars); i++ {
+			if !bars[i].Timestamp.After(bars[i-1].Timestamp) {
+				return nil, fmt.Errorf("bars for %s must be in strictly increasing order, bar %d at %s is not after bar %d at %s",
+					instrument, i, bars[i].Timestamp.Format(time.RFC3339), i-1, bars[i-1].Timestamp.Format(time.RFC3339))
+			}
+		}
+	}
+
+	if e.Conversion != nil {
+		for instrument, bars := range e.Bars {
+			if err := e.Conversion.Covers(instrument, bars); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	acc := account.NewAccount(e.initialBalance)
+	if e.ExitResolver != nil {
+		acc.Resolver = e.ExitResolver
+	}
+	acc.CostModel = e.CostModel
+	acc.Margin = e.Margin
+	acc.Financing = e.Financing
+	acc.Conversion = e.Conversion
+	acc.Instruments = e.Instruments
+	results := &Results{
+		Currency:       acc.Currency(),
+		InitialBalance: e.initialBalance,
+		Trades:         []account.Trade{},
+	}
+
+	slog.Debug("Starting portfolio backtest", "initial_balance", e.initialBalance, "instruments", instruments)
+
+	// Index of the next bar to process per instrument, and market signals waiting for that bar
+	next := make(map[string]int, len(e.Bars))
+	queued := make(map[string][]types.Signal, len(e.Bars))
+
+	for _, timestamp := range e.clock() {
+		// Every instrument with a bar now fills and exits before any strategy sees the new bars
+		var active []string
+		for instrument, bars := range e.Bars {
+			i := next[instrument]
+			if i >= len(bars) || !bars[i].Timestamp.Equal(timestamp) {
+				continue
+			}
+			active = append(active, instrument)
+		}
+		sort.Strings(active)
+
+		for _, instrument := range active {
+			bar := e.Bars[instrument][next[instrument]]
+
+			for _, signal := range queued[instrument] {
+				if signal.Type == CLOSE_TRADE {
+					results.Trades = append(results.Trades, CloseTargets(acc, e.FillModel, signal, bar)...)
+					continue
+				}
+				fillPrice := e.FillModel.FillPrice(signal, bar)
+				slog.Debug("Filling market signal", "instrument", instrument, "signal_price", signal.Price, "fill_price", fillPrice, "timestamp", bar.Timestamp)
+				acc.OpenTrade(Reanchor(signal, fillPrice), bar)
+			}
+			queued[instrument] = nil
+
+			acc.CheckOrdersFor(instrument, bar)
+			results.Trades = append(results.Trades, acc.CheckExitsFor(instrument, bar)...)
+		}
+		// Checked once every instrument has been marked at this timestamp
+		results.Trades = append(results.Trades, acc.CheckMarginCloseout()...)
+		results.EquityCurve = append(results.EquityCurve, equityPoint(timestamp, acc))
+
+		for _, instrument := range active {
+			i := next[instrument]
+			next[instrument]++
+
+			strat, ok := strategies[instrument]
+			if !ok {
+				continue
+			}
+
+			bars := e.Bars[instrument]
+			for _, signal := range strat.OnBar(bars, i, acc) {
+				if signal.Instrument == "" {
+					signal.Instrument = instrument
+					// Positions are closed and modified on their own instrument, whichever strategy manages them
+					if pos, ok := acc.Position(signal.PositionID); ok && signal.Type != OPEN_TRADE {
+						signal.Instrument = pos.Instrument
+					}
+				}
+				if _, ok := e.Bars[signal.Instrument]; !ok {
+					slog.Warn("Dropping signal for instrument without bars", "instrument", signal.Instrument, "from", instrument)
+					continue
+				}
+
+				switch signal.Type {
+				case OPEN_TRADE:
+					if signal.IsPending() {
+						acc.PlaceOrder(signal, bars[i].Timestamp)
+						continue
+					}
+					// Market signals fill on the target instrument's next bar, which we may never get
+					queued[signal.Instrument] = append(queued[signal.Instrument], signal)
+				case CLOSE_TRADE:
+					queued[signal.Instrument] = append(queued[signal.Instrument], signal)
+				case MODIFY_TRADE:
+					ModifyTargets(acc, signal, bars[i].Timestamp)
+				}
+			}
+		}
+	}
+
+	// Close anything at the end, at each instrument's own last bar
+	names := make([]string, 0, len(e.Bars))
+	for instrument := range e.Bars {
+		names = append(names, instrument)
+	}
+	sort.Strings(names)
+	for _, instrument := range names {
+		bars := e.Bars[instrument]
+		if len(bars) > 0 {
+			results.Trades = append(results.Trades, acc.CloseAllFor(instrument, bars[len(bars)-1])...)
+		}
+	}
+
+	if len(results.EquityCurve) > 0 {
+		last := results.EquityCurve[len(results.EquityCurve)-1].Timestamp
+		results.EquityCurve[len(results.EquityCurve)-1] = equityPoint(last, acc)
+	}
+
+	results.FinalBalance = acc.Balance
+	results.Rejections = acc.Rejections()
+
+	return &PortfolioResults{
+		Combined:    results,
+		Instruments: byInstrument(results.Trades, results.Currency, e.initialBalance, names),
+	}, nil
+}
+
+// clock returns every timestamp any instrument has a bar at, in order
+func (e *PortfolioEngine) clock() []time.Time {
+	seen := make(map[time.Time]bool)
+	var timestamps []time.Time
+	for _, bars := range e.Bars {
+		for _, bar := range bars {
+			// Normalise so the same instant in different locations is one tick
+			ts := bar.Timestamp.UTC()
+			if !seen[ts] {
+				seen[ts] = true
+				timestamps = append(timestamps, ts)
+			}
+		}
+	}
+	sort.Slice(timestamps, func(i, j int) bool {
+		return timestamps[i].Before(timestamps[j])
+	})
+	return timestamps
+}
+
+// byInstrument splits trades into results per instrument, each as though it had the whole
+// initial balance to itself so its statistics are comparable with a single instrument run
+func byInstrument(trades []account.Trade, currency string, initialBalance float64, instruments []string) map[string]*Results {
+	split := make(map[string]*Results, len(instruments))
+	for _, instrument := range instruments {
+		split[instrument] = &Results{
+			Currency:       currency,
+			InitialBalance: initialBalance,
+			FinalBalance:   initialBalance,
+			Trades:         []account.Trade{},
+		}
+	}
+
+	for _, trade := range trades {
+		r, ok := split[trade.Instrument]
+		if !ok {
+			continue
+		}
+		r.Trades = append(r.Trades, trade)
+		r.FinalBalance += trade.PnL
+	}
+	return split
+}
+
+func (r *PortfolioResults) Print() {
+	names := make([]string, 0, len(r.Instruments))
+	for instrument := range r.Instruments {
+		names = append(names, instrument)
+	}
+	sort.Strings(names)
+
+	c := account.CurrencySymbol(r.Combined.Currency)
+
+	fmt.Println("\n=== Portfolio Breakdown ===")
+	for _, instrument := range names {
+		stats := r.Instruments[instrument].Calculate()
+		fmt.Printf("%-12s Trades: %4d | Win Rate: %6.2f%% | P&L: %s%.2f | Costs: %s%.2f | Max DD: %s%.2f\n",
+			instrument,
+			stats.TotalTrades,
+			stats.WinRate,
+			c, stats.TotalPnL,
+			c, stats.TotalCosts.Total(),
+			c, stats.MaxDrawdown,
+		)
+	}
+
+	r.Combined.Calculate().Print()
+}
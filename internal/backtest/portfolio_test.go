@@ -0,0 +1,129 @@
+package backtest
+
+import (
+	"testing"
+
+	"github.com/jwtly10/tradebook/internal/strategy"
+	"github.com/jwtly10/tradebook/internal/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPortfolioEngine_RunSharesOneAccountAcrossInstruments(t *testing.T) {
+	bars := map[string][]types.Bar{
+		"NAS100_USD": {
+			{Timestamp: TimeFromString("2024-01-01T00:00:00Z"), Open: 100, High: 101, Low: 99, Close: 100},
+			{Timestamp: TimeFromString("2024-01-01T00:15:00Z"), Open: 100, High: 106, Low: 100, Close: 105},
+			{Timestamp: TimeFromString("2024-01-01T00:30:00Z"), Open: 105, High: 106, Low: 104, Close: 105},
+		},
+		// Starts a bar later than NAS100
+		"GBP_USD": {
+			{Timestamp: TimeFromString("2024-01-01T00:15:00Z"), Open: 10, High: 11, Low: 9, Close: 10},
+			{Timestamp: TimeFromString("2024-01-01T00:30:00Z"), Open: 10, High: 10, Low: 8, Close: 9},
+			{Timestamp: TimeFromString("2024-01-01T00:45:00Z"), Open: 9, High: 9, Low: 7, Close: 8},
+		},
+	}
+
+	strategies := map[string]strategy.Strategy{
+		"NAS100_USD": &signalStrategy{signals: map[int][]types.Signal{
+			0: {
+				{Type: OPEN_TRADE, Action: types.BUY, Price: 100, TP: 105, SL: 90, Size: 1},
+				// Routed to GBP_USD, which fills on its first bar at 00:15
+				{Instrument: "GBP_USD", Type: OPEN_TRADE, Action: types.SELL, Price: 10, TP: 1, SL: 20, Size: 10},
+			},
+		}},
+		"GBP_USD": &signalStrategy{signals: map[int][]types.Signal{
+			1: {{Type: OPEN_TRADE, Action: types.BUY, Price: 9, TP: 20, SL: 1, Size: 1}},
+		}},
+	}
+
+	results, err := NewPortfolioEngine(bars, 10000).Run(strategies)
+	assert.NoError(t, err)
+
+	combined := results.Combined
+	assert.Equal(t, 3, len(combined.Trades))
+	assert.Equal(t, "NAS100_USD", combined.Trades[0].Instrument)
+	assert.Equal(t, "TAKE_PROFIT", combined.Trades[0].ExitReason)
+
+	// NAS100 +5, GBP_USD short from 10 to 8 +20, long from 9 (00:45 open) to 8 -1
+	assert.Equal(t, 10000.0+5+20-1, combined.FinalBalance)
+
+	nas := results.Instruments["NAS100_USD"]
+	assert.Equal(t, 1, len(nas.Trades))
+	assert.Equal(t, 10005.0, nas.FinalBalance)
+
+	gbp := results.Instruments["GBP_USD"]
+	assert.Equal(t, 2, len(gbp.Trades))
+	assert.Equal(t, 10019.0, gbp.FinalBalance)
+	for _, trade := range gbp.Trades {
+		assert.Equal(t, "END_OF_BACKTEST", trade.ExitReason)
+		assert.Equal(t, TimeFromString("2024-01-01T00:45:00Z"), trade.ExitTime)
+	}
+}
+
+func TestPortfolioEngine_RunClosesPositionsOnTheirOwnInstrument(t *testing.T) {
+	bars := map[string][]types.Bar{
+		"NAS100_USD": {
+			{Timestamp: TimeFromString("2024-01-01T00:00:00Z"), Open: 100, High: 101, Low: 99, Close: 100},
+			{Timestamp: TimeFromString("2024-01-01T00:15:00Z"), Open: 100, High: 106, Low: 100, Close: 105},
+			{Timestamp: TimeFromString("2024-01-01T00:30:00Z"), Open: 105, High: 106, Low: 104, Close: 105},
+		},
+		"GBP_USD": {
+			{Timestamp: TimeFromString("2024-01-01T00:15:00Z"), Open: 10, High: 11, Low: 9, Close: 10},
+			{Timestamp: TimeFromString("2024-01-01T00:30:00Z"), Open: 12, High: 12, Low: 11, Close: 11},
+			{Timestamp: TimeFromString("2024-01-01T00:45:00Z"), Open: 11, High: 11, Low: 10, Close: 10},
+		},
+	}
+
+	strategies := map[string]strategy.Strategy{
+		"NAS100_USD": &signalStrategy{signals: map[int][]types.Signal{
+			0: {{Instrument: "GBP_USD", Type: OPEN_TRADE, Action: types.BUY, Price: 10, TP: 20, SL: 1, Size: 1}},
+			// The GBP_USD position, closed by ID from the NAS100 strategy
+			1: {{Type: CLOSE_TRADE, PositionID: 1}},
+		}},
+	}
+
+	results, err := NewPortfolioEngine(bars, 10000).Run(strategies)
+	assert.NoError(t, err)
+
+	trades := results.Combined.Trades
+	assert.Equal(t, 1, len(trades))
+	assert.Equal(t, "GBP_USD", trades[0].Instrument)
+	assert.Equal(t, "STRATEGY_EXIT", trades[0].ExitReason)
+	// At GBP_USD's next open, not NAS100's
+	assert.Equal(t, TimeFromString("2024-01-01T00:30:00Z"), trades[0].ExitTime)
+	assert.Equal(t, 12.0, trades[0].ExitPrice)
+	assert.Equal(t, 10002.0, results.Combined.FinalBalance)
+}
+
+func TestPortfolioEngine_RunNeedsBarsForEveryStrategy(t *testing.T) {
+	_, err := NewPortfolioEngine(map[string][]types.Bar{}, 10000).Run(map[string]strategy.Strategy{
+		"NAS100_USD": &signalStrategy{},
+	})
+	assert.Error(t, err)
+}
+
+func TestPortfolioEngine_RunRejectsUnorderedBars(t *testing.T) {
+	bar := func(ts string) types.Bar {
+		return types.Bar{Timestamp: TimeFromString(ts), Open: 1, High: 1, Low: 1, Close: 1}
+	}
+	tests := []struct {
+		name string
+		bars []types.Bar
+	}{
+		{"duplicate", []types.Bar{bar("2024-01-01T00:00:00Z"), bar("2024-01-01T00:15:00Z"), bar("2024-01-01T00:15:00Z")}},
+		{"out of order", []types.Bar{bar("2024-01-01T00:15:00Z"), bar("2024-01-01T00:00:00Z")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bars := map[string][]types.Bar{
+				"NAS100_USD": {bar("2024-01-01T00:00:00Z"), bar("2024-01-01T00:15:00Z")},
+				"GBP_USD":    tt.bars,
+			}
+			_, err := NewPortfolioEngine(bars, 10000).Run(map[string]strategy.Strategy{
+				"NAS100_USD": &signalStrategy{},
+			})
+			assert.ErrorContains(t, err, "bars for GBP_USD must be in strictly increasing order")
+		})
+	}
+}
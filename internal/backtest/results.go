@@ -1,11 +1,37 @@
 package backtest
 
-import "github.com/jwtly10/tradebook/internal/account"
+import (
+	"time"
+
+	"github.com/jwtly10/tradebook/internal/account"
+	"github.com/jwtly10/tradebook/internal/data"
+)
 
 type Results struct {
+	Currency       string // Account currency, empty unless the engine converts between currencies
 	InitialBalance float64
 	FinalBalance   float64
 	Trades         []account.Trade
+	DataQuality    *data.Report // Nil unless the engine has a Validator
+	Rejections     []account.OrderRejectedError
+	EquityCurve    []EquityPoint // One point per bar, after exits on that bar
 
 	stats *Statistics
 }
+
+// EquityPoint is the state of the account at the close of a bar
+type EquityPoint struct {
+	Timestamp time.Time
+	Balance   float64 // Realised
+	Equity    float64 // Balance plus unrealised PnL of open positions
+	Exposure  float64 // Notional value of open positions
+}
+
+func equityPoint(timestamp time.Time, acc *account.Account) EquityPoint {
+	return EquityPoint{
+		Timestamp: timestamp,
+		Balance:   acc.Balance,
+		Equity:    acc.Equity(),
+		Exposure:  acc.Exposure(),
+	}
+}
@@ -3,14 +3,19 @@ package backtest
 import (
 	"fmt"
 	"time"
+
+	"github.com/jwtly10/tradebook/internal/account"
 )
 
 type Statistics struct {
+	Currency string // Account currency all amounts are in, empty is treated as GBP
+
 	// Basic
-	TotalTrades   int
-	WinningTrades int
-	LosingTrades  int
-	WinRate       float64
+	TotalTrades    int
+	WinningTrades  int
+	LosingTrades   int
+	WinRate        float64
+	RejectedOrders int
 
 	// P&L
 	TotalPnL        float64
@@ -19,6 +24,11 @@ type Statistics struct {
 	GrossLoss       float64
 	ProfitFactor    float64
 
+	// Costs
+	TotalGrossPnL  float64
+	TotalCosts     account.Costs
+	TotalFinancing float64 // Negative is a net charge
+
 	// Averages
 	AvgWin        float64
 	AvgLoss       float64
@@ -39,7 +49,9 @@ func (r *Results) Calculate() *Statistics {
 	}
 
 	stats := &Statistics{
-		TotalTrades: len(r.Trades),
+		Currency:       r.Currency,
+		TotalTrades:    len(r.Trades),
+		RejectedOrders: len(r.Rejections),
 	}
 
 	if len(r.Trades) == 0 {
@@ -63,6 +75,11 @@ func (r *Results) Calculate() *Statistics {
 			totalLoss += trade.PnL // Already negative
 		}
 
+		// Costs
+		stats.TotalGrossPnL += trade.GrossPnL
+		stats.TotalCosts = stats.TotalCosts.Add(trade.Costs)
+		stats.TotalFinancing += trade.Financing
+
 		// Drawdown calculation
 		runningBalance += trade.PnL
 		if runningBalance > peak {
@@ -102,9 +119,14 @@ func (r *Results) Calculate() *Statistics {
 	stats.ExpectedValue = stats.TotalPnL / float64(stats.TotalTrades)
 
 	// Drawdown
-	stats.MaxDrawdown = maxDD
-	if peak > 0 {
-		stats.MaxDrawdownPercent = (maxDD / peak) * 100
+	if len(r.EquityCurve) > 0 {
+		// Equity catches drawdowns while trades are open, which closed trade balances miss
+		stats.MaxDrawdown, stats.MaxDrawdownPercent = equityDrawdown(r.InitialBalance, r.EquityCurve)
+	} else {
+		stats.MaxDrawdown = maxDD
+		if peak > 0 {
+			stats.MaxDrawdownPercent = (maxDD / peak) * 100
+		}
 	}
 
 	// Duration
@@ -114,35 +136,63 @@ func (r *Results) Calculate() *Statistics {
 	return stats
 }
 
+// equityDrawdown returns the largest fall in equity from a previous high, and that fall as a percentage of the high
+func equityDrawdown(initialBalance float64, curve []EquityPoint) (float64, float64) {
+	peak := initialBalance
+	var maxDD, maxDDPercent float64
+	for _, point := range curve {
+		if point.Equity > peak {
+			peak = point.Equity
+		}
+		dd := peak - point.Equity
+		if dd > maxDD {
+			maxDD = dd
+		}
+		if peak > 0 && dd/peak*100 > maxDDPercent {
+			maxDDPercent = dd / peak * 100
+		}
+	}
+	return maxDD, maxDDPercent
+}
+
 func (s *Statistics) Print() {
+	c := account.CurrencySymbol(s.Currency)
+
 	fmt.Println("\n=== Backtest Results ===")
 	fmt.Printf("Total Trades:     %d\n", s.TotalTrades)
 	fmt.Printf("Winning Trades:   %d (%.2f%%)\n", s.WinningTrades, s.WinRate)
-	fmt.Printf("Losing Trades:    %d\n\n", s.LosingTrades)
+	fmt.Printf("Losing Trades:    %d\n", s.LosingTrades)
+	fmt.Printf("Rejected Orders:  %d\n\n", s.RejectedOrders)
 
-	fmt.Printf("Total P&L:        £%.2f (%.2f%%)\n", s.TotalPnL, s.TotalPnLPercent)
-	fmt.Printf("Gross Profit:     £%.2f\n", s.GrossProfit)
-	fmt.Printf("Gross Loss:       £%.2f\n", s.GrossLoss)
+	fmt.Printf("Total P&L:        %s%.2f (%.2f%%)\n", c, s.TotalPnL, s.TotalPnLPercent)
+	fmt.Printf("Gross Profit:     %s%.2f\n", c, s.GrossProfit)
+	fmt.Printf("Gross Loss:       %s%.2f\n", c, s.GrossLoss)
 	fmt.Printf("Profit Factor:    %.2f\n\n", s.ProfitFactor)
 
-	fmt.Printf("Avg Win:          £%.2f\n", s.AvgWin)
-	fmt.Printf("Avg Loss:         £%.2f\n", s.AvgLoss)
-	fmt.Printf("Expected Value:   £%.2f per trade\n\n", s.ExpectedValue)
+	fmt.Printf("Gross P&L:        %s%.2f\n", c, s.TotalGrossPnL)
+	fmt.Printf("Total Costs:      %s%.2f (spread %s%.2f, commission %s%.2f, slippage %s%.2f)\n",
+		c, s.TotalCosts.Total(), c, s.TotalCosts.Spread, c, s.TotalCosts.Commission, c, s.TotalCosts.Slippage)
+	fmt.Printf("Financing:        %s%.2f\n\n", c, s.TotalFinancing)
+
+	fmt.Printf("Avg Win:          %s%.2f\n", c, s.AvgWin)
+	fmt.Printf("Avg Loss:         %s%.2f\n", c, s.AvgLoss)
+	fmt.Printf("Expected Value:   %s%.2f per trade\n\n", c, s.ExpectedValue)
 
-	fmt.Printf("Max Drawdown:     £%.2f (%.2f%%)\n", s.MaxDrawdown, s.MaxDrawdownPercent)
+	fmt.Printf("Max Drawdown:     %s%.2f (%.2f%%)\n", c, s.MaxDrawdown, s.MaxDrawdownPercent)
 	fmt.Printf("Avg Duration:     %s\n", s.AvgTradeDuration.Round(time.Minute))
 }
 
 func (r *Results) PrintTrades() {
 	fmt.Println("\n=== Trade List ===")
 	for i, trade := range r.Trades {
-		fmt.Printf("#%d | %s | Entry: %.5f @ %s | Exit: %.5f @ %s | P&L: £%.2f | %s\n",
+		fmt.Printf("#%d | %s | Entry: %.5f @ %s | Exit: %.5f @ %s | P&L: %s%.2f | %s\n",
 			i+1,
 			trade.Direction,
 			trade.EntryPrice,
 			trade.EntryTime.Format("2006-01-02 15:04"),
 			trade.ExitPrice,
 			trade.ExitTime.Format("2006-01-02 15:04"),
+			account.CurrencySymbol(r.Currency),
 			trade.PnL,
 			trade.ExitReason,
 		)
@@ -152,13 +202,14 @@ func (r *Results) PrintTrades() {
 func (r *Results) PrintTradesBetween(i, j int) {
 	fmt.Println("\n=== Trade List ===")
 	for index, trade := range r.Trades[i:j] {
-		fmt.Printf("#%d | %s | Entry: %.5f @ %s | Exit: %.5f @ %s | P&L: £%.2f | %s\n",
+		fmt.Printf("#%d | %s | Entry: %.5f @ %s | Exit: %.5f @ %s | P&L: %s%.2f | %s\n",
 			i+index+1,
 			trade.Direction,
 			trade.EntryPrice,
 			trade.EntryTime.Format("2006-01-02 15:04"),
 			trade.ExitPrice,
 			trade.ExitTime.Format("2006-01-02 15:04"),
+			account.CurrencySymbol(r.Currency),
 			trade.PnL,
 			trade.ExitReason,
 		)
@@ -0,0 +1,262 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/jwtly10/tradebook/internal/data"
+	"github.com/jwtly10/tradebook/internal/instrument"
+	"github.com/jwtly10/tradebook/internal/types"
+)
+
+var ErrOffline = errors.New("bars are missing from the cache and the cache is offline")
+
+// Cache wraps another data.DataSource, storing bars on disk per instrument, granularity and
+// price component, and only fetching the date ranges it hasn't seen before.
+type Cache struct {
+	Dir    string
+	Source data.DataSource
+	// Offline never calls the Source, and errors with ErrOffline if any bars are missing
+	Offline bool
+
+	now func() time.Time
+}
+
+// Range is a half open [From, To) period of time the cache holds every bar for
+type Range struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// entry is the on disk format for a single instrument, granularity and price component.
+// Coverage is stored separately to the bars, so that periods with no bars (weekends, holidays)
+// are not refetched once a later bar has been seen.
+type entry struct {
+	Instrument  string               `json:"instrument"`
+	Granularity types.Granularity    `json:"granularity"`
+	Price       types.PriceComponent `json:"price"`
+	Coverage    []Range              `json:"coverage"`
+	Bars        []types.Bar          `json:"bars"`
+}
+
+func NewCache(dir string, source data.DataSource) *Cache {
+	return &Cache{
+		Dir:    dir,
+		Source: source,
+		now:    time.Now,
+	}
+}
+
+// FetchBars returns all bars between 2 dates, fetching and storing any ranges missing from the cache.
+//
+// Only completed bars are cached, anything more recent is fetched on every call.
+func (c *Cache) FetchBars(ctx context.Context, req data.Request) ([]types.Bar, error) {
+	period, err := req.Granularity.ToDuration()
+	if err != nil {
+		return nil, err
+	}
+	if req.Price == "" {
+		req.Price = types.MID
+	}
+
+	e, err := c.load(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Anything starting after this could still be forming, so is never cached
+	cacheableTo := req.To
+	if latest := c.now().Add(-period).Truncate(period); latest.Before(cacheableTo) {
+		cacheableTo = latest
+	}
+
+	gaps := missing(e.Coverage, Range{From: req.From, To: cacheableTo})
+	if len(gaps) > 0 {
+		if c.Offline {
+			return nil, fmt.Errorf("%w: %s %s %s missing %d range(s), first from %s to %s", ErrOffline, req.Instrument, req.Granularity, req.Price, len(gaps), gaps[0].From, gaps[0].To)
+		}
+
+		for _, gap := range gaps {
+			slog.Info("Fetching bars missing from cache", "instrument", req.Instrument, "granularity", req.Granularity, "price", req.Price, "from", gap.From, "to", gap.To)
+			bars, err := c.fetch(ctx, req, gap)
+			if err != nil {
+				return nil, err
+			}
+			// Gaps end before anything that could still be forming, so the whole gap is covered
+			// even if the source had no bars for the end of it (weekends, holidays)
+			e.Bars = mergeBars(e.Bars, bars)
+			e.Coverage = addRange(e.Coverage, gap)
+		}
+
+		if err := c.save(e); err != nil {
+			return nil, err
+		}
+	} else {
+		slog.Info("Loaded bars from cache", "instrument", req.Instrument, "granularity", req.Granularity, "price", req.Price, "from", req.From, "to", cacheableTo)
+	}
+
+	result := data.Between(e.Bars, req.From, cacheableTo)
+
+	if cacheableTo.Before(req.To) {
+		if c.Offline {
+			return nil, fmt.Errorf("%w: %s %s %s bars after %s are too recent to be cached", ErrOffline, req.Instrument, req.Granularity, req.Price, cacheableTo)
+		}
+		recent, err := c.fetch(ctx, req, Range{From: maxTime(req.From, cacheableTo), To: req.To})
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, recent...)
+	}
+
+	return result, nil
+}
+
+// fetch loads all bars in the range from the underlying source
+func (c *Cache) fetch(ctx context.Context, req data.Request, r Range) ([]types.Bar, error) {
+	if c.Source == nil {
+		return nil, fmt.Errorf("cache has no source to load bars from %s to %s", r.From, r.To)
+	}
+
+	req.From = r.From
+	req.To = r.To
+	bars, err := c.Source.FetchBars(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch bars from %s to %s: %w", r.From, r.To, err)
+	}
+
+	return bars, nil
+}
+
+// Instruments lists the underlying source's instruments, or the instrument registry's when offline
+func (c *Cache) Instruments(ctx context.Context) ([]types.Instrument, error) {
+	if c.Offline || c.Source == nil {
+		return instrument.Default().All(), nil
+	}
+	return c.Source.Instruments(ctx)
+}
+
+// Instrument returns the underlying source's metadata, or the instrument registry's when offline
+func (c *Cache) Instrument(ctx context.Context, name string) (types.Instrument, error) {
+	if c.Offline || c.Source == nil {
+		return instrument.Lookup(name)
+	}
+	return c.Source.Instrument(ctx, name)
+}
+
+func (c *Cache) path(req data.Request) string {
+	return filepath.Join(c.Dir, req.Instrument, fmt.Sprintf("%s_%s.json", req.Granularity, req.Price))
+}
+
+func (c *Cache) load(req data.Request) (*entry, error) {
+	data, err := os.ReadFile(c.path(req))
+	if errors.Is(err, os.ErrNotExist) {
+		return &entry{
+			Instrument:  req.Instrument,
+			Granularity: req.Granularity,
+			Price:       req.Price,
+		}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache file: %w", err)
+	}
+
+	var e entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, fmt.Errorf("failed to decode cache file %s: %w", c.path(req), err)
+	}
+	return &e, nil
+}
+
+// save writes the entry to a temporary file first, so an interrupted write can't corrupt the cache
+func (c *Cache) save(e *entry) error {
+	path := c.path(data.Request{Instrument: e.Instrument, Granularity: e.Granularity, Price: e.Price})
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to encode cache entry: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// missing returns the parts of want that are not covered by any of the (sorted, non overlapping) ranges
+func missing(coverage []Range, want Range) []Range {
+	var gaps []Range
+	cursor := want.From
+
+	for _, r := range coverage {
+		if !r.To.After(cursor) {
+			continue
+		}
+		if !r.From.Before(want.To) {
+			break
+		}
+		if r.From.After(cursor) {
+			gaps = append(gaps, Range{From: cursor, To: r.From})
+		}
+		cursor = r.To
+	}
+
+	if cursor.Before(want.To) {
+		gaps = append(gaps, Range{From: cursor, To: want.To})
+	}
+	return gaps
+}
+
+// addRange adds the range to the coverage, merging any overlapping or touching ranges
+func addRange(coverage []Range, add Range) []Range {
+	all := append(append([]Range{}, coverage...), add)
+	sort.Slice(all, func(i, j int) bool { return all[i].From.Before(all[j].From) })
+
+	merged := []Range{all[0]}
+	for _, r := range all[1:] {
+		last := &merged[len(merged)-1]
+		if !r.From.After(last.To) {
+			if r.To.After(last.To) {
+				last.To = r.To
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// mergeBars combines both sets of bars sorted by timestamp, preferring the new bar for duplicate timestamps
+func mergeBars(existing, bars []types.Bar) []types.Bar {
+	byTime := make(map[time.Time]types.Bar, len(existing)+len(bars))
+	for _, bar := range existing {
+		byTime[bar.Timestamp] = bar
+	}
+	for _, bar := range bars {
+		byTime[bar.Timestamp] = bar
+	}
+
+	merged := make([]types.Bar, 0, len(byTime))
+	for _, bar := range byTime {
+		merged = append(merged, bar)
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].Timestamp.Before(merged[j].Timestamp) })
+	return merged
+}
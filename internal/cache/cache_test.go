@@ -0,0 +1,172 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jwtly10/tradebook/internal/data"
+	"github.com/jwtly10/tradebook/internal/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// stubSource serves hourly bars on weekdays, recording every request made
+type stubSource struct {
+	requests []data.Request
+}
+
+func (s *stubSource) FetchBars(ctx context.Context, req data.Request) ([]types.Bar, error) {
+	s.requests = append(s.requests, req)
+
+	var bars []types.Bar
+	for ts := req.From; ts.Before(req.To); ts = ts.Add(time.Hour) {
+		if ts.Weekday() == time.Saturday || ts.Weekday() == time.Sunday {
+			continue
+		}
+		bars = append(bars, types.Bar{Timestamp: ts, Open: 1, High: 1, Low: 1, Close: 1})
+	}
+	return bars, nil
+}
+
+func (s *stubSource) Instruments(ctx context.Context) ([]types.Instrument, error) {
+	return nil, nil
+}
+
+func (s *stubSource) Instrument(ctx context.Context, name string) (types.Instrument, error) {
+	return types.Instrument{Name: name}, nil
+}
+
+func newTestCache(t *testing.T, source data.DataSource) *Cache {
+	c := NewCache(t.TempDir(), source)
+	c.now = func() time.Time { return time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC) }
+	return c
+}
+
+func request(from, to time.Time) data.Request {
+	return data.Request{Instrument: "GBP_USD", Granularity: types.H1, From: from, To: to}
+}
+
+func TestCache_OnlyFetchesMissingRanges(t *testing.T) {
+	source := &stubSource{}
+	c := newTestCache(t, source)
+	day := time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	bars, err := c.FetchBars(context.Background(), request(day, day.Add(6*time.Hour)))
+	assert.NoError(t, err)
+	assert.Equal(t, 6, len(bars))
+	assert.Equal(t, day, bars[0].Timestamp, "The first bar should be included")
+	assert.Equal(t, 1, len(source.requests))
+
+	// Fully cached, nothing to fetch
+	bars, err = c.FetchBars(context.Background(), request(day.Add(2*time.Hour), day.Add(4*time.Hour)))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(bars))
+	assert.Equal(t, 1, len(source.requests))
+
+	// Only the gaps either side are fetched
+	bars, err = c.FetchBars(context.Background(), request(day.Add(-2*time.Hour), day.Add(8*time.Hour)))
+	assert.NoError(t, err)
+	assert.Equal(t, 10, len(bars))
+	assert.Equal(t, 3, len(source.requests))
+	assert.Equal(t, day.Add(6*time.Hour), source.requests[2].From)
+	assert.Equal(t, day.Add(8*time.Hour), source.requests[2].To)
+}
+
+func TestCache_OfflineServesCachedBarsOnly(t *testing.T) {
+	source := &stubSource{}
+	c := newTestCache(t, source)
+	day := time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	_, err := c.FetchBars(context.Background(), request(day, day.Add(6*time.Hour)))
+	assert.NoError(t, err)
+
+	offline := NewCache(c.Dir, nil)
+	offline.Offline = true
+	offline.now = c.now
+
+	bars, err := offline.FetchBars(context.Background(), request(day, day.Add(6*time.Hour)))
+	assert.NoError(t, err)
+	assert.Equal(t, 6, len(bars))
+
+	_, err = offline.FetchBars(context.Background(), request(day, day.Add(7*time.Hour)))
+	assert.True(t, errors.Is(err, ErrOffline))
+}
+
+func TestCache_DoesNotCacheIncompleteBars(t *testing.T) {
+	source := &stubSource{}
+	c := newTestCache(t, source)
+	now := c.now()
+
+	_, err := c.FetchBars(context.Background(), request(now.Add(-4*time.Hour), now))
+	assert.NoError(t, err)
+	_, err = c.FetchBars(context.Background(), request(now.Add(-4*time.Hour), now))
+	assert.NoError(t, err)
+
+	// The most recent bar is refetched every time, the rest only once
+	assert.Equal(t, 3, len(source.requests))
+	assert.Equal(t, now.Add(-time.Hour), source.requests[2].From)
+	assert.Equal(t, now, source.requests[2].To)
+}
+
+func TestCache_CoversWeekendsWithoutBars(t *testing.T) {
+	source := &stubSource{}
+	c := newTestCache(t, source)
+	saturday := time.Date(2025, 1, 4, 0, 0, 0, 0, time.UTC)
+
+	bars, err := c.FetchBars(context.Background(), request(saturday, saturday.Add(48*time.Hour)))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(bars))
+
+	offline := NewCache(c.Dir, nil)
+	offline.Offline = true
+	offline.now = c.now
+
+	bars, err = offline.FetchBars(context.Background(), request(saturday, saturday.Add(48*time.Hour)))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(bars))
+}
+
+func TestCache_CoversRequestsEndingOnAWeekend(t *testing.T) {
+	source := &stubSource{}
+	c := newTestCache(t, source)
+	friday := time.Date(2025, 1, 3, 20, 0, 0, 0, time.UTC)
+	saturday := time.Date(2025, 1, 4, 12, 0, 0, 0, time.UTC)
+
+	bars, err := c.FetchBars(context.Background(), request(friday, saturday))
+	assert.NoError(t, err)
+	assert.Equal(t, 4, len(bars))
+
+	offline := NewCache(c.Dir, nil)
+	offline.Offline = true
+	offline.now = c.now
+
+	bars, err = offline.FetchBars(context.Background(), request(friday, saturday))
+	assert.NoError(t, err)
+	assert.Equal(t, 4, len(bars))
+	assert.Equal(t, 1, len(source.requests))
+}
+
+func TestCache_RecentBarsStartFromTheRequest(t *testing.T) {
+	source := &stubSource{}
+	c := newTestCache(t, source)
+	now := c.now()
+
+	// Both bars are too recent to cache, the whole request is fetched as is
+	bars, err := c.FetchBars(context.Background(), request(now.Add(-30*time.Minute), now.Add(time.Hour)))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(bars))
+	assert.Equal(t, 1, len(source.requests))
+	assert.Equal(t, now.Add(-30*time.Minute), source.requests[0].From)
+}
+
+func TestMissing(t *testing.T) {
+	at := func(h int) time.Time { return time.Date(2025, 1, 1, h, 0, 0, 0, time.UTC) }
+	coverage := []Range{{From: at(2), To: at(4)}, {From: at(6), To: at(8)}}
+
+	assert.Equal(t, []Range{{From: at(0), To: at(2)}, {From: at(4), To: at(6)}, {From: at(8), To: at(10)}}, missing(coverage, Range{From: at(0), To: at(10)}))
+	assert.Equal(t, []Range(nil), missing(coverage, Range{From: at(2), To: at(4)}))
+	assert.Equal(t, []Range{{From: at(1), To: at(2)}}, missing(coverage, Range{From: at(1), To: at(3)}))
+
+	assert.Equal(t, []Range{{From: at(2), To: at(8)}}, addRange(coverage, Range{From: at(4), To: at(6)}))
+}
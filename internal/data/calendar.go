@@ -0,0 +1,204 @@
+package data
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jwtly10/tradebook/internal/instrument"
+)
+
+// Calendar reports when an instrument trades, so closed periods aren't reported as missing data
+type Calendar interface {
+	IsOpen(t time.Time) bool
+}
+
+// AlwaysOpen is a calendar for instruments that trade around the clock
+type AlwaysOpen struct{}
+
+func (AlwaysOpen) IsOpen(t time.Time) bool {
+	return true
+}
+
+// SessionCalendar is a weekly trading session in a local time zone, with optional daily breaks and holidays
+type SessionCalendar struct {
+	Location  *time.Location
+	OpenDay   time.Weekday
+	OpenTime  time.Duration // Time of day the week opens, eg 17 * time.Hour
+	CloseDay  time.Weekday
+	CloseTime time.Duration // Time of day the week closes
+	// DailyBreaks are periods each day the market is closed, eg a 17:00-18:00 maintenance break
+	DailyBreaks []Break
+	// Holidays are trading days the market is closed, by their date in Location. A trading day
+	// ends at CloseTime, so with a 17:00 close the 25th runs from 17:00 on the 24th.
+	Holidays []time.Time
+	// AnnualHolidays are trading days the market is closed every year, eg 25 December
+	AnnualHolidays []AnnualHoliday
+}
+
+// AnnualHoliday is a trading day closed every year
+type AnnualHoliday struct {
+	Month time.Month
+	Day   int
+}
+
+// standardHolidays are the days Oanda closes FX and CFDs every year
+var standardHolidays = []AnnualHoliday{{Month: time.January, Day: 1}, {Month: time.December, Day: 25}}
+
+// Break is a period of the day, as offsets from midnight, when the market is closed
+type Break struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+func newYork() *time.Location {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		// Without tzdata fall back to EST, which is only wrong by an hour during DST
+		return time.FixedZone("EST", -5*60*60)
+	}
+	return loc
+}
+
+// FXCalendar trades from Sunday 17:00 to Friday 17:00 New York time
+func FXCalendar() *SessionCalendar {
+	return &SessionCalendar{
+		Location:       newYork(),
+		OpenDay:        time.Sunday,
+		OpenTime:       17 * time.Hour,
+		CloseDay:       time.Friday,
+		CloseTime:      17 * time.Hour,
+		AnnualHolidays: standardHolidays,
+	}
+}
+
+// IndexCFDCalendar trades from Sunday 18:00 to Friday 17:00 New York time, with a daily break from 17:00 to 18:00
+func IndexCFDCalendar() *SessionCalendar {
+	return &SessionCalendar{
+		Location:       newYork(),
+		OpenDay:        time.Sunday,
+		OpenTime:       18 * time.Hour,
+		CloseDay:       time.Friday,
+		CloseTime:      17 * time.Hour,
+		DailyBreaks:    []Break{{Start: 17 * time.Hour, End: 18 * time.Hour}},
+		AnnualHolidays: standardHolidays,
+	}
+}
+
+// NewSessionCalendar builds a calendar from an instrument's trading hours
+func NewSessionCalendar(hours instrument.Hours) (*SessionCalendar, error) {
+	loc, err := time.LoadLocation(hours.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load timezone %s: %w", hours.Timezone, err)
+	}
+	openDay, openTime, err := parseWeekTime(hours.Open)
+	if err != nil {
+		return nil, err
+	}
+	closeDay, closeTime, err := parseWeekTime(hours.Close)
+	if err != nil {
+		return nil, err
+	}
+
+	calendar := &SessionCalendar{
+		Location:  loc,
+		OpenDay:   openDay,
+		OpenTime:  openTime,
+		CloseDay:  closeDay,
+		CloseTime: closeTime,
+	}
+	for _, b := range hours.Breaks {
+		start, end, ok := strings.Cut(b, "-")
+		if !ok {
+			return nil, fmt.Errorf("invalid break %q, expected HH:MM-HH:MM", b)
+		}
+		startTime, err := parseTimeOfDay(start)
+		if err != nil {
+			return nil, err
+		}
+		endTime, err := parseTimeOfDay(end)
+		if err != nil {
+			return nil, err
+		}
+		calendar.DailyBreaks = append(calendar.DailyBreaks, Break{Start: startTime, End: endTime})
+	}
+	for _, h := range hours.Holidays {
+		date, err := time.Parse("01-02", strings.TrimSpace(h))
+		if err != nil {
+			return nil, fmt.Errorf("invalid holiday %q, expected MM-DD: %w", h, err)
+		}
+		calendar.AnnualHolidays = append(calendar.AnnualHolidays, AnnualHoliday{Month: date.Month(), Day: date.Day()})
+	}
+	return calendar, nil
+}
+
+// CalendarFor returns the calendar for an instrument from the default instrument registry
+func CalendarFor(name string) (Calendar, error) {
+	hours, ok := instrument.Default().Hours(name)
+	if !ok {
+		return nil, fmt.Errorf("no trading hours for instrument: %s", name)
+	}
+	return NewSessionCalendar(hours)
+}
+
+// parseWeekTime parses a day and time of day, eg "Sunday 17:00"
+func parseWeekTime(s string) (time.Weekday, time.Duration, error) {
+	day, clock, ok := strings.Cut(strings.TrimSpace(s), " ")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid session time %q, expected eg Sunday 17:00", s)
+	}
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if strings.EqualFold(d.String(), day) {
+			timeOfDay, err := parseTimeOfDay(clock)
+			return d, timeOfDay, err
+		}
+	}
+	return 0, 0, fmt.Errorf("invalid weekday %q", day)
+}
+
+// parseTimeOfDay parses HH:MM as an offset from midnight
+func parseTimeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q: %w", s, err)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func (c *SessionCalendar) IsOpen(t time.Time) bool {
+	local := t.In(c.Location)
+	timeOfDay := time.Duration(local.Hour())*time.Hour + time.Duration(local.Minute())*time.Minute + time.Duration(local.Second())*time.Second
+
+	// Past the close, times belong to the next trading day
+	tradingDay := local
+	if c.CloseTime > 0 && timeOfDay >= c.CloseTime {
+		tradingDay = local.AddDate(0, 0, 1)
+	}
+	for _, holiday := range c.Holidays {
+		y, m, d := holiday.Date()
+		if tradingDay.Year() == y && tradingDay.Month() == m && tradingDay.Day() == d {
+			return false
+		}
+	}
+	for _, holiday := range c.AnnualHolidays {
+		if tradingDay.Month() == holiday.Month && tradingDay.Day() == holiday.Day {
+			return false
+		}
+	}
+
+	for _, b := range c.DailyBreaks {
+		if timeOfDay >= b.Start && timeOfDay < b.End {
+			return false
+		}
+	}
+
+	sinceWeekStart := time.Duration(local.Weekday())*24*time.Hour + timeOfDay
+	opensAt := time.Duration(c.OpenDay)*24*time.Hour + c.OpenTime
+	closesAt := time.Duration(c.CloseDay)*24*time.Hour + c.CloseTime
+
+	if opensAt < closesAt {
+		return sinceWeekStart >= opensAt && sinceWeekStart < closesAt
+	}
+	// Session wraps the end of the week
+	return sinceWeekStart >= opensAt || sinceWeekStart < closesAt
+}
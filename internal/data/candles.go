@@ -0,0 +1,121 @@
+package data
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/jwtly10/tradebook/internal/types"
+)
+
+// CandleBuilder aggregates ticks of one instrument into bars of a granularity, on the same boundaries
+// as Resample. Mid prices come from the midpoint of each tick, bid and ask from its quotes and volume is
+// the number of ticks, as with Oanda's candles. Periods without ticks produce no bar.
+type CandleBuilder struct {
+	Granularity types.Granularity
+	Alignment   Alignment
+
+	bar     types.Bar
+	end     time.Time
+	forming bool
+	started bool
+	// End of the last bar returned, ticks before it belong to a bar already emitted
+	emitted time.Time
+}
+
+func NewCandleBuilder(granularity types.Granularity, alignment Alignment) (*CandleBuilder, error) {
+	if _, err := granularity.ToDuration(); err != nil {
+		return nil, err
+	}
+	return &CandleBuilder{Granularity: granularity, Alignment: alignment}, nil
+}
+
+// Add adds a tick, returning the previous bar if the tick starts a new one.
+// The first bar built is marked Partial, unless its first tick was at the very start of its period,
+// as the ticks before the builder started are missing from it. Ticks older than the forming bar,
+// or arriving late for a bar already returned, are dropped, as are ticks quoted while the market
+// isn't tradeable.
+func (b *CandleBuilder) Add(tick types.Tick) (types.Bar, bool) {
+	if !tick.Tradeable {
+		slog.Debug("Dropping untradeable tick", "instrument", tick.Instrument, "time", tick.Time)
+		return types.Bar{}, false
+	}
+	if (b.forming && tick.Time.Before(b.bar.Timestamp)) || tick.Time.Before(b.emitted) {
+		slog.Debug("Dropping out of order tick", "instrument", tick.Instrument, "time", tick.Time, "bar", b.bar.Timestamp)
+		return types.Bar{}, false
+	}
+
+	var completed types.Bar
+	var ok bool
+	if b.forming && !tick.Time.Before(b.end) {
+		completed, ok = b.bar, true
+		b.forming = false
+		b.emitted = b.end
+	}
+
+	if !b.forming {
+		if err := b.start(tick); err != nil {
+			slog.Error("Failed to align tick", "instrument", tick.Instrument, "granularity", b.Granularity, "error", err)
+		}
+		return completed, ok
+	}
+
+	mid := tick.Mid()
+	b.bar.High = max(b.bar.High, mid)
+	b.bar.Low = min(b.bar.Low, mid)
+	b.bar.Close = mid
+	b.bar.Bid = mergeOHLC(b.bar.Bid, types.OHLC{High: tick.Bid, Low: tick.Bid, Close: tick.Bid})
+	b.bar.Ask = mergeOHLC(b.bar.Ask, types.OHLC{High: tick.Ask, Low: tick.Ask, Close: tick.Ask})
+	b.bar.Spread = tick.Ask - tick.Bid
+	b.bar.Volume++
+	return completed, ok
+}
+
+// Flush returns the forming bar once its period has ended by now, for when no tick arrives to close it
+func (b *CandleBuilder) Flush(now time.Time) (types.Bar, bool) {
+	if !b.forming || now.Before(b.end) {
+		return types.Bar{}, false
+	}
+	b.forming = false
+	b.emitted = b.end
+	return b.bar, true
+}
+
+// Current returns the forming bar, marked Partial, false if there isn't one
+func (b *CandleBuilder) Current() (types.Bar, bool) {
+	if !b.forming {
+		return types.Bar{}, false
+	}
+	bar := b.bar
+	bar.Partial = true
+	return bar, true
+}
+
+// start begins a new bar from the tick
+func (b *CandleBuilder) start(tick types.Tick) error {
+	start, err := b.Alignment.Start(b.Granularity, tick.Time)
+	if err != nil {
+		return err
+	}
+	end, err := b.Alignment.End(b.Granularity, tick.Time)
+	if err != nil {
+		return err
+	}
+
+	mid := tick.Mid()
+	b.bar = types.Bar{
+		Timestamp: start,
+		Open:      mid,
+		High:      mid,
+		Low:       mid,
+		Close:     mid,
+		Volume:    1,
+		Spread:    tick.Ask - tick.Bid,
+		Bid:       types.OHLC{Open: tick.Bid, High: tick.Bid, Low: tick.Bid, Close: tick.Bid},
+		Ask:       types.OHLC{Open: tick.Ask, High: tick.Ask, Low: tick.Ask, Close: tick.Ask},
+		Partial:   !b.started && tick.Time.After(start),
+	}
+	b.end = end
+	b.forming = true
+	b.started = true
+	return nil
+}
@@ -0,0 +1,131 @@
+package data
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jwtly10/tradebook/internal/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func tick(t time.Time, bid, ask float64) types.Tick {
+	return types.Tick{Instrument: "EUR_USD", Time: t, Bid: bid, Ask: ask, Tradeable: true}
+}
+
+func TestCandleBuilder_BuildsBarsFromTicks(t *testing.T) {
+	b, err := NewCandleBuilder(types.M15, DefaultAlignment())
+	assert.NoError(t, err)
+
+	start := time.Date(2025, 1, 8, 12, 0, 0, 0, time.UTC)
+
+	// Joins part way through 12:00 - 12:15
+	_, ok := b.Add(tick(start.Add(5*time.Minute), 1.0, 1.2))
+	assert.False(t, ok)
+	_, ok = b.Add(tick(start.Add(14*time.Minute), 1.1, 1.3))
+	assert.False(t, ok)
+
+	bar, ok := b.Add(tick(start.Add(15*time.Minute), 1.3, 1.5))
+	assert.True(t, ok)
+	assert.Equal(t, start, bar.Timestamp.UTC())
+	assert.True(t, bar.Partial)
+	assert.InDelta(t, 1.1, bar.Open, 1e-9)
+	assert.InDelta(t, 1.2, bar.Close, 1e-9)
+	assert.Equal(t, 2.0, bar.Volume)
+
+	// 12:15 - 12:30 is covered from its first tick
+	b.Add(tick(start.Add(20*time.Minute), 1.5, 1.7))
+	b.Add(tick(start.Add(25*time.Minute), 0.9, 1.1))
+	bar, ok = b.Add(tick(start.Add(45*time.Minute), 1.0, 1.2))
+	assert.True(t, ok)
+	assert.False(t, bar.Partial)
+	assert.Equal(t, start.Add(15*time.Minute), bar.Timestamp.UTC())
+	assert.InDelta(t, 1.4, bar.Open, 1e-9)
+	assert.InDelta(t, 1.6, bar.High, 1e-9)
+	assert.InDelta(t, 1.0, bar.Low, 1e-9)
+	assert.InDelta(t, 1.0, bar.Close, 1e-9)
+	assert.Equal(t, 3.0, bar.Volume)
+	assert.Equal(t, types.OHLC{Open: 1.3, High: 1.5, Low: 0.9, Close: 0.9}, bar.Bid)
+	assert.Equal(t, types.OHLC{Open: 1.5, High: 1.7, Low: 1.1, Close: 1.1}, bar.Ask)
+	assert.InDelta(t, 0.2, bar.Spread, 1e-9)
+
+	// 12:30 - 12:45 had no ticks, so the next bar is 12:45
+	current, ok := b.Current()
+	assert.True(t, ok)
+	assert.True(t, current.Partial)
+	assert.Equal(t, start.Add(45*time.Minute), current.Timestamp.UTC())
+}
+
+func TestCandleBuilder_DropsOutOfOrderTicks(t *testing.T) {
+	b, err := NewCandleBuilder(types.M15, DefaultAlignment())
+	assert.NoError(t, err)
+
+	start := time.Date(2025, 1, 8, 12, 0, 0, 0, time.UTC)
+	b.Add(tick(start.Add(15*time.Minute), 1.0, 1.2))
+	_, ok := b.Add(tick(start.Add(10*time.Minute), 2.0, 2.2))
+	assert.False(t, ok)
+
+	current, _ := b.Current()
+	assert.InDelta(t, 1.1, current.High, 1e-9)
+	assert.Equal(t, 1.0, current.Volume)
+}
+
+func TestCandleBuilder_DropsUntradeableTicks(t *testing.T) {
+	b, err := NewCandleBuilder(types.M15, DefaultAlignment())
+	assert.NoError(t, err)
+
+	start := time.Date(2025, 1, 8, 12, 0, 0, 0, time.UTC)
+	b.Add(tick(start, 1.0, 1.2))
+	halted := tick(start.Add(5*time.Minute), 2.0, 2.2)
+	halted.Tradeable = false
+	_, ok := b.Add(halted)
+	assert.False(t, ok)
+
+	// Nor does an untradeable tick close the forming bar
+	halted.Time = start.Add(20 * time.Minute)
+	_, ok = b.Add(halted)
+	assert.False(t, ok)
+
+	current, _ := b.Current()
+	assert.Equal(t, start, current.Timestamp.UTC())
+	assert.InDelta(t, 1.1, current.High, 1e-9)
+	assert.Equal(t, 1.0, current.Volume)
+}
+
+func TestCandleBuilder_Flush(t *testing.T) {
+	b, err := NewCandleBuilder(types.H1, DefaultAlignment())
+	assert.NoError(t, err)
+
+	_, ok := b.Flush(time.Now())
+	assert.False(t, ok)
+
+	start := time.Date(2025, 1, 8, 12, 0, 0, 0, time.UTC)
+	b.Add(tick(start, 1.0, 1.2))
+
+	_, ok = b.Flush(start.Add(59 * time.Minute))
+	assert.False(t, ok)
+
+	bar, ok := b.Flush(start.Add(time.Hour))
+	assert.True(t, ok)
+	assert.Equal(t, start, bar.Timestamp.UTC())
+	assert.False(t, bar.Partial)
+
+	_, ok = b.Current()
+	assert.False(t, ok)
+
+	// A tick stamped before the flushed bar ended arrives after it, it can't reopen the bar
+	_, ok = b.Add(tick(start.Add(59*time.Minute), 1.1, 1.3))
+	assert.False(t, ok)
+	_, ok = b.Current()
+	assert.False(t, ok)
+
+	b.Add(tick(start.Add(time.Hour), 1.1, 1.3))
+	current, ok := b.Current()
+	assert.True(t, ok)
+	assert.Equal(t, start.Add(time.Hour), current.Timestamp.UTC())
+	assert.Equal(t, 1.0, current.Volume)
+}
+
+func TestNewCandleBuilder_RejectsUnknownGranularity(t *testing.T) {
+	_, err := NewCandleBuilder(types.Granularity("X1"), DefaultAlignment())
+	assert.Error(t, err)
+}
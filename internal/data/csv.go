@@ -0,0 +1,440 @@
+package data
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jwtly10/tradebook/internal/types"
+)
+
+const (
+	// Special CSVFormat.TimeLayout values for numeric timestamps
+	UnixSeconds      = "unix"
+	UnixMilliseconds = "unixms"
+)
+
+// CSVFormat describes how bars are laid out in a CSV file
+type CSVFormat struct {
+	Delimiter rune // Defaults to ','
+	HasHeader bool
+	// Columns maps bar fields to columns. If left empty on a file with a header,
+	// columns are detected from the header names.
+	Columns CSVColumns
+	// TimeLayout is a Go time layout, or UnixSeconds / UnixMilliseconds. Defaults to RFC3339.
+	// When the date and time are in separate columns they are joined with a space before parsing.
+	TimeLayout string
+	// Location is used for layouts without a time zone, defaults to UTC
+	Location *time.Location
+}
+
+// CSVColumns maps bar fields to column numbers. Columns are numbered from 1, zero means the column isn't present.
+type CSVColumns struct {
+	Timestamp int
+	Date      int // Used with Time instead of Timestamp when the date and time are split over two columns
+	Time      int
+	Open      int
+	High      int
+	Low       int
+	Close     int
+	Volume    int
+	Spread    int
+
+	BidOpen  int
+	BidHigh  int
+	BidLow   int
+	BidClose int
+	AskOpen  int
+	AskHigh  int
+	AskLow   int
+	AskClose int
+}
+
+// CSVError reports a problem with a single line of a CSV file
+type CSVError struct {
+	Line   int
+	Column string
+	Err    error
+}
+
+func (e *CSVError) Error() string {
+	if e.Column == "" {
+		return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+	}
+	return fmt.Sprintf("line %d: %s: %v", e.Line, e.Column, e.Err)
+}
+
+func (e *CSVError) Unwrap() error {
+	return e.Err
+}
+
+// DefaultCSVFormat reads files written by WriteCSV
+func DefaultCSVFormat() CSVFormat {
+	return CSVFormat{
+		Delimiter:  ',',
+		HasHeader:  true,
+		TimeLayout: time.RFC3339,
+		Location:   time.UTC,
+	}
+}
+
+// headerAliases maps lower case header names to the bar field they hold
+var headerAliases = map[string]func(c *CSVColumns) *int{
+	"timestamp": func(c *CSVColumns) *int { return &c.Timestamp },
+	"datetime":  func(c *CSVColumns) *int { return &c.Timestamp },
+	"date":      func(c *CSVColumns) *int { return &c.Date },
+	"time":      func(c *CSVColumns) *int { return &c.Time },
+	"open":      func(c *CSVColumns) *int { return &c.Open },
+	"o":         func(c *CSVColumns) *int { return &c.Open },
+	"high":      func(c *CSVColumns) *int { return &c.High },
+	"h":         func(c *CSVColumns) *int { return &c.High },
+	"low":       func(c *CSVColumns) *int { return &c.Low },
+	"l":         func(c *CSVColumns) *int { return &c.Low },
+	"close":     func(c *CSVColumns) *int { return &c.Close },
+	"c":         func(c *CSVColumns) *int { return &c.Close },
+	"volume":    func(c *CSVColumns) *int { return &c.Volume },
+	"vol":       func(c *CSVColumns) *int { return &c.Volume },
+	"tickvol":   func(c *CSVColumns) *int { return &c.Volume },
+	"spread":    func(c *CSVColumns) *int { return &c.Spread },
+	"bid_open":  func(c *CSVColumns) *int { return &c.BidOpen },
+	"bid_high":  func(c *CSVColumns) *int { return &c.BidHigh },
+	"bid_low":   func(c *CSVColumns) *int { return &c.BidLow },
+	"bid_close": func(c *CSVColumns) *int { return &c.BidClose },
+	"ask_open":  func(c *CSVColumns) *int { return &c.AskOpen },
+	"ask_high":  func(c *CSVColumns) *int { return &c.AskHigh },
+	"ask_low":   func(c *CSVColumns) *int { return &c.AskLow },
+	"ask_close": func(c *CSVColumns) *int { return &c.AskClose },
+}
+
+// ColumnsFromHeader detects columns from header names such as "timestamp", "open" or "bid_close".
+// Unrecognised headers are ignored.
+func ColumnsFromHeader(header []string) CSVColumns {
+	var columns CSVColumns
+	for i, name := range header {
+		name = strings.ToLower(strings.Trim(strings.TrimSpace(name), "<>"))
+		if field, ok := headerAliases[name]; ok && *field(&columns) == 0 {
+			*field(&columns) = i + 1
+		}
+	}
+
+	// A lone date or time column holds the full timestamp
+	if columns.Timestamp == 0 && (columns.Date == 0) != (columns.Time == 0) {
+		columns.Timestamp = max(columns.Date, columns.Time)
+		columns.Date, columns.Time = 0, 0
+	}
+	return columns
+}
+
+// ReadCSV reads bars from CSV in the given format, in file order
+func ReadCSV(r io.Reader, format CSVFormat) ([]types.Bar, error) {
+	reader := csv.NewReader(r)
+	if format.Delimiter != 0 {
+		reader.Comma = format.Delimiter
+	}
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	if format.TimeLayout == "" {
+		format.TimeLayout = time.RFC3339
+	}
+	if format.Location == nil {
+		format.Location = time.UTC
+	}
+
+	columns := format.Columns
+	line := 0
+
+	if format.HasHeader {
+		header, err := reader.Read()
+		line++
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, &CSVError{Line: line, Err: err}
+		}
+		if columns == (CSVColumns{}) {
+			columns = ColumnsFromHeader(header)
+		}
+	}
+
+	if err := columns.validate(); err != nil {
+		return nil, err
+	}
+
+	var bars []types.Bar
+	for {
+		record, err := reader.Read()
+		line++
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, &CSVError{Line: line, Err: err}
+		}
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+
+		bar, err := parseRecord(record, columns, format)
+		if err != nil {
+			var csvErr *CSVError
+			if errors.As(err, &csvErr) {
+				csvErr.Line = line
+			}
+			return nil, err
+		}
+		bars = append(bars, bar)
+	}
+
+	return bars, nil
+}
+
+func (c CSVColumns) validate() error {
+	if c.Timestamp == 0 && (c.Date == 0 || c.Time == 0) {
+		return errors.New("csv format has no timestamp column, or date and time columns")
+	}
+	if c.Open == 0 || c.High == 0 || c.Low == 0 || c.Close == 0 {
+		return errors.New("csv format must have open, high, low and close columns")
+	}
+	return nil
+}
+
+func parseRecord(record []string, columns CSVColumns, format CSVFormat) (types.Bar, error) {
+	var bar types.Bar
+	var err error
+
+	field := func(column int, name string) (string, error) {
+		if column > len(record) {
+			return "", &CSVError{Column: name, Err: fmt.Errorf("expected at least %d columns, found %d", column, len(record))}
+		}
+		return strings.TrimSpace(record[column-1]), nil
+	}
+
+	number := func(column int, name string, dest *float64) {
+		if err != nil || column == 0 {
+			return
+		}
+		var value string
+		value, err = field(column, name)
+		if err != nil {
+			return
+		}
+		*dest, err = strconv.ParseFloat(value, 64)
+		if err != nil {
+			err = &CSVError{Column: name, Err: fmt.Errorf("invalid number %q", value)}
+		}
+	}
+
+	var rawTime string
+	if columns.Timestamp != 0 {
+		rawTime, err = field(columns.Timestamp, "timestamp")
+	} else {
+		var date, clock string
+		date, err = field(columns.Date, "date")
+		if err == nil {
+			clock, err = field(columns.Time, "time")
+		}
+		rawTime = date + " " + clock
+	}
+	if err != nil {
+		return bar, err
+	}
+	bar.Timestamp, err = parseTime(rawTime, format.TimeLayout, format.Location)
+	if err != nil {
+		return bar, &CSVError{Column: "timestamp", Err: err}
+	}
+
+	number(columns.Open, "open", &bar.Open)
+	number(columns.High, "high", &bar.High)
+	number(columns.Low, "low", &bar.Low)
+	number(columns.Close, "close", &bar.Close)
+	number(columns.Volume, "volume", &bar.Volume)
+	number(columns.Spread, "spread", &bar.Spread)
+	number(columns.BidOpen, "bid_open", &bar.Bid.Open)
+	number(columns.BidHigh, "bid_high", &bar.Bid.High)
+	number(columns.BidLow, "bid_low", &bar.Bid.Low)
+	number(columns.BidClose, "bid_close", &bar.Bid.Close)
+	number(columns.AskOpen, "ask_open", &bar.Ask.Open)
+	number(columns.AskHigh, "ask_high", &bar.Ask.High)
+	number(columns.AskLow, "ask_low", &bar.Ask.Low)
+	number(columns.AskClose, "ask_close", &bar.Ask.Close)
+	if err != nil {
+		return bar, err
+	}
+
+	if bar.High < bar.Low {
+		return bar, &CSVError{Err: fmt.Errorf("high %v is below low %v", bar.High, bar.Low)}
+	}
+	if bar.Spread == 0 && bar.HasQuotes() {
+		bar.Spread = bar.Ask.Close - bar.Bid.Close
+	}
+
+	return bar, nil
+}
+
+func parseTime(value, layout string, loc *time.Location) (time.Time, error) {
+	switch layout {
+	case UnixSeconds, UnixMilliseconds:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid unix timestamp %q", value)
+		}
+		if layout == UnixMilliseconds {
+			return time.UnixMilli(n).UTC(), nil
+		}
+		return time.Unix(n, 0).UTC(), nil
+	default:
+		t, err := time.ParseInLocation(layout, value, loc)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid time %q for layout %q", value, layout)
+		}
+		return t.UTC(), nil
+	}
+}
+
+// WriteCSV writes bars in DefaultCSVFormat, including bid and ask columns if any bar carries quotes
+func WriteCSV(w io.Writer, bars []types.Bar) error {
+	writer := csv.NewWriter(w)
+
+	withQuotes := false
+	for _, bar := range bars {
+		if bar.HasQuotes() {
+			withQuotes = true
+			break
+		}
+	}
+
+	header := []string{"timestamp", "open", "high", "low", "close", "volume", "spread"}
+	if withQuotes {
+		header = append(header, "bid_open", "bid_high", "bid_low", "bid_close", "ask_open", "ask_high", "ask_low", "ask_close")
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	format := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	for _, bar := range bars {
+		record := []string{
+			bar.Timestamp.UTC().Format(time.RFC3339),
+			format(bar.Open), format(bar.High), format(bar.Low), format(bar.Close),
+			format(bar.Volume), format(bar.Spread),
+		}
+		if withQuotes {
+			record = append(record,
+				format(bar.Bid.Open), format(bar.Bid.High), format(bar.Bid.Low), format(bar.Bid.Close),
+				format(bar.Ask.Open), format(bar.Ask.High), format(bar.Ask.Low), format(bar.Ask.Close),
+			)
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+func ReadCSVFile(path string, format CSVFormat) ([]types.Bar, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	bars, err := ReadCSV(f, format)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return bars, nil
+}
+
+func WriteCSVFile(path string, bars []types.Bar) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := WriteCSV(f, bars); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return f.Close()
+}
+
+// CSVSource serves bars from CSV files named <instrument>_<granularity>.csv in a directory,
+// eg NAS100_USD_M15.csv. Files are read once and held in memory.
+type CSVSource struct {
+	Dir    string
+	Format CSVFormat
+
+	mu     sync.Mutex
+	loaded map[string][]types.Bar
+}
+
+func NewCSVSource(dir string, format CSVFormat) *CSVSource {
+	return &CSVSource{
+		Dir:    dir,
+		Format: format,
+		loaded: make(map[string][]types.Bar),
+	}
+}
+
+func (s *CSVSource) FetchBars(ctx context.Context, req Request) ([]types.Bar, error) {
+	name := fmt.Sprintf("%s_%s.csv", req.Instrument, req.Granularity)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bars, ok := s.loaded[name]
+	if !ok {
+		var err error
+		bars, err = ReadCSVFile(filepath.Join(s.Dir, name), s.Format)
+		if err != nil {
+			return nil, err
+		}
+		sort.SliceStable(bars, func(i, j int) bool { return bars[i].Timestamp.Before(bars[j].Timestamp) })
+		s.loaded[name] = bars
+	}
+
+	return Between(bars, req.From, req.To), nil
+}
+
+// Instruments lists the instruments with at least one CSV file in the directory
+func (s *CSVSource) Instruments(ctx context.Context) ([]types.Instrument, error) {
+	paths, err := filepath.Glob(filepath.Join(s.Dir, "*_*.csv"))
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var instruments []types.Instrument
+	for _, path := range paths {
+		base := strings.TrimSuffix(filepath.Base(path), ".csv")
+		name := base[:strings.LastIndex(base, "_")]
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		instrument, err := s.Instrument(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		instruments = append(instruments, instrument)
+	}
+	return instruments, nil
+}
+
+// Instrument returns the registry's metadata for the instrument, CSV files carry none of their own
+func (s *CSVSource) Instrument(ctx context.Context, name string) (types.Instrument, error) {
+	return lookupInstrument(name), nil
+}
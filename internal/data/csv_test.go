@@ -0,0 +1,110 @@
+package data
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jwtly10/tradebook/internal/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCSV_RoundTrip(t *testing.T) {
+	bars := []types.Bar{
+		{
+			Timestamp: time.Date(2025, 1, 2, 14, 30, 0, 0, time.UTC),
+			Open:      21000.5, High: 21010, Low: 20990.2, Close: 21005, Volume: 321, Spread: 1.5,
+			Bid: types.OHLC{Open: 20999.75, High: 21009.25, Low: 20989.45, Close: 21004.25},
+			Ask: types.OHLC{Open: 21001.25, High: 21010.75, Low: 20990.95, Close: 21005.75},
+		},
+		{
+			Timestamp: time.Date(2025, 1, 2, 14, 45, 0, 0, time.UTC),
+			Open:      21005, High: 21020, Low: 21000, Close: 21015, Volume: 100, Spread: 1.5,
+			Bid: types.OHLC{Open: 21004.25, High: 21019.25, Low: 20999.25, Close: 21014.25},
+			Ask: types.OHLC{Open: 21005.75, High: 21020.75, Low: 21000.75, Close: 21015.75},
+		},
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, WriteCSV(&buf, bars))
+
+	read, err := ReadCSV(&buf, DefaultCSVFormat())
+	assert.NoError(t, err)
+	assert.Equal(t, bars, read)
+}
+
+func TestReadCSV_CustomMappingAndTimezone(t *testing.T) {
+	input := "2025.01.02;09:30;1.5;2.5;0.5;2.0;10\n" +
+		"2025.01.02;09:45;2.0;3.0;1.0;2.5;12\n"
+
+	ny, err := time.LoadLocation("America/New_York")
+	assert.NoError(t, err)
+
+	bars, err := ReadCSV(strings.NewReader(input), CSVFormat{
+		Delimiter:  ';',
+		Columns:    CSVColumns{Date: 1, Time: 2, Open: 3, High: 4, Low: 5, Close: 6, Volume: 7},
+		TimeLayout: "2006.01.02 15:04",
+		Location:   ny,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(bars))
+	assert.Equal(t, time.Date(2025, 1, 2, 14, 30, 0, 0, time.UTC), bars[0].Timestamp)
+	assert.Equal(t, types.Bar{Timestamp: time.Date(2025, 1, 2, 14, 45, 0, 0, time.UTC), Open: 2, High: 3, Low: 1, Close: 2.5, Volume: 12}, bars[1])
+}
+
+func TestReadCSV_ReportsOffendingLine(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		errMsg string
+	}{
+		{"bad number", "timestamp,open,high,low,close\n2025-01-02T00:00:00Z,1,2,0.5,1\n2025-01-02T00:15:00Z,1,abc,0.5,1\n", "line 3: high: invalid number \"abc\""},
+		{"bad time", "timestamp,open,high,low,close\n02/01/2025,1,2,0.5,1\n", "line 2: timestamp: invalid time \"02/01/2025\""},
+		{"high below low", "timestamp,open,high,low,close\n2025-01-02T00:00:00Z,1,0.5,2,1\n", "line 2: high 0.5 is below low 2"},
+		{"short row", "timestamp,open,high,low,close\n2025-01-02T00:00:00Z,1,2\n", "line 2: low: expected at least 4 columns, found 3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadCSV(strings.NewReader(tt.input), DefaultCSVFormat())
+			assert.ErrorContains(t, err, tt.errMsg)
+
+			var csvErr *CSVError
+			assert.True(t, errors.As(err, &csvErr))
+		})
+	}
+
+	_, err := ReadCSV(strings.NewReader("timestamp,price\n"), DefaultCSVFormat())
+	assert.ErrorContains(t, err, "must have open, high, low and close columns")
+}
+
+func TestCSVSource_FetchesBarsInRange(t *testing.T) {
+	dir := t.TempDir()
+	bars := []types.Bar{
+		{Timestamp: time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC), Open: 1, High: 1, Low: 1, Close: 1},
+		{Timestamp: time.Date(2025, 1, 2, 0, 15, 0, 0, time.UTC), Open: 2, High: 2, Low: 2, Close: 2},
+		{Timestamp: time.Date(2025, 1, 2, 0, 30, 0, 0, time.UTC), Open: 3, High: 3, Low: 3, Close: 3},
+	}
+	assert.NoError(t, WriteCSVFile(filepath.Join(dir, "NAS100_USD_M15.csv"), bars))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "NAS100_USD_H1.csv"), []byte("timestamp,open,high,low,close\n"), 0o644))
+
+	source := NewCSVSource(dir, DefaultCSVFormat())
+	got, err := source.FetchBars(context.Background(), Request{
+		Instrument:  "NAS100_USD",
+		Granularity: types.M15,
+		From:        bars[0].Timestamp,
+		To:          bars[2].Timestamp,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, bars[:2], got)
+
+	instruments, err := source.Instruments(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(instruments))
+	assert.Equal(t, float64(1), instruments[0].PipSize())
+}
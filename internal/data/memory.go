@@ -0,0 +1,68 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/jwtly10/tradebook/internal/types"
+)
+
+// MemorySource serves bars held in memory, eg from a synthetic generator or for tests
+type MemorySource struct {
+	bars        map[string]map[types.Granularity][]types.Bar
+	instruments map[string]types.Instrument
+}
+
+func NewMemorySource() *MemorySource {
+	return &MemorySource{
+		bars:        make(map[string]map[types.Granularity][]types.Bar),
+		instruments: make(map[string]types.Instrument),
+	}
+}
+
+// Add stores bars for the instrument and granularity, replacing any already held.
+// Metadata for the instrument is taken from the default instrument registry if it hasn't been set.
+func (s *MemorySource) Add(instrument string, granularity types.Granularity, bars []types.Bar) {
+	sorted := append([]types.Bar{}, bars...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Timestamp.Before(sorted[j].Timestamp) })
+
+	if s.bars[instrument] == nil {
+		s.bars[instrument] = make(map[types.Granularity][]types.Bar)
+	}
+	s.bars[instrument][granularity] = sorted
+
+	if _, ok := s.instruments[instrument]; !ok {
+		s.instruments[instrument] = lookupInstrument(instrument)
+	}
+}
+
+// SetInstrument stores metadata for an instrument
+func (s *MemorySource) SetInstrument(instrument types.Instrument) {
+	s.instruments[instrument.Name] = instrument
+}
+
+func (s *MemorySource) FetchBars(ctx context.Context, req Request) ([]types.Bar, error) {
+	bars, ok := s.bars[req.Instrument][req.Granularity]
+	if !ok {
+		return nil, fmt.Errorf("no %s bars held for %s", req.Granularity, req.Instrument)
+	}
+	return Between(bars, req.From, req.To), nil
+}
+
+func (s *MemorySource) Instruments(ctx context.Context) ([]types.Instrument, error) {
+	instruments := make([]types.Instrument, 0, len(s.instruments))
+	for _, instrument := range s.instruments {
+		instruments = append(instruments, instrument)
+	}
+	sort.Slice(instruments, func(i, j int) bool { return instruments[i].Name < instruments[j].Name })
+	return instruments, nil
+}
+
+func (s *MemorySource) Instrument(ctx context.Context, name string) (types.Instrument, error) {
+	instrument, ok := s.instruments[name]
+	if !ok {
+		return types.Instrument{}, fmt.Errorf("unknown instrument: %s", name)
+	}
+	return instrument, nil
+}
@@ -0,0 +1,113 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jwtly10/tradebook/internal/types"
+)
+
+// staleRatePeriods is how many periods of a pair's bars can pass after its last bar before it has no rate
+const staleRatePeriods = 5
+
+// RateTable converts between currencies using FX bars, such as GBP_USD, that have already been loaded
+type RateTable struct {
+	// MaxAge is how long after the end of a pair's last bar its close is still used as the rate.
+	// Zero allows a few periods of the pair's bars, or any age for a pair with a single bar.
+	MaxAge time.Duration
+
+	pairs   map[string][]types.Bar
+	periods map[string]time.Duration
+}
+
+func NewRateTable() *RateTable {
+	return &RateTable{
+		pairs:   make(map[string][]types.Bar),
+		periods: make(map[string]time.Duration),
+	}
+}
+
+// Add stores bars for a currency pair instrument, named BASE_QUOTE, sorting them by time
+func (t *RateTable) Add(instrument string, bars []types.Bar) {
+	sorted := make([]types.Bar, len(bars))
+	copy(sorted, bars)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Timestamp.Before(sorted[j].Timestamp)
+	})
+	t.pairs[instrument] = sorted
+	t.periods[instrument] = barPeriod(sorted)
+}
+
+// Load fetches bars for a currency pair instrument from the source and adds them
+func (t *RateTable) Load(ctx context.Context, source DataSource, req Request) error {
+	bars, err := source.FetchBars(ctx, req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch %s rates: %w", req.Instrument, err)
+	}
+	if len(bars) == 0 {
+		return fmt.Errorf("no %s rates between %s and %s", req.Instrument, req.From, req.To)
+	}
+	t.Add(req.Instrument, bars)
+	return nil
+}
+
+// Rate returns how many units of to one unit of from was worth at the given time.
+// The rate is the open of a bar starting exactly at that time, otherwise the close of the last bar before it,
+// as long as that bar ended no more than MaxAge ago.
+func (t *RateTable) Rate(from, to string, at time.Time) (float64, error) {
+	if from == to {
+		return 1, nil
+	}
+
+	if rate, ok := t.rateAt(from+"_"+to, at); ok {
+		return rate, nil
+	}
+	if rate, ok := t.rateAt(to+"_"+from, at); ok {
+		return 1 / rate, nil
+	}
+	return 0, fmt.Errorf("no %s/%s rate at %s", strings.ToUpper(from), strings.ToUpper(to), at)
+}
+
+// rateAt returns the pair's rate at the given time, if it has a bar before it that isn't stale
+func (t *RateTable) rateAt(pair string, at time.Time) (float64, bool) {
+	bars, ok := t.pairs[pair]
+	if !ok {
+		return 0, false
+	}
+
+	// First bar starting after at
+	i := sort.Search(len(bars), func(i int) bool {
+		return bars[i].Timestamp.After(at)
+	})
+	if i == 0 {
+		return 0, false
+	}
+
+	bar := bars[i-1]
+	if bar.Timestamp.Equal(at) {
+		return bar.Open, true
+	}
+
+	maxAge := t.MaxAge
+	if maxAge == 0 {
+		maxAge = staleRatePeriods * t.periods[pair]
+	}
+	if maxAge > 0 && at.Sub(bar.Timestamp.Add(t.periods[pair])) > maxAge {
+		return 0, false
+	}
+	return bar.Close, true
+}
+
+// barPeriod returns the smallest gap between sorted bars, zero if there's fewer than 2
+func barPeriod(bars []types.Bar) time.Duration {
+	var smallest time.Duration
+	for i := 1; i < len(bars); i++ {
+		if gap := bars[i].Timestamp.Sub(bars[i-1].Timestamp); gap > 0 && (smallest == 0 || gap < smallest) {
+			smallest = gap
+		}
+	}
+	return smallest
+}
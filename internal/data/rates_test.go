@@ -0,0 +1,64 @@
+package data
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jwtly10/tradebook/internal/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateTable_Rate(t *testing.T) {
+	start := time.Date(2025, 1, 8, 12, 0, 0, 0, time.UTC)
+	rates := NewRateTable()
+	rates.Add("GBP_USD", []types.Bar{
+		{Timestamp: start.Add(time.Hour), Open: 1.26, Close: 1.28},
+		{Timestamp: start, Open: 1.24, Close: 1.25},
+	})
+
+	rate, err := rates.Rate("GBP", "USD", start.Add(30*time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, 1.25, rate)
+
+	rate, err = rates.Rate("GBP", "USD", start.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 1.26, rate, "A bar starting now has only opened")
+
+	rate, err = rates.Rate("USD", "GBP", start.Add(2*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 1/1.28, rate)
+
+	rate, err = rates.Rate("USD", "USD", start)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(1), rate)
+
+	_, err = rates.Rate("GBP", "USD", start.Add(-time.Minute))
+	assert.Error(t, err, "No rate before the first bar")
+
+	_, err = rates.Rate("EUR", "USD", start)
+	assert.Error(t, err)
+}
+
+func TestRateTable_RateIsStaleAfterTheLastBar(t *testing.T) {
+	start := time.Date(2025, 1, 8, 12, 0, 0, 0, time.UTC)
+	rates := NewRateTable()
+	rates.Add("GBP_USD", []types.Bar{
+		{Timestamp: start, Open: 1.24, Close: 1.25},
+		{Timestamp: start.Add(time.Hour), Open: 1.26, Close: 1.28},
+	})
+	end := start.Add(2 * time.Hour)
+
+	// A few periods after the last bar ends
+	rate, err := rates.Rate("USD", "GBP", end.Add(5*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 1/1.28, rate)
+
+	_, err = rates.Rate("USD", "GBP", end.Add(5*time.Hour+time.Minute))
+	assert.Error(t, err, "Rates that ended before the bars being converted are stale")
+
+	rates.MaxAge = 30 * time.Minute
+	_, err = rates.Rate("GBP", "USD", end.Add(30*time.Minute))
+	assert.NoError(t, err)
+	_, err = rates.Rate("GBP", "USD", end.Add(31*time.Minute))
+	assert.Error(t, err)
+}
@@ -0,0 +1,242 @@
+package data
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jwtly10/tradebook/internal/types"
+)
+
+// Alignment decides where bars of a granularity start, mirroring Oanda's
+// dailyAlignment, alignmentTimezone and weeklyAlignment candle parameters
+type Alignment struct {
+	Location  *time.Location
+	DailyHour int          // Hour of the day, in Location, daily bars start
+	WeeklyDay time.Weekday // Day weekly bars start on
+}
+
+// DefaultAlignment matches Oanda's defaults, daily bars roll over at 17:00 New York and weeks start on Friday
+func DefaultAlignment() Alignment {
+	return Alignment{
+		Location:  newYork(),
+		DailyHour: 17,
+		WeeklyDay: time.Friday,
+	}
+}
+
+func (a Alignment) location() *time.Location {
+	if a.Location == nil {
+		return time.UTC
+	}
+	return a.Location
+}
+
+// dayStart returns the daily rollover at or before t
+func (a Alignment) dayStart(t time.Time) time.Time {
+	local := t.In(a.location())
+	start := time.Date(local.Year(), local.Month(), local.Day(), a.DailyHour, 0, 0, 0, a.location())
+	if start.After(local) {
+		start = time.Date(local.Year(), local.Month(), local.Day()-1, a.DailyHour, 0, 0, 0, a.location())
+	}
+	return start
+}
+
+// nextDay returns the rollover after the one at start, which isn't always 24 hours later across DST changes
+func (a Alignment) nextDay(start time.Time) time.Time {
+	local := start.In(a.location())
+	return time.Date(local.Year(), local.Month(), local.Day()+1, a.DailyHour, 0, 0, 0, a.location())
+}
+
+// Start returns the start of the bar at granularity g that contains t.
+// Intraday bars are counted from the daily rollover, so H4 bars start at 17:00, 21:00, 01:00... New York by default
+func (a Alignment) Start(g types.Granularity, t time.Time) (time.Time, error) {
+	switch g {
+	case types.M:
+		local := t.In(a.location())
+		start := time.Date(local.Year(), local.Month(), 1, a.DailyHour, 0, 0, 0, a.location())
+		if start.After(local) {
+			start = time.Date(local.Year(), local.Month()-1, 1, a.DailyHour, 0, 0, 0, a.location())
+		}
+		return start, nil
+	case types.W:
+		start := a.dayStart(t)
+		back := (int(start.Weekday()) - int(a.WeeklyDay) + 7) % 7
+		local := start.In(a.location())
+		return time.Date(local.Year(), local.Month(), local.Day()-back, a.DailyHour, 0, 0, 0, a.location()), nil
+	case types.D:
+		return a.dayStart(t), nil
+	}
+
+	duration, err := g.ToDuration()
+	if err != nil {
+		return time.Time{}, err
+	}
+	day := a.dayStart(t)
+	return day.Add(t.Sub(day).Truncate(duration)), nil
+}
+
+// End returns the start of the bar after the one at granularity g that contains t.
+// The last intraday bar of a day is cut short at the rollover when the day isn't a multiple of the granularity
+func (a Alignment) End(g types.Granularity, t time.Time) (time.Time, error) {
+	start, err := a.Start(g, t)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	local := start.In(a.location())
+	switch g {
+	case types.M:
+		return time.Date(local.Year(), local.Month()+1, 1, a.DailyHour, 0, 0, 0, a.location()), nil
+	case types.W:
+		return time.Date(local.Year(), local.Month(), local.Day()+7, a.DailyHour, 0, 0, 0, a.location()), nil
+	case types.D:
+		return a.nextDay(start), nil
+	}
+
+	end := start.Add(g.MustToDuration())
+	if rollover := a.nextDay(a.dayStart(t)); end.After(rollover) {
+		end = rollover
+	}
+	return end, nil
+}
+
+// Resample aggregates bars of the source granularity into bars of the target granularity.
+// Bars are timestamped at the start of their period. A resampled bar is marked Partial when any
+// bar it was built from is partial, or when it sits at either end of the input and the input
+// doesn't cover its whole period. Use Complete to drop them.
+func Resample(bars []types.Bar, source, target types.Granularity, alignment Alignment) ([]types.Bar, error) {
+	sourceDuration, err := source.ToDuration()
+	if err != nil {
+		return nil, err
+	}
+	targetDuration, err := target.ToDuration()
+	if err != nil {
+		return nil, err
+	}
+	if targetDuration <= sourceDuration {
+		return nil, fmt.Errorf("cannot resample %s bars to %s", source, target)
+	}
+	if len(bars) == 0 {
+		return nil, nil
+	}
+
+	var out []types.Bar
+	var current types.Bar
+	var currentEnd time.Time
+	withQuotes := true
+
+	for i, bar := range bars {
+		if i > 0 && !bar.Timestamp.After(bars[i-1].Timestamp) {
+			return nil, fmt.Errorf("bars must be in ascending order, bar %d at %s", i, bar.Timestamp)
+		}
+
+		start, err := alignment.Start(target, bar.Timestamp)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(out) == 0 || !start.Equal(current.Timestamp) {
+			if len(out) > 0 {
+				out[len(out)-1] = finishBar(current, withQuotes)
+			}
+			current = types.Bar{
+				Timestamp: start,
+				Open:      bar.Open,
+				High:      bar.High,
+				Low:       bar.Low,
+				Bid:       bar.Bid,
+				Ask:       bar.Ask,
+				// Data starting part way through the first period means we haven't seen all of it
+				Partial: i == 0 && bar.Timestamp.After(start),
+			}
+			currentEnd, err = alignment.End(target, bar.Timestamp)
+			if err != nil {
+				return nil, err
+			}
+			withQuotes = true
+			out = append(out, current)
+		}
+
+		current.High = max(current.High, bar.High)
+		current.Low = min(current.Low, bar.Low)
+		current.Close = bar.Close
+		current.Volume += bar.Volume
+		current.Spread = bar.Spread
+		current.Partial = current.Partial || bar.Partial
+		if bar.HasQuotes() {
+			current.Bid = mergeOHLC(current.Bid, bar.Bid)
+			current.Ask = mergeOHLC(current.Ask, bar.Ask)
+		} else {
+			withQuotes = false
+		}
+	}
+
+	// Likewise for data stopping before the last period has finished
+	last := bars[len(bars)-1]
+	lastEnd, err := alignment.End(source, last.Timestamp)
+	if err != nil {
+		return nil, err
+	}
+	if lastEnd.Before(currentEnd) {
+		current.Partial = true
+	}
+	out[len(out)-1] = finishBar(current, withQuotes)
+
+	return out, nil
+}
+
+// Complete drops partial bars
+func Complete(bars []types.Bar) []types.Bar {
+	out := make([]types.Bar, 0, len(bars))
+	for _, bar := range bars {
+		if !bar.Partial {
+			out = append(out, bar)
+		}
+	}
+	return out
+}
+
+func mergeOHLC(agg, next types.OHLC) types.OHLC {
+	agg.High = max(agg.High, next.High)
+	agg.Low = min(agg.Low, next.Low)
+	agg.Close = next.Close
+	return agg
+}
+
+// finishBar clears bid/ask prices unless every bar in the period carried them
+func finishBar(bar types.Bar, withQuotes bool) types.Bar {
+	if !withQuotes {
+		bar.Bid = types.OHLC{}
+		bar.Ask = types.OHLC{}
+	}
+	return bar
+}
+
+// Aligner exposes a higher timeframe series alongside a base series without look-ahead,
+// only bars whose period has finished by a given time are visible
+type Aligner struct {
+	Bars []types.Bar
+	ends []time.Time
+}
+
+// NewAligner prepares bars of granularity g, which must be in ascending order
+func NewAligner(bars []types.Bar, g types.Granularity, alignment Alignment) (*Aligner, error) {
+	ends := make([]time.Time, len(bars))
+	for i, bar := range bars {
+		end, err := alignment.End(g, bar.Timestamp)
+		if err != nil {
+			return nil, err
+		}
+		ends[i] = end
+	}
+	return &Aligner{Bars: bars, ends: ends}, nil
+}
+
+// ClosedBy returns the bars that had closed at or before t, the last being the most recent
+func (a *Aligner) ClosedBy(t time.Time) []types.Bar {
+	n := sort.Search(len(a.ends), func(i int) bool {
+		return a.ends[i].After(t)
+	})
+	return a.Bars[:n]
+}
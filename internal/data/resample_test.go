@@ -0,0 +1,107 @@
+package data
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jwtly10/tradebook/internal/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResample_AggregatesOHLCV(t *testing.T) {
+	start := time.Date(2025, 1, 8, 12, 15, 0, 0, time.UTC)
+	var bars []types.Bar
+	for i := 0; i < 10; i++ {
+		price := float64(100 + i)
+		bars = append(bars, types.Bar{
+			Timestamp: start.Add(time.Duration(i) * 15 * time.Minute),
+			Open:      price,
+			High:      price + 2,
+			Low:       price - 1,
+			Close:     price + 1,
+			Volume:    10,
+			Spread:    float64(i),
+		})
+	}
+
+	out, err := Resample(bars, types.M15, types.H1, DefaultAlignment())
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(out))
+
+	// 12:15 - 12:45, missing the first quarter
+	assert.Equal(t, time.Date(2025, 1, 8, 12, 0, 0, 0, time.UTC), out[0].Timestamp.UTC())
+	assert.True(t, out[0].Partial)
+	assert.Equal(t, 30.0, out[0].Volume)
+
+	// 13:00 - 13:45
+	assert.False(t, out[1].Partial)
+	assert.Equal(t, types.Bar{
+		Timestamp: out[1].Timestamp,
+		Open:      103,
+		High:      108,
+		Low:       102,
+		Close:     107,
+		Volume:    40,
+		Spread:    6,
+	}, out[1])
+
+	// 14:00 - 14:30, data stops before the hour finishes
+	assert.True(t, out[2].Partial)
+	assert.Equal(t, 1, len(Complete(out)))
+}
+
+func TestResample_AlignsDailyBarsToRollover(t *testing.T) {
+	// 17:00 New York
+	start := time.Date(2025, 1, 7, 22, 0, 0, 0, time.UTC)
+	var bars []types.Bar
+	for i := 0; i < 26; i++ {
+		bars = append(bars, types.Bar{
+			Timestamp: start.Add(time.Duration(i) * time.Hour),
+			Open:      1, High: 2, Low: 0.5, Close: 1.5, Volume: 1,
+			Bid: types.OHLC{Open: 0.9, High: 1.9, Low: 0.4, Close: 1.4},
+			Ask: types.OHLC{Open: 1.1, High: 2.1, Low: 0.6, Close: 1.6},
+		})
+	}
+
+	daily, err := Resample(bars, types.H1, types.D, DefaultAlignment())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(daily))
+	assert.Equal(t, start, daily[0].Timestamp.UTC())
+	assert.False(t, daily[0].Partial)
+	assert.Equal(t, 24.0, daily[0].Volume)
+	assert.Equal(t, types.OHLC{Open: 0.9, High: 1.9, Low: 0.4, Close: 1.4}, daily[0].Bid)
+	assert.True(t, daily[1].Partial)
+
+	h4, err := Resample(bars, types.H1, types.H4, DefaultAlignment())
+	assert.NoError(t, err)
+	assert.Equal(t, 7, len(h4))
+	// Counted from the rollover, 17:00 then 21:00 New York
+	assert.Equal(t, start.Add(4*time.Hour), h4[1].Timestamp.UTC())
+}
+
+func TestResample_MarksPartialSourceBars(t *testing.T) {
+	start := time.Date(2025, 1, 8, 12, 0, 0, 0, time.UTC)
+	bars := []types.Bar{
+		{Timestamp: start, Open: 1, High: 1, Low: 1, Close: 1},
+		{Timestamp: start.Add(30 * time.Minute), Open: 1, High: 1, Low: 1, Close: 1, Partial: true},
+	}
+
+	out, err := Resample(bars, types.M30, types.H1, DefaultAlignment())
+	assert.NoError(t, err)
+	assert.True(t, out[0].Partial)
+
+	_, err = Resample(bars, types.H1, types.M30, DefaultAlignment())
+	assert.Error(t, err)
+}
+
+func TestAlignment_Weekly(t *testing.T) {
+	a := DefaultAlignment()
+	// Wednesday
+	start, err := a.Start(types.W, time.Date(2025, 1, 8, 12, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 1, 3, 22, 0, 0, 0, time.UTC), start.UTC())
+
+	end, err := a.End(types.W, start)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 1, 10, 22, 0, 0, 0, time.UTC), end.UTC())
+}
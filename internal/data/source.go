@@ -0,0 +1,49 @@
+package data
+
+import (
+	"context"
+	"time"
+
+	"github.com/jwtly10/tradebook/internal/instrument"
+	"github.com/jwtly10/tradebook/internal/types"
+)
+
+// DataSource provides bars and instrument metadata to the backtest engine,
+// so the engine doesn't care whether data comes from a broker, a file or a generator.
+type DataSource interface {
+	// FetchBars returns every bar starting in [From, To), sorted by timestamp
+	FetchBars(ctx context.Context, req Request) ([]types.Bar, error)
+	// Instruments lists the instruments the source can provide bars for
+	Instruments(ctx context.Context) ([]types.Instrument, error)
+	// Instrument returns metadata for a single instrument
+	Instrument(ctx context.Context, name string) (types.Instrument, error)
+}
+
+// Request describes a range of bars to load from a DataSource
+type Request struct {
+	Instrument  string
+	Granularity types.Granularity
+	Price       types.PriceComponent // Defaults to mid prices
+	From        time.Time
+	To          time.Time
+}
+
+// lookupInstrument returns the registry's metadata for the instrument, or just its name when it isn't known
+func lookupInstrument(name string) types.Instrument {
+	meta, err := instrument.Lookup(name)
+	if err != nil {
+		return types.Instrument{Name: name}
+	}
+	return meta
+}
+
+// Between returns the bars starting in [from, to)
+func Between(bars []types.Bar, from, to time.Time) []types.Bar {
+	var result []types.Bar
+	for _, bar := range bars {
+		if !bar.Timestamp.Before(from) && bar.Timestamp.Before(to) {
+			result = append(result, bar)
+		}
+	}
+	return result
+}
@@ -0,0 +1,177 @@
+package data
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jwtly10/tradebook/internal/types"
+)
+
+const (
+	DUPLICATE_TIMESTAMP IssueKind = "DUPLICATE_TIMESTAMP"
+	OUT_OF_ORDER        IssueKind = "OUT_OF_ORDER"
+	INVALID_RANGE       IssueKind = "INVALID_RANGE"
+	ZERO_VOLUME         IssueKind = "ZERO_VOLUME"
+)
+
+type IssueKind string
+
+// Issue is a problem with a single bar
+type Issue struct {
+	Kind      IssueKind
+	Index     int
+	Timestamp time.Time
+	Message   string
+}
+
+// Gap is a run of bars missing while the market was open
+type Gap struct {
+	After   time.Time // Timestamp of the last bar before the gap
+	Before  time.Time // Timestamp of the first bar after the gap
+	Missing int       // Number of bars expected while the market was open
+}
+
+// Report is the result of validating a series of bars
+type Report struct {
+	Bars   int
+	Issues []Issue
+	Gaps   []Gap
+}
+
+// Clean returns true if no issues or gaps were found
+func (r *Report) Clean() bool {
+	return len(r.Issues) == 0 && len(r.Gaps) == 0
+}
+
+// Count returns the number of issues of the given kind
+func (r *Report) Count(kind IssueKind) int {
+	count := 0
+	for _, issue := range r.Issues {
+		if issue.Kind == kind {
+			count++
+		}
+	}
+	return count
+}
+
+// Summary describes the report in a single line
+func (r *Report) Summary() string {
+	if r.Clean() {
+		return fmt.Sprintf("%d bars, no issues", r.Bars)
+	}
+
+	parts := []string{fmt.Sprintf("%d bars", r.Bars)}
+	for _, kind := range []IssueKind{DUPLICATE_TIMESTAMP, OUT_OF_ORDER, INVALID_RANGE, ZERO_VOLUME} {
+		if count := r.Count(kind); count > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", count, strings.ToLower(string(kind))))
+		}
+	}
+	if len(r.Gaps) > 0 {
+		parts = append(parts, fmt.Sprintf("%d gaps", len(r.Gaps)))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (r *Report) Print() {
+	fmt.Println("\n=== Data Quality ===")
+	fmt.Println(r.Summary())
+	for _, issue := range r.Issues {
+		fmt.Printf("#%d | %s | %s | %s\n", issue.Index, issue.Timestamp.Format("2006-01-02 15:04"), issue.Kind, issue.Message)
+	}
+	for _, gap := range r.Gaps {
+		fmt.Printf("GAP | %s -> %s | %d missing bars\n", gap.After.Format("2006-01-02 15:04"), gap.Before.Format("2006-01-02 15:04"), gap.Missing)
+	}
+}
+
+// Validator checks bars for data problems, using the granularity and trading calendar to
+// tell missing data apart from the market being closed
+type Validator struct {
+	Granularity types.Granularity
+	Calendar    Calendar // Defaults to AlwaysOpen
+	// MaxMissingBars is the number of consecutive missing bars tolerated while the market is open,
+	// since quiet periods can legitimately have no ticks
+	MaxMissingBars int
+	// AllowZeroVolume skips reporting bars with no volume
+	AllowZeroVolume bool
+}
+
+func NewValidator(granularity types.Granularity, calendar Calendar) *Validator {
+	if calendar == nil {
+		calendar = AlwaysOpen{}
+	}
+	return &Validator{
+		Granularity: granularity,
+		Calendar:    calendar,
+	}
+}
+
+// Validate checks every bar, returning an error only if the validator itself is misconfigured
+func (v *Validator) Validate(bars []types.Bar) (*Report, error) {
+	period, err := v.Granularity.ToDuration()
+	if err != nil {
+		return nil, err
+	}
+	calendar := v.Calendar
+	if calendar == nil {
+		calendar = AlwaysOpen{}
+	}
+
+	report := &Report{Bars: len(bars)}
+	issue := func(kind IssueKind, i int, format string, args ...any) {
+		report.Issues = append(report.Issues, Issue{
+			Kind:      kind,
+			Index:     i,
+			Timestamp: bars[i].Timestamp,
+			Message:   fmt.Sprintf(format, args...),
+		})
+	}
+
+	latestIndex := 0
+	for i, bar := range bars {
+		if bar.High < bar.Low {
+			issue(INVALID_RANGE, i, "high %v is below low %v", bar.High, bar.Low)
+		} else if bar.Open > bar.High || bar.Open < bar.Low || bar.Close > bar.High || bar.Close < bar.Low {
+			issue(INVALID_RANGE, i, "open %v or close %v is outside the range %v - %v", bar.Open, bar.Close, bar.Low, bar.High)
+		}
+
+		if bar.Volume == 0 && !v.AllowZeroVolume {
+			issue(ZERO_VOLUME, i, "bar has no volume")
+		}
+
+		if i == 0 {
+			continue
+		}
+
+		// Compare against the latest bar seen, so a single out of order bar doesn't also create gaps
+		latest := bars[latestIndex]
+		switch {
+		case bar.Timestamp.Equal(latest.Timestamp):
+			issue(DUPLICATE_TIMESTAMP, i, "same timestamp as bar %d", latestIndex)
+		case bar.Timestamp.Before(latest.Timestamp):
+			issue(OUT_OF_ORDER, i, "before bar %d at %s", latestIndex, latest.Timestamp.Format(time.RFC3339))
+		default:
+			latestIndex = i
+			// Weekly and monthly bars don't have a fixed period to step through
+			if v.Granularity == types.W || v.Granularity == types.M {
+				continue
+			}
+			if missing := v.missingBars(latest.Timestamp, bar.Timestamp, period, calendar); missing > v.MaxMissingBars {
+				report.Gaps = append(report.Gaps, Gap{After: latest.Timestamp, Before: bar.Timestamp, Missing: missing})
+			}
+		}
+	}
+
+	return report, nil
+}
+
+// missingBars counts the bar start times between two bars that fall while the market is open
+func (v *Validator) missingBars(after, before time.Time, period time.Duration, calendar Calendar) int {
+	missing := 0
+	for t := after.Add(period); t.Before(before); t = t.Add(period) {
+		if calendar.IsOpen(t) {
+			missing++
+		}
+	}
+	return missing
+}
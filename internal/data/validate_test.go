@@ -0,0 +1,144 @@
+package data
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jwtly10/tradebook/internal/instrument"
+	"github.com/jwtly10/tradebook/internal/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func hourlyBar(ts time.Time) types.Bar {
+	return types.Bar{Timestamp: ts, Open: 1, High: 2, Low: 0.5, Close: 1.5, Volume: 10}
+}
+
+func TestValidator_ReportsBarIssues(t *testing.T) {
+	// Wednesday
+	start := time.Date(2025, 1, 8, 12, 0, 0, 0, time.UTC)
+	bars := []types.Bar{
+		hourlyBar(start),
+		hourlyBar(start.Add(time.Hour)),
+		hourlyBar(start.Add(time.Hour)),
+		hourlyBar(start.Add(30 * time.Minute)),
+		{Timestamp: start.Add(2 * time.Hour), Open: 1, High: 0.5, Low: 2, Close: 1, Volume: 10},
+		{Timestamp: start.Add(3 * time.Hour), Open: 3, High: 2, Low: 0.5, Close: 1, Volume: 10},
+		{Timestamp: start.Add(4 * time.Hour), Open: 1, High: 2, Low: 0.5, Close: 1},
+	}
+
+	report, err := NewValidator(types.H1, FXCalendar()).Validate(bars)
+	assert.NoError(t, err)
+
+	assert.False(t, report.Clean())
+	assert.Equal(t, 1, report.Count(DUPLICATE_TIMESTAMP))
+	assert.Equal(t, 1, report.Count(OUT_OF_ORDER))
+	assert.Equal(t, 2, report.Count(INVALID_RANGE))
+	assert.Equal(t, 1, report.Count(ZERO_VOLUME))
+	assert.Equal(t, 6, report.Issues[len(report.Issues)-1].Index)
+	assert.Equal(t, 0, len(report.Gaps))
+	assert.Equal(t, "7 bars, 1 duplicate_timestamp, 1 out_of_order, 2 invalid_range, 1 zero_volume", report.Summary())
+}
+
+func TestValidator_IgnoresWeekendsButReportsGaps(t *testing.T) {
+	// Friday 16:00 New York, the last hour before the weekend
+	friday := time.Date(2025, 1, 10, 21, 0, 0, 0, time.UTC)
+	// Sunday 17:00 New York, the first hour after the weekend
+	sunday := time.Date(2025, 1, 12, 22, 0, 0, 0, time.UTC)
+
+	bars := []types.Bar{
+		hourlyBar(friday),
+		hourlyBar(sunday),
+		hourlyBar(sunday.Add(time.Hour)),
+		// Three bars missing mid session
+		hourlyBar(sunday.Add(5 * time.Hour)),
+	}
+
+	report, err := NewValidator(types.H1, FXCalendar()).Validate(bars)
+	assert.NoError(t, err)
+	assert.Equal(t, []Gap{{After: sunday.Add(time.Hour), Before: sunday.Add(5 * time.Hour), Missing: 3}}, report.Gaps)
+
+	validator := NewValidator(types.H1, FXCalendar())
+	validator.MaxMissingBars = 3
+	report, err = validator.Validate(bars)
+	assert.NoError(t, err)
+	assert.True(t, report.Clean())
+
+	// Without a calendar the weekend is a gap too
+	report, err = NewValidator(types.H1, nil).Validate(bars)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(report.Gaps))
+}
+
+func TestValidator_IgnoresHolidays(t *testing.T) {
+	calendar, err := CalendarFor("GBP_USD")
+	assert.NoError(t, err)
+
+	// Christmas closes the trading day from 17:00 New York on the 24th to 17:00 on the 25th
+	var bars []types.Bar
+	for ts := time.Date(2024, 12, 24, 12, 0, 0, 0, time.UTC); ts.Before(time.Date(2024, 12, 24, 22, 0, 0, 0, time.UTC)); ts = ts.Add(time.Hour) {
+		bars = append(bars, hourlyBar(ts))
+	}
+	for ts := time.Date(2024, 12, 25, 22, 0, 0, 0, time.UTC); ts.Before(time.Date(2024, 12, 26, 4, 0, 0, 0, time.UTC)); ts = ts.Add(time.Hour) {
+		bars = append(bars, hourlyBar(ts))
+	}
+
+	report, err := NewValidator(types.H1, calendar).Validate(bars)
+	assert.NoError(t, err)
+	assert.True(t, report.Clean(), report.Summary())
+
+	// Missing bars after the reopen are still gaps
+	report, err = NewValidator(types.H1, calendar).Validate(append(bars[:11:11], bars[12:]...))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(report.Gaps))
+}
+
+func TestIndexCFDCalendar(t *testing.T) {
+	calendar := IndexCFDCalendar()
+	ny := calendar.Location
+
+	assert.True(t, calendar.IsOpen(time.Date(2025, 1, 8, 10, 0, 0, 0, ny)))
+	assert.False(t, calendar.IsOpen(time.Date(2025, 1, 8, 17, 30, 0, 0, ny)), "Daily break")
+	assert.False(t, calendar.IsOpen(time.Date(2025, 1, 11, 12, 0, 0, 0, ny)), "Saturday")
+	assert.False(t, calendar.IsOpen(time.Date(2025, 1, 12, 17, 30, 0, 0, ny)), "Sunday before the open")
+	assert.True(t, calendar.IsOpen(time.Date(2025, 1, 12, 18, 0, 0, 0, ny)))
+
+	calendar.Holidays = []time.Time{time.Date(2025, 1, 8, 0, 0, 0, 0, ny)}
+	assert.False(t, calendar.IsOpen(time.Date(2025, 1, 8, 10, 0, 0, 0, ny)))
+	assert.False(t, calendar.IsOpen(time.Date(2025, 1, 7, 19, 0, 0, 0, ny)), "The trading day starts the evening before")
+	assert.True(t, calendar.IsOpen(time.Date(2025, 1, 8, 19, 0, 0, 0, ny)))
+	assert.False(t, calendar.IsOpen(time.Date(2025, 1, 1, 10, 0, 0, 0, ny)), "New year's day")
+}
+
+func TestNewSessionCalendar(t *testing.T) {
+	calendar, err := NewSessionCalendar(instrument.Hours{
+		Timezone: "America/New_York",
+		Open:     "Sunday 18:00",
+		Close:    "Friday 17:00",
+		Breaks:   []string{"17:00-18:00"},
+		Holidays: []string{"01-01", "12-25"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, IndexCFDCalendar().DailyBreaks, calendar.DailyBreaks)
+	assert.Equal(t, IndexCFDCalendar().AnnualHolidays, calendar.AnnualHolidays)
+	assert.Equal(t, time.Sunday, calendar.OpenDay)
+	assert.Equal(t, 18*time.Hour, calendar.OpenTime)
+	assert.Equal(t, time.Friday, calendar.CloseDay)
+	assert.Equal(t, 17*time.Hour, calendar.CloseTime)
+
+	_, err = NewSessionCalendar(instrument.Hours{Timezone: "UTC", Open: "Someday 18:00", Close: "Friday 17:00"})
+	assert.ErrorContains(t, err, "invalid weekday")
+	_, err = NewSessionCalendar(instrument.Hours{Timezone: "UTC", Open: "Sunday 18:00", Close: "Friday 17:00", Breaks: []string{"17:00"}})
+	assert.ErrorContains(t, err, "invalid break")
+	_, err = NewSessionCalendar(instrument.Hours{Timezone: "UTC", Open: "Sunday 18:00", Close: "Friday 17:00", Holidays: []string{"25 Dec"}})
+	assert.ErrorContains(t, err, "invalid holiday")
+}
+
+func TestCalendarFor(t *testing.T) {
+	calendar, err := CalendarFor("GBP_USD")
+	assert.NoError(t, err)
+	// FX opens an hour before index CFDs on Sunday
+	assert.True(t, calendar.IsOpen(time.Date(2025, 1, 12, 22, 30, 0, 0, time.UTC)))
+
+	_, err = CalendarFor("UNKNOWN")
+	assert.Error(t, err)
+}
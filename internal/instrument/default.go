@@ -0,0 +1,39 @@
+package instrument
+
+import (
+	"sync"
+
+	"github.com/jwtly10/tradebook/internal/types"
+)
+
+var (
+	defaultMu       sync.RWMutex
+	defaultRegistry = mustBundled()
+)
+
+func mustBundled() *Registry {
+	r, err := Bundled()
+	if err != nil {
+		panic("instrument: bundled instruments are invalid: " + err.Error())
+	}
+	return r
+}
+
+// Default returns the registry used by Lookup, holding the bundled instruments until replaced
+func Default() *Registry {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultRegistry
+}
+
+// SetDefault replaces the registry used by Lookup, eg with one loaded from a broker
+func SetDefault(r *Registry) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultRegistry = r
+}
+
+// Lookup returns the metadata for the instrument from the default registry
+func Lookup(name string) (types.Instrument, error) {
+	return Default().Get(name)
+}
@@ -0,0 +1,278 @@
+// Package instrument holds metadata for tradeable instruments, so pip sizes, trade limits and
+// trading hours come from one place rather than being hard coded wherever they're needed.
+package instrument
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jwtly10/tradebook/internal/account"
+	"github.com/jwtly10/tradebook/internal/types"
+)
+
+// bundled is the offline fallback, used until metadata is loaded from a broker
+//
+//go:embed instruments.json
+var bundled []byte
+
+// Hours are an instrument's weekly trading session, eg open "Sunday 17:00" and close "Friday 17:00"
+// in America/New_York, with daily breaks as "17:00-18:00" and trading days closed every year as "12-25"
+type Hours struct {
+	Timezone string   `json:"timezone"`
+	Open     string   `json:"open"`
+	Close    string   `json:"close"`
+	Breaks   []string `json:"breaks,omitempty"`
+	Holidays []string `json:"holidays,omitempty"`
+}
+
+// Source provides instrument metadata, data.DataSource satisfies it
+type Source interface {
+	Instruments(ctx context.Context) ([]types.Instrument, error)
+}
+
+// Registry holds metadata and trading hours per instrument. It's safe for concurrent use.
+type Registry struct {
+	mu          sync.RWMutex
+	instruments map[string]types.Instrument
+	hours       map[string]Hours
+}
+
+// entry is an instrument as stored in the bundled file
+type entry struct {
+	Name                        string  `json:"name"`
+	DisplayName                 string  `json:"displayName"`
+	Type                        string  `json:"type"`
+	PipLocation                 int     `json:"pipLocation"`
+	DisplayPrecision            int     `json:"displayPrecision"`
+	TradeUnitsPrecision         int     `json:"tradeUnitsPrecision"`
+	MinimumTradeSize            float64 `json:"minimumTradeSize"`
+	MaximumOrderUnits           float64 `json:"maximumOrderUnits"`
+	MinimumStopDistance         float64 `json:"minimumStopDistance"`
+	MinimumTrailingStopDistance float64 `json:"minimumTrailingStopDistance"`
+	MarginRate                  float64 `json:"marginRate"`
+	QuoteCurrency               string  `json:"quoteCurrency"`
+	Financing                   struct {
+		Long      float64 `json:"longRate"`
+		Short     float64 `json:"shortRate"`
+		TripleDay string  `json:"tripleDay"`
+	} `json:"financing"`
+	Hours *Hours `json:"hours"`
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		instruments: make(map[string]types.Instrument),
+		hours:       make(map[string]Hours),
+	}
+}
+
+// Bundled returns a registry holding the instruments in the bundled file
+func Bundled() (*Registry, error) {
+	return Parse(bundled)
+}
+
+// Parse returns a registry holding the instruments in a JSON file in the bundled format
+func Parse(file []byte) (*Registry, error) {
+	var entries []entry
+	if err := json.Unmarshal(file, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse instruments: %w", err)
+	}
+
+	r := NewRegistry()
+	for _, e := range entries {
+		if e.Name == "" {
+			return nil, fmt.Errorf("instrument without a name")
+		}
+		tripleDay, err := parseWeekday(e.Financing.TripleDay)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse financing triple day of %s: %w", e.Name, err)
+		}
+		r.Set(types.Instrument{
+			Name:                        e.Name,
+			DisplayName:                 e.DisplayName,
+			Type:                        e.Type,
+			PipLocation:                 e.PipLocation,
+			DisplayPrecision:            e.DisplayPrecision,
+			MarginRate:                  e.MarginRate,
+			QuoteCurrency:               e.QuoteCurrency,
+			TradeUnitsPrecision:         e.TradeUnitsPrecision,
+			MinimumTradeSize:            e.MinimumTradeSize,
+			MaximumOrderUnits:           e.MaximumOrderUnits,
+			MinimumStopDistance:         e.MinimumStopDistance,
+			MinimumTrailingStopDistance: e.MinimumTrailingStopDistance,
+			FinancingLong:               e.Financing.Long,
+			FinancingShort:              e.Financing.Short,
+			FinancingTripleDay:          tripleDay,
+		})
+		if e.Hours != nil {
+			r.SetHours(e.Name, *e.Hours)
+		}
+	}
+	return r, nil
+}
+
+// Set stores metadata for an instrument, replacing any already held
+func (r *Registry) Set(instrument types.Instrument) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.instruments[instrument.Name] = instrument
+}
+
+// SetHours stores the trading hours for an instrument
+func (r *Registry) SetHours(name string, hours Hours) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hours[name] = hours
+}
+
+// Get returns the metadata for the instrument
+func (r *Registry) Get(name string) (types.Instrument, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	instrument, ok := r.instruments[name]
+	if !ok {
+		return types.Instrument{}, fmt.Errorf("unknown instrument: %s", name)
+	}
+	return instrument, nil
+}
+
+// Hours returns the trading hours for the instrument, false if they aren't known
+func (r *Registry) Hours(name string) (Hours, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	hours, ok := r.hours[name]
+	return hours, ok
+}
+
+// All returns every instrument, sorted by name
+func (r *Registry) All() []types.Instrument {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	instruments := make([]types.Instrument, 0, len(r.instruments))
+	for _, instrument := range r.instruments {
+		instruments = append(instruments, instrument)
+	}
+	sort.Slice(instruments, func(i, j int) bool { return instruments[i].Name < instruments[j].Name })
+	return instruments
+}
+
+// Map returns every instrument by name, for account.Account.Instruments
+func (r *Registry) Map() map[string]types.Instrument {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	instruments := make(map[string]types.Instrument, len(r.instruments))
+	for name, instrument := range r.instruments {
+		instruments[name] = instrument
+	}
+	return instruments
+}
+
+// MarginRates returns the margin rate of each instrument, for account.Margin
+func (r *Registry) MarginRates() map[string]float64 {
+	rates := make(map[string]float64)
+	for _, instrument := range r.All() {
+		rates[instrument.Name] = instrument.MarginRate
+	}
+	return rates
+}
+
+// QuoteCurrencies returns the quote currency of each instrument, for account.Conversion
+func (r *Registry) QuoteCurrencies() map[string]string {
+	currencies := make(map[string]string)
+	for _, instrument := range r.All() {
+		currencies[instrument.Name] = instrument.QuoteCurrency
+	}
+	return currencies
+}
+
+// FinancingRates returns the financing rates and triple days of each instrument, for account.Financing.
+// The bundled file only carries triple days as rates change daily, so load them from a broker first.
+func (r *Registry) FinancingRates() map[string]account.FinancingRate {
+	rates := make(map[string]account.FinancingRate)
+	for _, instrument := range r.All() {
+		rates[instrument.Name] = account.FinancingRate{
+			Long:      instrument.FinancingLong,
+			Short:     instrument.FinancingShort,
+			TripleDay: instrument.FinancingTripleDay,
+		}
+	}
+	return rates
+}
+
+// Load fetches metadata from the source and merges it over what's held. Fields the source
+// leaves unset keep their current values, and trading hours are never replaced.
+func (r *Registry) Load(ctx context.Context, source Source) error {
+	fetched, err := source.Instruments(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load instruments: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, instrument := range fetched {
+		r.instruments[instrument.Name] = merge(r.instruments[instrument.Name], instrument)
+	}
+	return nil
+}
+
+// merge overlays the set fields of next onto current. Zero is a valid pip location and precision,
+// so they're taken from next together when it sets any of them, as a source that knows one knows them all.
+func merge(current, next types.Instrument) types.Instrument {
+	current.Name = next.Name
+	if next.PipLocation != 0 || next.DisplayPrecision != 0 || next.TradeUnitsPrecision != 0 {
+		current.PipLocation = next.PipLocation
+		current.DisplayPrecision = next.DisplayPrecision
+		current.TradeUnitsPrecision = next.TradeUnitsPrecision
+	}
+	if next.DisplayName != "" {
+		current.DisplayName = next.DisplayName
+	}
+	if next.Type != "" {
+		current.Type = next.Type
+	}
+	if next.QuoteCurrency != "" {
+		current.QuoteCurrency = next.QuoteCurrency
+	}
+	if next.MarginRate != 0 {
+		current.MarginRate = next.MarginRate
+	}
+	if next.MinimumTradeSize != 0 {
+		current.MinimumTradeSize = next.MinimumTradeSize
+	}
+	if next.MaximumOrderUnits != 0 {
+		current.MaximumOrderUnits = next.MaximumOrderUnits
+	}
+	if next.MinimumStopDistance != 0 {
+		current.MinimumStopDistance = next.MinimumStopDistance
+	}
+	if next.MinimumTrailingStopDistance != 0 {
+		current.MinimumTrailingStopDistance = next.MinimumTrailingStopDistance
+	}
+	if next.FinancingLong != 0 || next.FinancingShort != 0 {
+		current.FinancingLong = next.FinancingLong
+		current.FinancingShort = next.FinancingShort
+	}
+	if next.FinancingTripleDay != time.Sunday {
+		current.FinancingTripleDay = next.FinancingTripleDay
+	}
+	return current
+}
+
+// parseWeekday parses a day name in any case, eg Wednesday or WEDNESDAY, empty is Sunday (unset)
+func parseWeekday(name string) (time.Weekday, error) {
+	if name == "" {
+		return time.Sunday, nil
+	}
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if strings.EqualFold(day.String(), name) {
+			return day, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown weekday %s", name)
+}
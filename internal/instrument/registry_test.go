@@ -0,0 +1,121 @@
+package instrument
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jwtly10/tradebook/internal/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type stubSource struct {
+	instruments []types.Instrument
+}
+
+func (s stubSource) Instruments(ctx context.Context) ([]types.Instrument, error) {
+	return s.instruments, nil
+}
+
+func TestBundled(t *testing.T) {
+	r, err := Bundled()
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		pipSize   float64
+		quote     string
+		tripleDay time.Weekday
+	}{
+		{"NAS100_USD", 1, "USD", time.Friday},
+		{"GBP_USD", 0.0001, "USD", time.Wednesday},
+		{"USD_JPY", 0.01, "JPY", time.Wednesday},
+		{"EUR_GBP", 0.0001, "GBP", time.Wednesday},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instrument, err := r.Get(tt.name)
+			assert.NoError(t, err)
+			assert.InDelta(t, tt.pipSize, instrument.PipSize(), 1e-12)
+			assert.Equal(t, tt.quote, instrument.QuoteCurrency)
+			assert.NotZero(t, instrument.MarginRate)
+			assert.Equal(t, tt.tripleDay, instrument.FinancingTripleDay)
+
+			hours, ok := r.Hours(tt.name)
+			assert.True(t, ok)
+			assert.Equal(t, "America/New_York", hours.Timezone)
+		})
+	}
+
+	_, err = r.Get("UNKNOWN")
+	assert.ErrorContains(t, err, "unknown instrument: UNKNOWN")
+
+	all := r.All()
+	for i := 1; i < len(all); i++ {
+		assert.Less(t, all[i-1].Name, all[i].Name)
+	}
+	assert.Equal(t, len(all), len(r.MarginRates()))
+	assert.Equal(t, "JPY", r.QuoteCurrencies()["USD_JPY"])
+}
+
+func TestParse_RejectsInvalidFiles(t *testing.T) {
+	_, err := Parse([]byte("not json"))
+	assert.ErrorContains(t, err, "failed to parse instruments")
+
+	_, err = Parse([]byte(`[{"pipLocation": -4}]`))
+	assert.ErrorContains(t, err, "instrument without a name")
+}
+
+func TestRegistry_LoadMergesOverBundled(t *testing.T) {
+	r, err := Bundled()
+	assert.NoError(t, err)
+
+	err = r.Load(context.Background(), stubSource{instruments: []types.Instrument{
+		// The broker's margin rate and financing win, fields it leaves unset keep the bundled values
+		{Name: "GBP_USD", PipLocation: -4, DisplayPrecision: 5, MarginRate: 0.05, FinancingLong: -0.04, FinancingShort: 0.01},
+		// Instruments we didn't bundle are added
+		{Name: "EUR_CHF", PipLocation: -4, DisplayPrecision: 5, QuoteCurrency: "CHF"},
+		// A source without precisions leaves the bundled ones alone
+		{Name: "USD_JPY", MarginRate: 0.04},
+	}})
+	assert.NoError(t, err)
+
+	jpy, err := r.Get("USD_JPY")
+	assert.NoError(t, err)
+	assert.Equal(t, 0.04, jpy.MarginRate)
+	assert.Equal(t, -2, jpy.PipLocation)
+	assert.Equal(t, 3, jpy.DisplayPrecision)
+
+	gbp, err := r.Get("GBP_USD")
+	assert.NoError(t, err)
+	assert.Equal(t, 0.05, gbp.MarginRate)
+	assert.Equal(t, "USD", gbp.QuoteCurrency)
+	assert.Equal(t, 0.0005, gbp.MinimumTrailingStopDistance)
+	assert.Zero(t, gbp.MinimumStopDistance, "Oanda doesn't limit ordinary stop losses")
+	assert.Equal(t, -0.04, r.FinancingRates()["GBP_USD"].Long)
+	assert.Equal(t, time.Wednesday, r.FinancingRates()["GBP_USD"].TripleDay, "Triple days are kept")
+	_, ok := r.Hours("GBP_USD")
+	assert.True(t, ok, "Hours are kept")
+
+	chf, err := r.Get("EUR_CHF")
+	assert.NoError(t, err)
+	assert.Equal(t, "CHF", chf.QuoteCurrency)
+	_, ok = r.Hours("EUR_CHF")
+	assert.False(t, ok)
+}
+
+func TestSetDefault(t *testing.T) {
+	original := Default()
+	defer SetDefault(original)
+
+	r := NewRegistry()
+	r.Set(types.Instrument{Name: "TEST", PipLocation: -2})
+	SetDefault(r)
+
+	instrument, err := Lookup("TEST")
+	assert.NoError(t, err)
+	assert.Equal(t, 0.01, instrument.PipSize())
+
+	_, err = Lookup("NAS100_USD")
+	assert.Error(t, err)
+}
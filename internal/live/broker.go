@@ -0,0 +1,21 @@
+// Package live runs strategies against bars as they close, routing their signals to a
+// paper account or a broker, so the same strategy code runs in backtest, paper and live.
+package live
+
+import (
+	"context"
+
+	"github.com/jwtly10/tradebook/internal/account"
+	"github.com/jwtly10/tradebook/internal/types"
+)
+
+// Broker executes signals and keeps an account.Account up to date for strategies to size positions from
+type Broker interface {
+	// Account is handed to the strategy on every bar
+	Account() *account.Account
+	// Sync brings the account up to date with a newly closed bar of the instrument, before the strategy
+	// sees it, returning any trades closed since the last sync
+	Sync(ctx context.Context, instrument string, bar types.Bar) ([]account.Trade, error)
+	// Execute routes a signal the strategy generated on bar
+	Execute(ctx context.Context, signal types.Signal, bar types.Bar) error
+}
@@ -0,0 +1,376 @@
+package live
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/jwtly10/tradebook/internal/account"
+	"github.com/jwtly10/tradebook/internal/backtest"
+	"github.com/jwtly10/tradebook/internal/oanda"
+	"github.com/jwtly10/tradebook/internal/types"
+)
+
+// OandaFeed polls Oanda for the latest candles
+type OandaFeed struct {
+	Service *oanda.OandaService
+	Price   types.PriceComponent // Defaults to mid prices
+}
+
+func (f OandaFeed) Latest(ctx context.Context, name string, granularity types.Granularity, count int) ([]types.Bar, error) {
+	return f.Service.LatestCandles(ctx, oanda.InstrumentName(name), granularity, f.Price, count)
+}
+
+// OandaBroker sends signals to an Oanda account. Its account.Account mirrors the trades opened
+// through it, updated from the account's transactions on every Sync, with the balance taken from Oanda.
+// Instruments quoted in another currency are converted into the account currency at the latest rate, so strategies size correctly.
+type OandaBroker struct {
+	service     *oanda.OandaService
+	acc         *account.Account
+	rates       liveRates
+	instruments map[string]types.Instrument
+
+	// Mirror position ID by Oanda trade, and the reverse
+	positions map[oanda.TradeID]int
+	trades    map[int]oanda.TradeID
+	// Signals of orders sent but not yet filled, so the mirror knows their stops
+	pending           map[oanda.OrderID]types.Signal
+	lastTransactionID oanda.TransactionID
+}
+
+// NewOandaBroker starts mirroring the account from its current balance. Trades already open aren't mirrored.
+// Orders are formatted, and conversion rates found, with the metadata of the given instruments, eg instrument.Registry.Map().
+func NewOandaBroker(ctx context.Context, service *oanda.OandaService, instruments map[string]types.Instrument) (*OandaBroker, error) {
+	summary, err := service.GetAccountSummary(ctx)
+	if err != nil {
+		return nil, err
+	}
+	balance, err := summary.Account.Balance.Float64()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse account balance %s: %w", summary.Account.Balance, err)
+	}
+	if summary.Account.OpenTradeCount > 0 {
+		slog.Warn("Account has open trades that won't be mirrored", "account_id", summary.Account.ID, "open_trades", summary.Account.OpenTradeCount)
+	}
+
+	quoteCurrencies := make(map[string]string, len(instruments))
+	for name, instrument := range instruments {
+		quoteCurrencies[name] = instrument.QuoteCurrency
+	}
+	rates := make(liveRates)
+	acc := account.NewAccount(balance)
+	acc.Conversion = &account.Conversion{
+		Home:            summary.Account.Currency,
+		QuoteCurrencies: quoteCurrencies,
+		Rates:           rates,
+	}
+
+	return &OandaBroker{
+		service:           service,
+		acc:               acc,
+		rates:             rates,
+		instruments:       instruments,
+		positions:         make(map[oanda.TradeID]int),
+		trades:            make(map[int]oanda.TradeID),
+		pending:           make(map[oanda.OrderID]types.Signal),
+		lastTransactionID: summary.LastTransactionID,
+	}, nil
+}
+
+func (b *OandaBroker) Account() *account.Account {
+	return b.acc
+}
+
+// Sync refreshes conversion rates, applies the account's transactions since the last sync to the mirror, then refreshes the balance.
+// Fills open and close mirror positions, closes are returned as trades.
+func (b *OandaBroker) Sync(ctx context.Context, instrument string, bar types.Bar) ([]account.Trade, error) {
+	names := map[string]bool{instrument: true}
+	for _, pos := range b.acc.OpenPositions() {
+		names[pos.Instrument] = true
+	}
+	for name := range names {
+		if err := b.refreshRate(ctx, name); err != nil {
+			return nil, err
+		}
+	}
+
+	changes, err := b.service.GetAccountChanges(ctx, b.lastTransactionID)
+	if err != nil {
+		return nil, err
+	}
+
+	var trades []account.Trade
+	for _, tx := range changes.Changes.Transactions {
+		switch tx.Type {
+		case "ORDER_FILL":
+			for _, closed := range tx.TradesClosed {
+				if trade, ok := b.closeMirror(tx, closed, bar); ok {
+					trades = append(trades, trade)
+				}
+			}
+			if tx.TradeReduced != nil {
+				if trade, ok := b.closeMirror(tx, *tx.TradeReduced, bar); ok {
+					trades = append(trades, trade)
+				}
+			}
+			if tx.TradeOpened != nil {
+				if err := b.openMirror(tx, *tx.TradeOpened, bar); err != nil {
+					slog.Error("Failed to mirror opened trade", "trade_id", tx.TradeOpened.TradeID, "error", err)
+				}
+			}
+		case "ORDER_CANCEL":
+			if _, ok := b.pending[tx.OrderID]; ok {
+				slog.Warn("Oanda order cancelled", "order_id", tx.OrderID, "reason", tx.Reason)
+				delete(b.pending, tx.OrderID)
+			}
+		}
+	}
+	b.lastTransactionID = changes.LastTransactionID
+
+	summary, err := b.service.GetAccountSummary(ctx)
+	if err != nil {
+		return trades, err
+	}
+	balance, err := summary.Account.Balance.Float64()
+	if err != nil {
+		return trades, fmt.Errorf("failed to parse account balance %s: %w", summary.Account.Balance, err)
+	}
+	b.acc.Balance = balance
+
+	return trades, nil
+}
+
+// Execute sends the signal to Oanda. Positions are only mirrored once Sync sees them fill.
+func (b *OandaBroker) Execute(ctx context.Context, signal types.Signal, bar types.Bar) error {
+	meta, ok := b.instruments[signal.Instrument]
+	if !ok {
+		return fmt.Errorf("unknown instrument: %s", signal.Instrument)
+	}
+
+	switch signal.Type {
+	case types.OPEN:
+		order, err := oanda.OrderFromSignal(signal, meta)
+		if err != nil {
+			return err
+		}
+		resp, err := b.service.CreateOrder(ctx, order)
+		if err != nil {
+			return err
+		}
+		b.pending[oanda.OrderID(resp.OrderCreateTransaction.ID)] = signal
+	case types.CLOSE:
+		for _, pos := range b.acc.Targets(signal) {
+			tradeID, ok := b.trades[pos.ID]
+			if !ok {
+				continue
+			}
+			req, err := oanda.TradeCloseFromSignal(signal, pos.Size, meta)
+			if err != nil {
+				return err
+			}
+			if _, err := b.service.CloseTrade(ctx, tradeID, req); err != nil {
+				return err
+			}
+		}
+	case types.MODIFY:
+		for _, pos := range b.acc.Targets(signal) {
+			tradeID, ok := b.trades[pos.ID]
+			if !ok {
+				continue
+			}
+			req, err := oanda.TradeOrdersFromSignal(signal, meta)
+			if err != nil {
+				return err
+			}
+			if _, err := b.service.ModifyTrade(ctx, tradeID, req); err != nil {
+				return err
+			}
+			// Mirror the levels as sent, rounded to the instrument's precision
+			stopLoss, takeProfit, err := sentLevels(req)
+			if err != nil {
+				return err
+			}
+			b.acc.ModifyPosition(pos.ID, stopLoss, takeProfit, bar.Timestamp)
+		}
+	}
+	return nil
+}
+
+// sentLevels returns the stop loss and take profit a trade orders request sets, zero for those it leaves unchanged
+func sentLevels(req oanda.TradeOrdersRequest) (stopLoss, takeProfit float64, err error) {
+	if req.StopLoss != nil {
+		if stopLoss, err = strconv.ParseFloat(string(req.StopLoss.Price), 64); err != nil {
+			return 0, 0, fmt.Errorf("failed to parse stop loss %s: %w", req.StopLoss.Price, err)
+		}
+	}
+	if req.TakeProfit != nil {
+		if takeProfit, err = strconv.ParseFloat(string(req.TakeProfit.Price), 64); err != nil {
+			return 0, 0, fmt.Errorf("failed to parse take profit %s: %w", req.TakeProfit.Price, err)
+		}
+	}
+	return stopLoss, takeProfit, nil
+}
+
+// openMirror opens a mirror position for a trade opened by a fill, with the stops of the signal that created the order
+func (b *OandaBroker) openMirror(tx oanda.Transaction, opened oanda.TradeOpen, bar types.Bar) error {
+	units, err := opened.Units.Float64()
+	if err != nil {
+		return err
+	}
+	price, err := strconv.ParseFloat(string(opened.Price), 64)
+	if err != nil {
+		return err
+	}
+
+	signal, ok := b.pending[tx.OrderID]
+	if !ok {
+		// Opened outside the runner, mirror it so the strategy can see it
+		signal = types.Signal{Instrument: string(tx.Instrument), Type: types.OPEN, Action: types.BUY}
+		if units < 0 {
+			signal.Action = types.SELL
+		}
+	}
+	delete(b.pending, tx.OrderID)
+
+	if ok && !signal.IsPending() {
+		// Market order stops are sent as distances, so Oanda places them relative to the fill
+		shift := price - signal.Price
+		if signal.SL != 0 {
+			signal.SL += shift
+		}
+		if signal.TP != 0 {
+			signal.TP += shift
+		}
+	}
+	signal.Price = price
+	signal.Size = math.Abs(units)
+	pos, err := b.acc.OpenTrade(signal, fillBar(tx, bar))
+	if err != nil {
+		return err
+	}
+	b.positions[opened.TradeID] = pos.ID
+	b.trades[pos.ID] = opened.TradeID
+	return nil
+}
+
+// closeMirror closes the part of a mirror position a fill reduced, taking PnL from Oanda
+func (b *OandaBroker) closeMirror(tx oanda.Transaction, reduced oanda.TradeReduce, bar types.Bar) (account.Trade, bool) {
+	id, ok := b.positions[reduced.TradeID]
+	if !ok {
+		return account.Trade{}, false
+	}
+	var size float64
+	for _, pos := range b.acc.OpenPositions() {
+		if pos.ID == id {
+			size = pos.Size
+		}
+	}
+	units, err := reduced.Units.Float64()
+	if err != nil || size == 0 {
+		return account.Trade{}, false
+	}
+	price, err := strconv.ParseFloat(string(reduced.Price), 64)
+	if err != nil {
+		price, _ = strconv.ParseFloat(string(tx.Price), 64)
+	}
+
+	fraction := math.Abs(units) / size
+	trade, ok := b.acc.ClosePosition(id, fraction, price, fillBar(tx, bar), exitReason(tx.Reason))
+	if !ok {
+		return account.Trade{}, false
+	}
+	if fraction >= 1 {
+		delete(b.positions, reduced.TradeID)
+		delete(b.trades, id)
+	}
+
+	// Oanda's PnL is already in the account currency, with the spread paid in its fill prices rather than as a cost
+	realized, _ := reduced.RealizedPL.Float64()
+	financing, _ := reduced.Financing.Float64()
+	if trade.GrossPnL != 0 && trade.ConversionRate != 0 && realized != 0 {
+		trade.ConversionRate = realized / (trade.GrossPnL / trade.ConversionRate)
+	}
+	trade.GrossPnL = realized
+	trade.Costs = account.Costs{}
+	trade.Financing = financing
+	trade.PnL = realized + financing
+	return trade, true
+}
+
+// refreshRate updates the rate converting the instrument's quote currency into the account currency,
+// from the latest price of whichever pair between them Oanda lists
+func (b *OandaBroker) refreshRate(ctx context.Context, name string) error {
+	home := b.acc.Conversion.Home
+	quote, ok := b.acc.Conversion.QuoteCurrencies[name]
+	if !ok || quote == home {
+		return nil
+	}
+
+	pairs := []struct {
+		name    string
+		inverse bool
+	}{
+		{quote + "_" + home, false},
+		{home + "_" + quote, true},
+	}
+	for _, pair := range pairs {
+		if _, ok := b.instruments[pair.name]; !ok {
+			continue
+		}
+		bars, err := b.service.LatestCandles(ctx, oanda.InstrumentName(pair.name), types.M1, types.MID, 1)
+		if err != nil {
+			return fmt.Errorf("failed to fetch %s conversion rate: %w", pair.name, err)
+		}
+		if len(bars) == 0 || bars[len(bars)-1].Close == 0 {
+			return fmt.Errorf("no %s price to convert %s with", pair.name, name)
+		}
+		rate := bars[len(bars)-1].Close
+		if pair.inverse {
+			rate = 1 / rate
+		}
+		b.rates[quote+"_"+home] = rate
+		return nil
+	}
+	return fmt.Errorf("no instrument converts %s into %s, so %s can't be sized in the account currency", quote, home, name)
+}
+
+// liveRates are the latest conversion rates keyed by FROM_TO, refreshed by the broker on every Sync.
+// Live amounts are converted at the current rate whatever time they're asked for.
+type liveRates map[string]float64
+
+func (r liveRates) Rate(from, to string, at time.Time) (float64, error) {
+	if from == to {
+		return 1, nil
+	}
+	if rate, ok := r[from+"_"+to]; ok {
+		return rate, nil
+	}
+	return 0, fmt.Errorf("no %s/%s rate, rates are refreshed on every sync", from, to)
+}
+
+// fillBar returns the bar stamped with the transaction's time, when it can be parsed
+func fillBar(tx oanda.Transaction, bar types.Bar) types.Bar {
+	if t, err := time.Parse(time.RFC3339, tx.Time); err == nil {
+		bar.Timestamp = t
+	}
+	return bar
+}
+
+// exitReason maps Oanda's fill reasons onto the exit reasons used in backtests
+func exitReason(reason string) string {
+	switch reason {
+	case "STOP_LOSS_ORDER", "TRAILING_STOP_LOSS_ORDER":
+		return "STOP_LOSS"
+	case "TAKE_PROFIT_ORDER":
+		return "TAKE_PROFIT"
+	case "MARKET_ORDER_TRADE_CLOSE":
+		return backtest.STRATEGY_EXIT
+	case "MARKET_ORDER_MARGIN_CLOSEOUT":
+		return account.MARGIN_CLOSEOUT
+	default:
+		return reason
+	}
+}
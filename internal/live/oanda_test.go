@@ -0,0 +1,123 @@
+package live
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jwtly10/tradebook/internal/account"
+	"github.com/jwtly10/tradebook/internal/oanda"
+	"github.com/jwtly10/tradebook/internal/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockV20 stands in for the v20 API of account 101-001, serving account changes by the transaction they're since
+type mockV20 struct {
+	t        *testing.T
+	balance  string
+	changes  map[string]string
+	requests []string
+}
+
+func (m *mockV20) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.requests = append(m.requests, r.Method+" "+r.URL.Path)
+
+	switch r.Method + " " + r.URL.Path {
+	case "GET /v3/accounts/101-001/summary":
+		w.Write([]byte(`{"account": {"id": "101-001", "currency": "GBP", "balance": "` + m.balance + `"}, "lastTransactionID": "1"}`))
+	case "GET /v3/accounts/101-001/changes":
+		changes, ok := m.changes[r.URL.Query().Get("sinceTransactionID")]
+		assert.True(m.t, ok, "unexpected changes request since %s", r.URL.Query().Get("sinceTransactionID"))
+		w.Write([]byte(changes))
+	case "POST /v3/accounts/101-001/orders":
+		var body struct {
+			Order oanda.OrderRequest `json:"order"`
+		}
+		assert.NoError(m.t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(m.t, oanda.DecimalNumber("1000"), body.Order.Units)
+		assert.Equal(m.t, oanda.DecimalNumber("0.00500"), body.Order.StopLossOnFill.Distance)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"orderCreateTransaction": {"id": "2", "type": "MARKET_ORDER"}, "lastTransactionID": "3"}`))
+	case "GET /v3/accounts/101-001/instruments/GBP_USD/candles":
+		// Converts the USD quoted trades into the GBP account
+		w.Write([]byte(`{"candles": [{"time": "2025-01-08T10:14:00Z", "mid": {"o": "1.25", "h": "1.25", "l": "1.25", "c": "1.25"}}]}`))
+	case "PUT /v3/accounts/101-001/trades/3/orders":
+		w.Write([]byte(`{"stopLossOrderTransaction": {"id": "4", "type": "STOP_LOSS_ORDER", "tradeID": "3"}, "lastTransactionID": "4"}`))
+	default:
+		m.t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestOandaBroker_MirrorsTradesFromAccountChanges(t *testing.T) {
+	mock := &mockV20{t: t, balance: "10000.0000", changes: map[string]string{
+		"1": `{"changes": {"transactions": [
+			{"id": "2", "type": "MARKET_ORDER"},
+			{"id": "3", "type": "ORDER_FILL", "orderID": "2", "instrument": "GBP_USD", "time": "2025-01-08T10:15:01Z",
+				"tradeOpened": {"tradeID": "3", "units": "1000", "price": "1.25020"}}
+		]}, "lastTransactionID": "3"}`,
+		"3": `{"changes": {"transactions": [
+			{"id": "4", "type": "STOP_LOSS_ORDER"},
+			{"id": "5", "type": "ORDER_FILL", "orderID": "4", "instrument": "GBP_USD", "reason": "STOP_LOSS_ORDER", "time": "2025-01-08T10:40:00Z",
+				"tradesClosed": [{"tradeID": "3", "units": "-1000", "price": "1.25500", "realizedPL": "3.8000", "financing": "-0.0100"}]}
+		]}, "lastTransactionID": "5"}`,
+	}}
+	server := httptest.NewServer(mock)
+	defer server.Close()
+
+	ctx := context.Background()
+	instruments := map[string]types.Instrument{
+		"GBP_USD": {Name: "GBP_USD", PipLocation: -4, DisplayPrecision: 5, QuoteCurrency: "USD"},
+	}
+	broker, err := NewOandaBroker(ctx, oanda.NewOandaService("101-001", "key", server.URL), instruments)
+	assert.NoError(t, err)
+	assert.Equal(t, 10000.0, broker.Account().Balance)
+	assert.Equal(t, "GBP", broker.Account().Currency())
+	_, err = broker.Account().Conversion.Rates.Rate("USD", "GBP", time.Time{})
+	assert.Error(t, err, "Rates are loaded on sync")
+
+	signalBar := bar(0, 1.25, 1.251, 1.249, 1.25, false)
+	err = broker.Execute(ctx, types.Signal{Instrument: "GBP_USD", Type: types.OPEN, Action: types.BUY, Price: 1.25, SL: 1.245, TP: 1.26, Size: 1000}, signalBar)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, broker.Account().PositionCount(), "Mirrored once the fill is seen")
+
+	trades, err := broker.Sync(ctx, "GBP_USD", bar(15, 1.25, 1.251, 1.249, 1.25, false))
+	assert.NoError(t, err)
+	assert.Empty(t, trades)
+
+	rate, err := broker.Account().QuoteRate("GBP_USD", time.Time{})
+	assert.NoError(t, err)
+	assert.InDelta(t, 1.25, rate, 1e-9)
+
+	positions := broker.Account().OpenPositions()
+	assert.Equal(t, 1, len(positions))
+	assert.Equal(t, 1.2502, positions[0].EntryPrice)
+	assert.Equal(t, 1000.0, positions[0].Size)
+	assert.InDelta(t, 1.2452, positions[0].StopLoss, 1e-9, "Stops are the signal's distance from the fill")
+
+	err = broker.Execute(ctx, types.Signal{Instrument: "GBP_USD", Type: types.MODIFY, SL: 1.2550004}, bar(15, 0, 0, 0, 0, false))
+	assert.NoError(t, err)
+	assert.Equal(t, 1.255, broker.Account().OpenPositions()[0].StopLoss, "The mirror has the level as it was sent")
+
+	err = broker.Execute(ctx, types.Signal{Instrument: "EUR_USD", Type: types.OPEN, Action: types.BUY, Price: 1.1, Size: 1000}, signalBar)
+	assert.ErrorContains(t, err, "unknown instrument: EUR_USD")
+
+	mock.balance = "10003.7900"
+	trades, err = broker.Sync(ctx, "GBP_USD", bar(30, 1.255, 1.256, 1.254, 1.255, false))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(trades))
+	assert.Equal(t, "STOP_LOSS", trades[0].ExitReason)
+	assert.Equal(t, 1.255, trades[0].ExitPrice)
+	assert.InDelta(t, 3.79, trades[0].PnL, 1e-9)
+	// Oanda's figures replace the mirror's, so the trade adds up
+	assert.InDelta(t, 3.8, trades[0].GrossPnL, 1e-9)
+	assert.Equal(t, account.Costs{}, trades[0].Costs)
+	assert.InDelta(t, trades[0].PnL, trades[0].GrossPnL-trades[0].Costs.Total()+trades[0].Financing, 1e-9)
+	// 1000 units from 1.2502 to 1.255 is $4.80, realized as £3.80
+	assert.InDelta(t, 3.8/4.8, trades[0].ConversionRate, 1e-9)
+	assert.Equal(t, 0, broker.Account().PositionCount())
+	assert.Equal(t, 10003.79, broker.Account().Balance)
+}
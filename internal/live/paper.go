@@ -0,0 +1,76 @@
+package live
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/jwtly10/tradebook/internal/account"
+	"github.com/jwtly10/tradebook/internal/backtest"
+	"github.com/jwtly10/tradebook/internal/types"
+)
+
+// PaperBroker trades a simulated account.Account with the same fills as a backtest,
+// market and CLOSE signals fill on the next bar and pending orders rest on the account
+type PaperBroker struct {
+	// FillModel prices market signals on the bar after they were generated, defaults to NextOpenFill
+	FillModel backtest.FillModel
+
+	acc    *account.Account
+	queued []types.Signal
+}
+
+func NewPaperBroker(acc *account.Account) *PaperBroker {
+	return &PaperBroker{
+		FillModel: backtest.NextOpenFill{},
+		acc:       acc,
+	}
+}
+
+func (b *PaperBroker) Account() *account.Account {
+	return b.acc
+}
+
+// Sync fills signals queued for the instrument, then checks pending orders, exits and margin against the bar
+func (b *PaperBroker) Sync(ctx context.Context, instrument string, bar types.Bar) ([]account.Trade, error) {
+	var trades []account.Trade
+
+	remaining := b.queued[:0]
+	for _, signal := range b.queued {
+		if signal.Instrument != instrument {
+			remaining = append(remaining, signal)
+			continue
+		}
+		if signal.Type == types.CLOSE {
+			trades = append(trades, backtest.CloseTargets(b.acc, b.FillModel, signal, bar)...)
+			continue
+		}
+		fillPrice := b.FillModel.FillPrice(signal, bar)
+		slog.Debug("Filling paper market signal", "instrument", instrument, "signal_price", signal.Price, "fill_price", fillPrice, "timestamp", bar.Timestamp)
+		if _, err := b.acc.OpenTrade(backtest.Reanchor(signal, fillPrice), bar); err != nil {
+			slog.Warn("Paper order rejected", "instrument", instrument, "error", err)
+		}
+	}
+	b.queued = remaining
+
+	b.acc.CheckOrdersFor(instrument, bar)
+	trades = append(trades, b.acc.CheckExitsFor(instrument, bar)...)
+	trades = append(trades, b.acc.CheckMarginCloseout()...)
+	return trades, nil
+}
+
+// Execute places pending orders and modifies positions straight away, market and CLOSE signals wait for the next bar
+func (b *PaperBroker) Execute(ctx context.Context, signal types.Signal, bar types.Bar) error {
+	switch signal.Type {
+	case types.OPEN:
+		if signal.IsPending() {
+			_, err := b.acc.PlaceOrder(signal, bar.Timestamp)
+			return err
+		}
+		b.queued = append(b.queued, signal)
+	case types.CLOSE:
+		b.queued = append(b.queued, signal)
+	case types.MODIFY:
+		backtest.ModifyTargets(b.acc, signal, bar.Timestamp)
+	}
+	return nil
+}
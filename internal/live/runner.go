@@ -0,0 +1,153 @@
+package live
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jwtly10/tradebook/internal/account"
+	"github.com/jwtly10/tradebook/internal/data"
+	"github.com/jwtly10/tradebook/internal/strategy"
+	"github.com/jwtly10/tradebook/internal/types"
+)
+
+// MaxPollCount is the most bars requested from a Feed at once, Oanda's candle limit
+const MaxPollCount = 5000
+
+// Feed provides the most recent bars of an instrument
+type Feed interface {
+	// Latest returns up to count of the most recent bars, oldest first. The last may still be forming and is marked Partial.
+	Latest(ctx context.Context, instrument string, granularity types.Granularity, count int) ([]types.Bar, error)
+}
+
+// Runner drives a strategy with bars as they close. OnBar is called exactly once per closed bar,
+// first for the warm up history, whose signals are dropped, then for each bar closing while it runs.
+type Runner struct {
+	Strategy    strategy.Strategy
+	Broker      Broker
+	Feed        Feed
+	Instrument  string
+	Granularity types.Granularity
+	// Warmup is how many closed bars are fed through the strategy before trading, so its indicators are ready
+	Warmup int
+	// PollInterval is how often the feed is checked for newly closed bars
+	PollInterval time.Duration
+	// History is the most closed bars kept and handed to the strategy, older bars are dropped so a long
+	// running process doesn't grow without limit. Keep it at least Warmup, bar indexes shift once it's reached.
+	History int
+
+	bars   []types.Bar
+	trades []account.Trade
+	now    func() time.Time
+}
+
+func NewRunner(s strategy.Strategy, broker Broker, feed Feed, instrument string, granularity types.Granularity) *Runner {
+	return &Runner{
+		Strategy:     s,
+		Broker:       broker,
+		Feed:         feed,
+		Instrument:   instrument,
+		Granularity:  granularity,
+		Warmup:       200,
+		PollInterval: 10 * time.Second,
+		History:      1000,
+		now:          time.Now,
+	}
+}
+
+// Trades returns every trade closed since the runner started
+func (r *Runner) Trades() []account.Trade {
+	return r.trades
+}
+
+// Run warms the strategy up then polls for closed bars until ctx is cancelled, returning ctx's error
+func (r *Runner) Run(ctx context.Context) error {
+	period, err := r.Granularity.ToDuration()
+	if err != nil {
+		return err
+	}
+
+	history, err := r.Feed.Latest(ctx, r.Instrument, r.Granularity, min(r.Warmup+1, MaxPollCount))
+	if err != nil {
+		return fmt.Errorf("failed to fetch warm up bars: %w", err)
+	}
+	for _, bar := range data.Complete(history) {
+		r.append(bar)
+		r.Strategy.OnBar(r.bars, len(r.bars)-1, r.Broker.Account())
+	}
+	slog.Info("Live runner warmed up", "instrument", r.Instrument, "granularity", r.Granularity, "bars", len(r.bars))
+
+	ticker := time.NewTicker(r.PollInterval)
+	defer ticker.Stop()
+	for {
+		if err := r.poll(ctx, period); err != nil {
+			slog.Error("Failed to poll for closed bars", "instrument", r.Instrument, "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll fetches enough bars to cover the time since the last closed bar and processes any that are new
+func (r *Runner) poll(ctx context.Context, period time.Duration) error {
+	count := 2
+	if len(r.bars) > 0 {
+		count = int(r.now().Sub(r.bars[len(r.bars)-1].Timestamp)/period) + 2
+	}
+
+	bars, err := r.Feed.Latest(ctx, r.Instrument, r.Granularity, min(count, MaxPollCount))
+	if err != nil {
+		return err
+	}
+
+	for _, bar := range data.Complete(bars) {
+		if len(r.bars) > 0 && !bar.Timestamp.After(r.bars[len(r.bars)-1].Timestamp) {
+			continue
+		}
+		if err := r.onBar(ctx, bar); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// onBar syncs the broker with a newly closed bar, then runs the strategy and routes its signals.
+// A failed sync leaves the bar unprocessed so it's retried on the next poll.
+func (r *Runner) onBar(ctx context.Context, bar types.Bar) error {
+	slog.Info("Bar closed", "instrument", r.Instrument, "timestamp", bar.Timestamp, "close", bar.Close)
+
+	trades, err := r.Broker.Sync(ctx, r.Instrument, bar)
+	if err != nil {
+		return fmt.Errorf("failed to sync broker at %s: %w", bar.Timestamp, err)
+	}
+	r.trades = append(r.trades, trades...)
+	r.append(bar)
+
+	for _, signal := range r.Strategy.OnBar(r.bars, len(r.bars)-1, r.Broker.Account()) {
+		if signal.Instrument == "" {
+			signal.Instrument = r.Instrument
+			if pos, ok := r.Broker.Account().Position(signal.PositionID); ok && signal.Type != types.OPEN {
+				signal.Instrument = pos.Instrument
+			}
+		}
+		if err := r.Broker.Execute(ctx, signal, bar); err != nil {
+			// One failed signal shouldn't stop the rest, the strategy sees the account as it is next bar
+			slog.Error("Failed to execute signal", "instrument", signal.Instrument, "type", signal.Type, "action", signal.Action, "error", err)
+		}
+	}
+	return nil
+}
+
+// append adds a closed bar, dropping the oldest once there are more than History.
+// Reslicing lets append reallocate at the current length, so the backing array stays bounded too.
+func (r *Runner) append(bar types.Bar) {
+	r.bars = append(r.bars, bar)
+	if r.History > 0 && len(r.bars) > r.History {
+		r.bars = r.bars[len(r.bars)-r.History:]
+	}
+}
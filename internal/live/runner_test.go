@@ -0,0 +1,128 @@
+package live
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jwtly10/tradebook/internal/account"
+	"github.com/jwtly10/tradebook/internal/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// stubFeed returns one response per call, repeating the last, and cancels once every response has been seen twice
+type stubFeed struct {
+	responses [][]types.Bar
+	counts    []int
+	cancel    context.CancelFunc
+}
+
+func (f *stubFeed) Latest(ctx context.Context, instrument string, granularity types.Granularity, count int) ([]types.Bar, error) {
+	f.counts = append(f.counts, count)
+	i := min(len(f.counts)-1, len(f.responses)-1)
+	if len(f.counts) > len(f.responses) {
+		f.cancel()
+	}
+	return f.responses[i], nil
+}
+
+// recordingStrategy records the bars it's called on and returns the signals set for them
+type recordingStrategy struct {
+	seen    []time.Time
+	lengths []int
+	signals map[int][]types.Signal
+}
+
+func (s *recordingStrategy) OnBar(bars []types.Bar, currentIndex int, acc *account.Account) []types.Signal {
+	s.seen = append(s.seen, bars[currentIndex].Timestamp)
+	s.lengths = append(s.lengths, len(bars))
+	return s.signals[currentIndex]
+}
+
+func (s *recordingStrategy) GetRiskPercentage() float64 { return 0 }
+func (s *recordingStrategy) GetRiskRatio() float64      { return 0 }
+func (s *recordingStrategy) GetBalanceToRisk() float64  { return 0 }
+func (s *recordingStrategy) GetStopLossPips() int       { return 0 }
+func (s *recordingStrategy) GetSymbol() string          { return "NAS100_USD" }
+func (s *recordingStrategy) GetPeriod() string          { return "M15" }
+
+func bar(minutes int, open, high, low, close float64, partial bool) types.Bar {
+	return types.Bar{
+		Timestamp: time.Date(2025, 1, 8, 10, minutes, 0, 0, time.UTC),
+		Open:      open, High: high, Low: low, Close: close,
+		Partial: partial,
+	}
+}
+
+func TestRunner_CallsOnBarOncePerClosedBar(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	feed := &stubFeed{
+		cancel: cancel,
+		responses: [][]types.Bar{
+			// Warm up, the forming bar is skipped
+			{bar(0, 100, 101, 99, 100, false), bar(15, 100, 101, 99, 100, false), bar(30, 100, 100, 100, 100, true)},
+			{bar(15, 100, 101, 99, 100, false), bar(30, 100, 102, 99, 101, false), bar(45, 101, 101, 101, 101, true)},
+			{bar(30, 100, 102, 99, 101, false), bar(45, 101, 107, 100, 106, false), bar(60, 106, 106, 106, 106, true)},
+		},
+	}
+	strat := &recordingStrategy{signals: map[int][]types.Signal{
+		// Dropped, it's generated during warm up
+		0: {{Type: types.OPEN, Action: types.SELL, Price: 100, Size: 1}},
+		// Fills at the 10:45 open and takes profit on the same bar
+		2: {{Type: types.OPEN, Action: types.BUY, Price: 101, TP: 106, SL: 90, Size: 1}},
+	}}
+
+	broker := NewPaperBroker(account.NewAccount(10000))
+	runner := NewRunner(strat, broker, feed, "NAS100_USD", types.M15)
+	runner.Warmup = 2
+	runner.PollInterval = time.Millisecond
+	runner.now = func() time.Time { return time.Date(2025, 1, 8, 10, 50, 0, 0, time.UTC) }
+
+	err := runner.Run(ctx)
+	assert.True(t, errors.Is(err, context.Canceled))
+
+	assert.Equal(t, []time.Time{
+		bar(0, 0, 0, 0, 0, false).Timestamp,
+		bar(15, 0, 0, 0, 0, false).Timestamp,
+		bar(30, 0, 0, 0, 0, false).Timestamp,
+		bar(45, 0, 0, 0, 0, false).Timestamp,
+	}, strat.seen)
+	assert.Equal(t, 3, feed.counts[0], "Warm up asks for one extra bar as the last is usually forming")
+
+	trades := runner.Trades()
+	assert.Equal(t, 1, len(trades))
+	assert.Equal(t, account.LONG, trades[0].Direction)
+	assert.Equal(t, 101.0, trades[0].EntryPrice)
+	assert.Equal(t, "TAKE_PROFIT", trades[0].ExitReason)
+	assert.Equal(t, 10005.0, broker.Account().Balance)
+	assert.Equal(t, 0, broker.Account().PositionCount())
+}
+
+func TestRunner_KeepsOnlyHistoryBars(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	feed := &stubFeed{
+		cancel: cancel,
+		responses: [][]types.Bar{
+			{bar(0, 100, 101, 99, 100, false), bar(15, 100, 101, 99, 100, false), bar(30, 100, 101, 99, 100, false)},
+			{bar(30, 100, 101, 99, 100, false), bar(45, 100, 101, 99, 100, false)},
+		},
+	}
+	strat := &recordingStrategy{}
+
+	runner := NewRunner(strat, NewPaperBroker(account.NewAccount(10000)), feed, "NAS100_USD", types.M15)
+	runner.Warmup = 2
+	runner.History = 2
+	runner.PollInterval = time.Millisecond
+	runner.now = func() time.Time { return time.Date(2025, 1, 8, 11, 0, 0, 0, time.UTC) }
+
+	err := runner.Run(ctx)
+	assert.True(t, errors.Is(err, context.Canceled))
+
+	assert.Equal(t, []int{1, 2, 2, 2}, strat.lengths)
+	assert.Equal(t, bar(45, 0, 0, 0, 0, false).Timestamp, strat.seen[len(strat.seen)-1])
+}
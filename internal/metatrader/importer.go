@@ -0,0 +1,94 @@
+package metatrader
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jwtly10/tradebook/internal/data"
+	"github.com/jwtly10/tradebook/internal/types"
+)
+
+const (
+	mt4TimeLayout    = "2006.01.02 15:04"
+	mt5TimeLayout    = "2006.01.02 15:04:05"
+	mt5DayTimeLayout = "2006.01.02"
+)
+
+// Options configures how MetaTrader exports are mapped onto bars
+type Options struct {
+	// Location is the broker's server time zone, MetaTrader exports timestamps in server time. Defaults to UTC.
+	Location *time.Location
+	// Point is the instrument's point size, used to convert MT5 spreads from points to price.
+	// If zero the spread is left unset.
+	Point float64
+}
+
+// ReadMT4 reads a MetaTrader 4 History Center export, which has no header and columns of
+// date, time, open, high, low, close, volume. eg:
+//
+//	2024.01.02,00:00,1.27150,1.27200,1.27100,1.27180,123
+func ReadMT4(r io.Reader, opts Options) ([]types.Bar, error) {
+	bars, err := data.ReadCSV(r, data.CSVFormat{
+		Delimiter:  ',',
+		Columns:    data.CSVColumns{Date: 1, Time: 2, Open: 3, High: 4, Low: 5, Close: 6, Volume: 7},
+		TimeLayout: mt4TimeLayout,
+		Location:   opts.Location,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read MT4 export: %w", err)
+	}
+	return bars, nil
+}
+
+// ReadMT5 reads a MetaTrader 5 bars export, which is tab separated with a header of
+// <DATE> <TIME> <OPEN> <HIGH> <LOW> <CLOSE> <TICKVOL> <VOL> <SPREAD>.
+// Daily and higher exports have no <TIME> column. Tick volume is used as the bar volume.
+func ReadMT5(r io.Reader, opts Options) ([]types.Bar, error) {
+	buffered := bufio.NewReader(r)
+	header, err := buffered.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to read MT5 export header: %w", err)
+	}
+
+	layout := mt5DayTimeLayout
+	if strings.Contains(strings.ToUpper(header), "<TIME>") {
+		layout = mt5TimeLayout
+	}
+
+	bars, err := data.ReadCSV(io.MultiReader(strings.NewReader(header), buffered), data.CSVFormat{
+		Delimiter:  '\t',
+		HasHeader:  true,
+		TimeLayout: layout,
+		Location:   opts.Location,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read MT5 export: %w", err)
+	}
+
+	for i := range bars {
+		bars[i].Spread *= opts.Point
+	}
+	return bars, nil
+}
+
+func ReadMT4File(path string, opts Options) ([]types.Bar, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadMT4(f, opts)
+}
+
+func ReadMT5File(path string, opts Options) ([]types.Bar, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadMT5(f, opts)
+}
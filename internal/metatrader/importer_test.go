@@ -0,0 +1,45 @@
+package metatrader
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jwtly10/tradebook/internal/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadMT4(t *testing.T) {
+	input := "2024.01.02,00:00,1.27150,1.27200,1.27100,1.27180,123\n" +
+		"2024.01.02,00:15,1.27180,1.27250,1.27170,1.27240,98\n"
+
+	// Server time of UTC+2
+	bars, err := ReadMT4(strings.NewReader(input), Options{Location: time.FixedZone("EET", 2*60*60)})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(bars))
+	assert.Equal(t, types.Bar{
+		Timestamp: time.Date(2024, 1, 1, 22, 0, 0, 0, time.UTC),
+		Open:      1.27150, High: 1.27200, Low: 1.27100, Close: 1.27180, Volume: 123,
+	}, bars[0])
+}
+
+func TestReadMT5(t *testing.T) {
+	input := "<DATE>\t<TIME>\t<OPEN>\t<HIGH>\t<LOW>\t<CLOSE>\t<TICKVOL>\t<VOL>\t<SPREAD>\n" +
+		"2024.01.02\t00:00:00\t1.27150\t1.27200\t1.27100\t1.27180\t123\t0\t12\n"
+
+	bars, err := ReadMT5(strings.NewReader(input), Options{Point: 0.00001})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(bars))
+	assert.Equal(t, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), bars[0].Timestamp)
+	assert.Equal(t, float64(123), bars[0].Volume, "Tick volume should be used over real volume")
+	assert.InDelta(t, 0.00012, bars[0].Spread, 1e-12)
+}
+
+func TestReadMT5_DailyWithoutTime(t *testing.T) {
+	input := "<DATE>\t<OPEN>\t<HIGH>\t<LOW>\t<CLOSE>\t<TICKVOL>\t<VOL>\t<SPREAD>\n" +
+		"2024.01.02\t1.27150\t1.27200\t1.27100\t1.27180\t123\t0\t12\n" +
+		"2024.01.03\t1.27180\tbad\t1.27100\t1.27180\t123\t0\t12\n"
+
+	_, err := ReadMT5(strings.NewReader(input), Options{})
+	assert.ErrorContains(t, err, "line 3: high: invalid number \"bad\"")
+}
@@ -0,0 +1,58 @@
+package oanda
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// GetAccount returns the full details of the account, including its open trades, positions and pending orders
+func (s *OandaService) GetAccount(ctx context.Context) (*AccountResponse, error) {
+	var resp AccountResponse
+	if err := s.do(ctx, http.MethodGet, s.accountPath(""), nil, nil, &resp); err != nil {
+		return nil, fmt.Errorf("failed to fetch account: %w", err)
+	}
+	return &resp, nil
+}
+
+// GetAccountSummary returns the account's balance, margin and PnL without its trades, positions and orders
+func (s *OandaService) GetAccountSummary(ctx context.Context) (*AccountSummaryResponse, error) {
+	var resp AccountSummaryResponse
+	if err := s.do(ctx, http.MethodGet, s.accountPath("/summary"), nil, nil, &resp); err != nil {
+		return nil, fmt.Errorf("failed to fetch account summary: %w", err)
+	}
+	return &resp, nil
+}
+
+// GetInstruments returns the instruments the account can trade, limited to names when any are given
+func (s *OandaService) GetInstruments(ctx context.Context, names ...InstrumentName) (*InstrumentsResponse, error) {
+	params := url.Values{}
+	if len(names) > 0 {
+		list := make([]string, len(names))
+		for i, name := range names {
+			list[i] = string(name)
+		}
+		params.Add("instruments", strings.Join(list, ","))
+	}
+
+	var resp InstrumentsResponse
+	if err := s.do(ctx, http.MethodGet, s.accountPath("/instruments"), params, nil, &resp); err != nil {
+		return nil, fmt.Errorf("failed to fetch instruments: %w", err)
+	}
+	return &resp, nil
+}
+
+// GetAccountChanges returns what has happened to the account since the transaction, and its current
+// price dependent state. Poll with the returned LastTransactionID to follow the account.
+func (s *OandaService) GetAccountChanges(ctx context.Context, sinceTransactionID TransactionID) (*AccountChangesResponse, error) {
+	params := url.Values{}
+	params.Add("sinceTransactionID", string(sinceTransactionID))
+
+	var resp AccountChangesResponse
+	if err := s.do(ctx, http.MethodGet, s.accountPath("/changes"), params, nil, &resp); err != nil {
+		return nil, fmt.Errorf("failed to fetch account changes: %w", err)
+	}
+	return &resp, nil
+}
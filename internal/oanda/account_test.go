@@ -0,0 +1,132 @@
+package oanda
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestService returns a service pointed at a stand-in v20 server for account 101-001
+func newTestService(t *testing.T, handler http.HandlerFunc) *OandaService {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return NewOandaService("101-001", "key", server.URL)
+}
+
+func TestGetAccount(t *testing.T) {
+	s := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method)
+		assert.Equal(t, "/v3/accounts/101-001", r.URL.Path)
+		assert.Equal(t, "Bearer key", r.Header.Get("Authorization"))
+		w.Write([]byte(`{
+			"account": {
+				"id": "101-001",
+				"currency": "GBP",
+				"balance": "10000.0000",
+				"NAV": "10012.5000",
+				"openTradeCount": 1,
+				"trades": [{"id": "42", "instrument": "GBP_USD", "price": "1.26500", "currentUnits": "1000", "unrealizedPL": "12.5000"}],
+				"positions": [{"instrument": "GBP_USD", "long": {"units": "1000", "averagePrice": "1.26500", "tradeIDs": ["42"]}, "short": {"units": "0"}}],
+				"orders": [{"id": "43", "type": "STOP_LOSS", "state": "PENDING", "tradeID": "42", "price": "1.26000"}]
+			},
+			"lastTransactionID": "44"
+		}`))
+	})
+
+	resp, err := s.GetAccount(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, TransactionID("44"), resp.LastTransactionID)
+
+	account := resp.Account
+	assert.Equal(t, AccountID("101-001"), account.ID)
+	assert.Equal(t, "GBP", account.Currency)
+	balance, err := account.Balance.Float64()
+	assert.NoError(t, err)
+	assert.Equal(t, 10000.0, balance)
+	assert.Equal(t, 1, account.OpenTradeCount)
+
+	assert.Equal(t, 1, len(account.Trades))
+	assert.Equal(t, TradeID("42"), account.Trades[0].ID)
+	assert.Equal(t, []TradeID{"42"}, account.Positions[0].Long.TradeIDs)
+	assert.Equal(t, TradeID("42"), account.Orders[0].TradeID)
+}
+
+func TestGetAccountSummary(t *testing.T) {
+	s := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v3/accounts/101-001/summary", r.URL.Path)
+		w.Write([]byte(`{
+			"account": {"id": "101-001", "currency": "GBP", "balance": "9950.2500", "marginUsed": "42.1000", "marginAvailable": "9908.1500", "marginRate": "0.0333"},
+			"lastTransactionID": "50"
+		}`))
+	})
+
+	resp, err := s.GetAccountSummary(context.Background())
+	assert.NoError(t, err)
+
+	marginAvailable, err := resp.Account.MarginAvailable.Float64()
+	assert.NoError(t, err)
+	assert.Equal(t, 9908.15, marginAvailable)
+	marginRate, err := resp.Account.MarginRate.Float64()
+	assert.NoError(t, err)
+	assert.Equal(t, 0.0333, marginRate)
+}
+
+func TestGetInstruments_FiltersByName(t *testing.T) {
+	s := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v3/accounts/101-001/instruments", r.URL.Path)
+		assert.Equal(t, "GBP_USD,NAS100_USD", r.URL.Query().Get("instruments"))
+		w.Write([]byte(`{"instruments": [
+			{"name": "GBP_USD", "pipLocation": -4, "marginRate": "0.0333"},
+			{"name": "NAS100_USD", "pipLocation": 0, "marginRate": "0.05"}
+		]}`))
+	})
+
+	resp, err := s.GetInstruments(context.Background(), GBPUSD, NAS100)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(resp.Instruments))
+	assert.Equal(t, NAS100, resp.Instruments[1].Name)
+	assert.Equal(t, DecimalNumber("0.05"), resp.Instruments[1].MarginRate)
+}
+
+func TestGetAccountChanges(t *testing.T) {
+	s := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v3/accounts/101-001/changes", r.URL.Path)
+		assert.Equal(t, "44", r.URL.Query().Get("sinceTransactionID"))
+		w.Write([]byte(`{
+			"changes": {
+				"tradesClosed": [{"id": "42", "instrument": "GBP_USD", "state": "CLOSED", "realizedPL": "-5.0000"}],
+				"transactions": [{"id": "45", "type": "ORDER_FILL", "instrument": "GBP_USD", "units": "-1000", "pl": "-5.0000", "reason": "STOP_LOSS_ORDER"}]
+			},
+			"state": {"NAV": "9995.0000", "marginUsed": "0.0000", "positions": [{"instrument": "GBP_USD", "netUnrealizedPL": "0.0000"}]},
+			"lastTransactionID": "45"
+		}`))
+	})
+
+	resp, err := s.GetAccountChanges(context.Background(), "44")
+	assert.NoError(t, err)
+	assert.Equal(t, TransactionID("45"), resp.LastTransactionID)
+	assert.Equal(t, "CLOSED", resp.Changes.TradesClosed[0].State)
+	assert.Equal(t, "STOP_LOSS_ORDER", resp.Changes.Transactions[0].Reason)
+	assert.Equal(t, DecimalNumber("9995.0000"), resp.State.NAV)
+	assert.Equal(t, GBPUSD, resp.State.Positions[0].Instrument)
+}
+
+func TestAccountEndpoints_DecodeAPIErrors(t *testing.T) {
+	s := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"errorMessage": "The account specified is not accessible", "errorCode": "ACCOUNT_NOT_ACCESSIBLE"}`))
+	})
+
+	_, err := s.GetAccountSummary(context.Background())
+	assert.ErrorContains(t, err, "failed to fetch account summary")
+
+	var apiErr *APIError
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusForbidden, apiErr.StatusCode)
+	assert.Equal(t, "ACCOUNT_NOT_ACCESSIBLE", apiErr.ErrorCode)
+	assert.Equal(t, "The account specified is not accessible", apiErr.ErrorMessage)
+}
@@ -0,0 +1,148 @@
+package oanda
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// APIError is a non 2xx response from the v20 API
+type APIError struct {
+	StatusCode   int
+	ErrorCode    string `json:"errorCode"`
+	ErrorMessage string `json:"errorMessage"`
+	// Rejections are the reject transactions in the response, eg orderRejectTransaction or
+	// stopLossOrderRejectTransaction, ordered by the field they were found in
+	Rejections []Transaction `json:"-"`
+	// Body is the raw response, for errors that carry more than a message
+	Body []byte `json:"-"`
+}
+
+func (e *APIError) Error() string {
+	code := e.ErrorCode
+	if code == "" {
+		code = e.RejectReason()
+	}
+	if e.ErrorMessage == "" {
+		return fmt.Sprintf("status code %d, API Response: %s", e.StatusCode, string(e.Body))
+	}
+	if code == "" {
+		return fmt.Sprintf("status code %d: %s", e.StatusCode, e.ErrorMessage)
+	}
+	return fmt.Sprintf("status code %d: %s (%s)", e.StatusCode, e.ErrorMessage, code)
+}
+
+// RejectReason returns the reason of the first reject transaction, eg INSUFFICIENT_MARGIN, empty if there wasn't one
+func (e *APIError) RejectReason() string {
+	for _, rejection := range e.Rejections {
+		if rejection.RejectReason != "" {
+			return rejection.RejectReason
+		}
+	}
+	return ""
+}
+
+// RejectReason returns the reject reason of err if it's an *APIError, empty otherwise
+func RejectReason(err error) string {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.RejectReason()
+	}
+	return ""
+}
+
+// decodeAPIError decodes an error response, collecting any reject transactions it carries
+func decodeAPIError(statusCode int, body []byte) *APIError {
+	apiErr := &APIError{StatusCode: statusCode, Body: body}
+	// Best effort, the raw body is kept if it isn't the usual error shape
+	if err := json.Unmarshal(body, apiErr); err != nil {
+		return apiErr
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return apiErr
+	}
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		if strings.HasSuffix(name, "RejectTransaction") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		var rejection Transaction
+		if err := json.Unmarshal(fields[name], &rejection); err == nil {
+			apiErr.Rejections = append(apiErr.Rejections, rejection)
+		}
+	}
+	return apiErr
+}
+
+// accountPath returns the path of an endpoint under the service's account
+func (s *OandaService) accountPath(endpoint string) string {
+	return "/v3/accounts/" + s.AccountId + endpoint
+}
+
+// do sends a request to the v20 API, encoding body as JSON when it isn't nil and decoding
+// a successful response into out. Responses outside 2xx are returned as an *APIError.
+func (s *OandaService) do(ctx context.Context, method, path string, params url.Values, body, out any) error {
+	fullURL := s.ApiUrl + path
+	if len(params) > 0 {
+		fullURL += "?" + params.Encode()
+	}
+
+	var reqBody io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request body: %w", err)
+		}
+		reqBody = bytes.NewReader(encoded)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, method, fullURL, reqBody)
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+s.ApiKey)
+	httpReq.Header.Set("Accept-Datetime-Format", "RFC3339")
+	if body != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+
+	slog.Debug("Sending Oanda request", "method", method, "url", fullURL)
+
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response body: status code %d: %w", resp.StatusCode, err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		apiErr := decodeAPIError(resp.StatusCode, respBody)
+		slog.Error("Oanda API returned an error status", "method", method, "url", fullURL, "status_code", resp.StatusCode, "response", string(respBody))
+		return apiErr
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/jwtly10/tradebook/internal/types"
@@ -16,56 +17,44 @@ import (
 
 const (
 	DefaultBaseUrl       = "https://api-fxpractice.oanda.com"
+	DefaultStreamUrl     = "https://stream-fxpractice.oanda.com"
 	MaxCandlesPerRequest = 4000 // Limit is 5000 but we maintain a buffer
 
 	// Oanda granularities
-	M1  CandlestickGranularity = "M1"
-	M5  CandlestickGranularity = "M5"
-	M15 CandlestickGranularity = "M15"
-	M30 CandlestickGranularity = "M30"
-	H1  CandlestickGranularity = "H1"
-	H6  CandlestickGranularity = "H6"
-	D   CandlestickGranularity = "D"
-	W   CandlestickGranularity = "W"
-	M   CandlestickGranularity = "M"
+	S5  = types.S5
+	S10 = types.S10
+	S15 = types.S15
+	S30 = types.S30
+	M1  = types.M1
+	M2  = types.M2
+	M4  = types.M4
+	M5  = types.M5
+	M10 = types.M10
+	M15 = types.M15
+	M30 = types.M30
+	H1  = types.H1
+	H2  = types.H2
+	H3  = types.H3
+	H4  = types.H4
+	H6  = types.H6
+	H8  = types.H8
+	H12 = types.H12
+	D   = types.D
+	W   = types.W
+	M   = types.M
+
+	// Oanda price components
+	MID         = types.MID
+	BID         = types.BID
+	ASK         = types.ASK
+	BID_ASK     = types.BID_ASK
+	MID_BID_ASK = types.MID_BID_ASK
 
 	// Oanda Instruments
 	GBPUSD InstrumentName = "GBP_USD"
 	NAS100 InstrumentName = "NAS100_USD"
 )
 
-var granularityToDuration = map[CandlestickGranularity]time.Duration{
-	M1:  1 * time.Minute,
-	M5:  5 * time.Minute,
-	M15: 15 * time.Minute,
-	M30: 30 * time.Minute,
-	H1:  1 * time.Hour,
-	H6:  6 * time.Hour,
-	D:   24 * time.Hour,
-	W:   7 * 24 * time.Hour,
-	M:   30 * 24 * time.Hour, // Approx
-}
-
-func (g CandlestickGranularity) ToDuration() (time.Duration, error) {
-	duration, ok := granularityToDuration[g]
-	if !ok {
-		return 0, fmt.Errorf("invalid granularity: %s", g)
-	}
-	return duration, nil
-}
-
-func (g CandlestickGranularity) MustToDuration() time.Duration {
-	duration, err := g.ToDuration()
-	if err != nil {
-		panic(err)
-	}
-	return duration
-}
-
-func (g CandlestickGranularity) String() string {
-	return string(g)
-}
-
 func NewOandaService(accountId, apiKey, apiUrl string) *OandaService {
 	if apiUrl == "" {
 		apiUrl = DefaultBaseUrl
@@ -75,7 +64,17 @@ func NewOandaService(accountId, apiKey, apiUrl string) *OandaService {
 		AccountId: accountId,
 		ApiKey:    apiKey,
 		ApiUrl:    apiUrl,
+		StreamUrl: streamUrl(apiUrl),
+	}
+}
+
+// streamUrl returns the stream host paired with a REST host, eg stream-fxtrade.oanda.com for api-fxtrade.oanda.com.
+// Anything else, like a local mock server, is assumed to serve streams itself.
+func streamUrl(apiUrl string) string {
+	if apiUrl == DefaultBaseUrl {
+		return DefaultStreamUrl
 	}
+	return strings.Replace(apiUrl, "://api-fx", "://stream-fx", 1)
 }
 
 // FetchBars will iteratativly fetch all bars between 2 dates.
@@ -106,6 +105,7 @@ func (s *OandaService) FetchBars(ctx context.Context, req CandleRequest) ([]type
 		newReq := CandleRequest{
 			Instrument:  req.Instrument,
 			Granularity: req.Granularity,
+			Price:       req.Price,
 			From:        currentFrom,
 			To:          batchTo,
 		}
@@ -137,6 +137,8 @@ func (s *OandaService) FetchBars(ctx context.Context, req CandleRequest) ([]type
 	return allBars, nil
 }
 
+// candlesToBars converts candles to bars, carrying bid and ask prices when they were requested.
+// If mid prices weren't requested the bar's OHLC is derived from the bid and ask.
 func (s *OandaService) candlesToBars(candles []Candlestick) ([]types.Bar, error) {
 	bars := make([]types.Bar, 0, len(candles))
 	for _, candle := range candles {
@@ -145,36 +147,101 @@ func (s *OandaService) candlesToBars(candles []Candlestick) ([]types.Bar, error)
 			return nil, fmt.Errorf("failed to parse candle time %s: %w", candle.Time, err)
 		}
 
-		o, err := strconv.ParseFloat(string(candle.Mid.O), 64)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse candle open price %s: %w", candle.Mid.O, err)
+		bar := types.Bar{
+			Timestamp: timestamp,
+			Volume:    float64(candle.Volume),
+			Partial:   !candle.Complete,
 		}
-		h, err := strconv.ParseFloat(string(candle.Mid.H), 64)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse candle high price %s: %w", candle.Mid.H, err)
+
+		if candle.Bid.O != "" {
+			bar.Bid, err = parseCandleData(candle.Bid, "bid")
+			if err != nil {
+				return nil, err
+			}
 		}
-		l, err := strconv.ParseFloat(string(candle.Mid.L), 64)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse candle low price %s: %w", candle.Mid.L, err)
+		if candle.Ask.O != "" {
+			bar.Ask, err = parseCandleData(candle.Ask, "ask")
+			if err != nil {
+				return nil, err
+			}
 		}
-		c, err := strconv.ParseFloat(string(candle.Mid.C), 64)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse candle close price %s: %w", candle.Mid.C, err)
+
+		var mid types.OHLC
+		switch {
+		case candle.Mid.O != "":
+			mid, err = parseCandleData(candle.Mid, "mid")
+			if err != nil {
+				return nil, err
+			}
+		case bar.HasQuotes():
+			mid = types.OHLC{
+				Open:  (bar.Bid.Open + bar.Ask.Open) / 2,
+				High:  (bar.Bid.High + bar.Ask.High) / 2,
+				Low:   (bar.Bid.Low + bar.Ask.Low) / 2,
+				Close: (bar.Bid.Close + bar.Ask.Close) / 2,
+			}
+		// Candles requested with only one side are priced from that side
+		case candle.Bid.O != "":
+			mid = bar.Bid
+		case candle.Ask.O != "":
+			mid = bar.Ask
+		default:
+			return nil, fmt.Errorf("candle at %s has no mid, bid or ask prices", candle.Time)
 		}
-		v := float64(candle.Volume)
 
-		bars = append(bars, types.Bar{
-			Timestamp: timestamp,
-			Open:      o,
-			High:      h,
-			Low:       l,
-			Close:     c,
-			Volume:    v,
-		})
+		bar.Open = mid.Open
+		bar.High = mid.High
+		bar.Low = mid.Low
+		bar.Close = mid.Close
+
+		// Spread is only known when the candle was requested with bid and ask prices
+		if bar.HasQuotes() {
+			bar.Spread = bar.Ask.Close - bar.Bid.Close
+		}
+
+		bars = append(bars, bar)
 	}
 	return bars, nil
 }
 
+func parseCandleData(data CandleStickData, component string) (types.OHLC, error) {
+	o, err := strconv.ParseFloat(string(data.O), 64)
+	if err != nil {
+		return types.OHLC{}, fmt.Errorf("failed to parse candle %s open price %s: %w", component, data.O, err)
+	}
+	h, err := strconv.ParseFloat(string(data.H), 64)
+	if err != nil {
+		return types.OHLC{}, fmt.Errorf("failed to parse candle %s high price %s: %w", component, data.H, err)
+	}
+	l, err := strconv.ParseFloat(string(data.L), 64)
+	if err != nil {
+		return types.OHLC{}, fmt.Errorf("failed to parse candle %s low price %s: %w", component, data.L, err)
+	}
+	c, err := strconv.ParseFloat(string(data.C), 64)
+	if err != nil {
+		return types.OHLC{}, fmt.Errorf("failed to parse candle %s close price %s: %w", component, data.C, err)
+	}
+
+	return types.OHLC{Open: o, High: h, Low: l, Close: c}, nil
+}
+
+// LatestCandles returns the most recent count candles as bars, oldest first.
+// The last is usually still forming and is marked Partial.
+func (s *OandaService) LatestCandles(ctx context.Context, instrument InstrumentName, granularity CandlestickGranularity, price PriceComponent, count int) ([]types.Bar, error) {
+	params := url.Values{}
+	params.Add("granularity", string(granularity))
+	if price != "" {
+		params.Add("price", string(price))
+	}
+	params.Add("count", strconv.Itoa(count))
+
+	var resp CandlestickResponse
+	if err := s.do(ctx, http.MethodGet, s.accountPath("/instruments/"+string(instrument)+"/candles"), params, nil, &resp); err != nil {
+		return nil, fmt.Errorf("failed to fetch latest candles for %s: %w", instrument, err)
+	}
+	return s.candlesToBars(resp.Candles)
+}
+
 func (s *OandaService) fetchHistoricCandles(ctx context.Context, req CandleRequest) (*CandlestickResponse, error) {
 	endpoint := s.ApiUrl + "/v3/accounts/" + s.AccountId + "/instruments/" + string(req.Instrument) + "/candles"
 
@@ -182,8 +249,11 @@ func (s *OandaService) fetchHistoricCandles(ctx context.Context, req CandleReque
 	if req.Granularity != "" {
 		params.Add("granularity", string(req.Granularity))
 	}
+	if req.Price != "" {
+		params.Add("price", string(req.Price))
+	}
 	if req.Count != 0 {
-		params.Add("count", string(rune(req.Count)))
+		params.Add("count", strconv.Itoa(req.Count))
 	}
 
 	params.Add("from", strconv.FormatInt(req.From.Unix(), 10))
@@ -192,7 +262,7 @@ func (s *OandaService) fetchHistoricCandles(ctx context.Context, req CandleReque
 
 	fullURL := endpoint + "?" + params.Encode()
 
-	slog.Info("Fetching historic candles", "instrument", req.Instrument, "from", req.From, "to", req.To)
+	slog.Info("Fetching historic candles", "instrument", req.Instrument, "price", req.Price, "from", req.From, "to", req.To)
 	slog.Debug("Request URL", "url", fullURL)
 
 	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, fullURL, nil)
@@ -235,3 +305,82 @@ func (s *OandaService) fetchHistoricCandles(ctx context.Context, req CandleReque
 
 	return &candleResp, nil
 }
+
+// FetchInstruments returns metadata for every instrument the account can trade, or only those named
+func (s *OandaService) FetchInstruments(ctx context.Context, names ...InstrumentName) ([]types.Instrument, error) {
+	slog.Info("Fetching account instruments", "instruments", names)
+
+	resp, err := s.GetInstruments(ctx, names...)
+	if err != nil {
+		return nil, err
+	}
+
+	instruments := make([]types.Instrument, 0, len(resp.Instruments))
+	for _, instrument := range resp.Instruments {
+		converted, err := instrument.toInstrument()
+		if err != nil {
+			return nil, err
+		}
+		instruments = append(instruments, converted)
+	}
+	return instruments, nil
+}
+
+// toInstrument converts Oanda's metadata, taking the quote currency from the instrument name
+func (i Instrument) toInstrument() (types.Instrument, error) {
+	instrument := types.Instrument{
+		Name:                string(i.Name),
+		DisplayName:         i.DisplayName,
+		Type:                i.Type,
+		PipLocation:         i.PipLocation,
+		DisplayPrecision:    i.DisplayPrecision,
+		TradeUnitsPrecision: i.TradeUnitsPrecision,
+	}
+	if _, quote, ok := strings.Cut(instrument.Name, "_"); ok {
+		instrument.QuoteCurrency = quote
+	}
+
+	fields := []struct {
+		name  string
+		value DecimalNumber
+		dest  *float64
+	}{
+		{"minimumTradeSize", i.MinimumTradeSize, &instrument.MinimumTradeSize},
+		{"maximumOrderUnits", i.MaximumOrderUnits, &instrument.MaximumOrderUnits},
+		{"minimumTrailingStopDistance", i.MinimumTrailingStopDistance, &instrument.MinimumTrailingStopDistance},
+		{"marginRate", i.MarginRate, &instrument.MarginRate},
+		{"financing.longRate", i.Financing.LongRate, &instrument.FinancingLong},
+		{"financing.shortRate", i.Financing.ShortRate, &instrument.FinancingShort},
+	}
+	for _, f := range fields {
+		v, err := f.value.Float64()
+		if err != nil {
+			return types.Instrument{}, fmt.Errorf("failed to parse %s %s of %s: %w", f.name, f.value, i.Name, err)
+		}
+		*f.dest = v
+	}
+
+	// The day charged for the weekend, Wednesday for FX and Friday for most CFDs
+	for _, day := range i.Financing.DaysOfWeek {
+		if day.DaysCharged <= 1 {
+			continue
+		}
+		weekday, ok := weekdays[day.DayOfWeek]
+		if !ok {
+			return types.Instrument{}, fmt.Errorf("failed to parse financing day %s of %s", day.DayOfWeek, i.Name)
+		}
+		instrument.FinancingTripleDay = weekday
+	}
+	return instrument, nil
+}
+
+// weekdays maps Oanda's DayOfWeek values
+var weekdays = map[string]time.Weekday{
+	"SUNDAY":    time.Sunday,
+	"MONDAY":    time.Monday,
+	"TUESDAY":   time.Tuesday,
+	"WEDNESDAY": time.Wednesday,
+	"THURSDAY":  time.Thursday,
+	"FRIDAY":    time.Friday,
+	"SATURDAY":  time.Saturday,
+}
@@ -0,0 +1,147 @@
+package oanda
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jwtly10/tradebook/internal/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCandlesToBars_CarriesBidAndAsk(t *testing.T) {
+	s := NewOandaService("", "", "")
+
+	candles := []Candlestick{
+		{
+			Time:   "2025-10-23T13:30:00Z",
+			Bid:    CandleStickData{O: "1.0", H: "2.0", L: "0.5", C: "1.5"},
+			Ask:    CandleStickData{O: "1.2", H: "2.2", L: "0.7", C: "1.7"},
+			Volume: 10,
+		},
+	}
+
+	bars, err := s.candlesToBars(candles)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(bars))
+
+	bar := bars[0]
+	assert.Equal(t, time.Date(2025, 10, 23, 13, 30, 0, 0, time.UTC), bar.Timestamp)
+	assert.Equal(t, types.OHLC{Open: 1.0, High: 2.0, Low: 0.5, Close: 1.5}, bar.Bid)
+	assert.Equal(t, types.OHLC{Open: 1.2, High: 2.2, Low: 0.7, Close: 1.7}, bar.Ask)
+	// Mid is derived from the bid and ask when it wasn't requested
+	assert.InDelta(t, 1.1, bar.Open, 1e-9)
+	assert.InDelta(t, 1.6, bar.Close, 1e-9)
+	assert.InDelta(t, 0.2, bar.Spread, 1e-9)
+	assert.Equal(t, types.OHLC{Open: 1.2, High: 2.2, Low: 0.7, Close: 1.7}, bar.Side(types.BUY))
+	assert.Equal(t, types.OHLC{Open: 1.0, High: 2.0, Low: 0.5, Close: 1.5}, bar.Side(types.SELL))
+}
+
+func TestCandlesToBars_MidOnly(t *testing.T) {
+	s := NewOandaService("", "", "")
+
+	bars, err := s.candlesToBars([]Candlestick{
+		{Time: "2025-10-23T13:30:00Z", Mid: CandleStickData{O: "1", H: "2", L: "0.5", C: "1.5"}},
+	})
+	assert.NoError(t, err)
+	assert.False(t, bars[0].HasQuotes())
+	assert.Equal(t, float64(0), bars[0].Spread)
+	assert.Equal(t, bars[0].Mid(), bars[0].Side(types.BUY))
+
+	_, err = s.candlesToBars([]Candlestick{
+		{Time: "2025-10-23T13:30:00Z", Mid: CandleStickData{O: "abc", H: "2", L: "0.5", C: "1.5"}},
+	})
+	assert.ErrorContains(t, err, "failed to parse candle mid open price abc")
+}
+
+func TestFetchHistoricCandles_SendsCount(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "5000", r.URL.Query().Get("count"))
+		w.Write([]byte(`{"candles": []}`))
+	}))
+	defer server.Close()
+
+	s := NewOandaService("101-001", "key", server.URL)
+	_, err := s.fetchHistoricCandles(context.Background(), CandleRequest{Instrument: GBPUSD, Granularity: M15, Count: 5000})
+	assert.NoError(t, err)
+}
+
+func TestCandlesToBars_SingleSide(t *testing.T) {
+	s := NewOandaService("", "", "")
+
+	bars, err := s.candlesToBars([]Candlestick{
+		{Time: "2025-10-23T13:30:00Z", Bid: CandleStickData{O: "1", H: "2", L: "0.5", C: "1.5"}},
+	})
+	assert.NoError(t, err)
+	assert.False(t, bars[0].HasQuotes())
+	assert.Equal(t, types.OHLC{Open: 1, High: 2, Low: 0.5, Close: 1.5}, bars[0].Mid())
+	assert.Equal(t, bars[0].Mid(), bars[0].Bid)
+	assert.Equal(t, float64(0), bars[0].Spread)
+
+	bars, err = s.candlesToBars([]Candlestick{
+		{Time: "2025-10-23T13:30:00Z", Ask: CandleStickData{O: "1.1", H: "2.1", L: "0.6", C: "1.6"}},
+	})
+	assert.NoError(t, err)
+	assert.False(t, bars[0].HasQuotes())
+	assert.Equal(t, types.OHLC{Open: 1.1, High: 2.1, Low: 0.6, Close: 1.6}, bars[0].Mid())
+	assert.Equal(t, bars[0].Mid(), bars[0].Side(types.SELL))
+
+	_, err = s.candlesToBars([]Candlestick{{Time: "2025-10-23T13:30:00Z"}})
+	assert.ErrorContains(t, err, "has no mid, bid or ask prices")
+}
+
+func TestFetchInstruments(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v3/accounts/101-001/instruments", r.URL.Path)
+		assert.Equal(t, "Bearer key", r.Header.Get("Authorization"))
+		w.Write([]byte(`{"instruments": [{
+			"name": "GBP_USD",
+			"type": "CURRENCY",
+			"displayName": "GBP/USD",
+			"pipLocation": -4,
+			"displayPrecision": 5,
+			"tradeUnitsPrecision": 0,
+			"minimumTradeSize": "1",
+			"minimumTrailingStopDistance": "0.00050",
+			"maximumOrderUnits": "100000000",
+			"marginRate": "0.0333",
+			"financing": {"longRate": "-0.0421", "shortRate": "0.0165", "financingDaysOfWeek": [
+				{"dayOfWeek": "MONDAY", "daysCharged": 1},
+				{"dayOfWeek": "WEDNESDAY", "daysCharged": 3},
+				{"dayOfWeek": "SATURDAY", "daysCharged": 0}
+			]}
+		}]}`))
+	}))
+	defer server.Close()
+
+	instruments, err := NewOandaService("101-001", "key", server.URL).FetchInstruments(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []types.Instrument{{
+		Name:                        "GBP_USD",
+		DisplayName:                 "GBP/USD",
+		Type:                        "CURRENCY",
+		PipLocation:                 -4,
+		DisplayPrecision:            5,
+		MarginRate:                  0.0333,
+		QuoteCurrency:               "USD",
+		MinimumTradeSize:            1,
+		MaximumOrderUnits:           100000000,
+		MinimumTrailingStopDistance: 0.0005,
+		FinancingLong:               -0.0421,
+		FinancingShort:              0.0165,
+		FinancingTripleDay:          time.Wednesday,
+	}}, instruments)
+}
+
+func TestFetchInstruments_ErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"errorMessage": "Insufficient authorization to perform request."}`))
+	}))
+	defer server.Close()
+
+	_, err := NewOandaService("101-001", "bad", server.URL).FetchInstruments(context.Background())
+	assert.ErrorContains(t, err, "status code 401")
+}
@@ -0,0 +1,208 @@
+package oanda
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jwtly10/tradebook/internal/types"
+)
+
+// OrderCancelledError is returned when an order was created but cancelled straight away,
+// eg a market order that couldn't fill because of insufficient margin or a closed market
+type OrderCancelledError struct {
+	Reason      string
+	Transaction Transaction
+}
+
+func (e *OrderCancelledError) Error() string {
+	return fmt.Sprintf("order %s was cancelled: %s", e.Transaction.OrderID, e.Reason)
+}
+
+// CreateOrder submits an order. A market order that is cancelled rather than filled returns
+// the response alongside an *OrderCancelledError, rejected orders return an *APIError.
+func (s *OandaService) CreateOrder(ctx context.Context, order OrderRequest) (*OrderCreateResponse, error) {
+	slog.Info("Creating Oanda order", "type", order.Type, "instrument", order.Instrument, "units", order.Units, "price", order.Price)
+
+	var resp OrderCreateResponse
+	if err := s.do(ctx, http.MethodPost, s.accountPath("/orders"), nil, createOrderBody{Order: order}, &resp); err != nil {
+		return nil, fmt.Errorf("failed to create %s order for %s: %w", order.Type, order.Instrument, err)
+	}
+	if cancel := resp.OrderCancelTransaction; cancel != nil {
+		return &resp, &OrderCancelledError{Reason: cancel.Reason, Transaction: *cancel}
+	}
+	return &resp, nil
+}
+
+// CloseTrade closes some or all of an open trade. A close that is cancelled rather than filled
+// returns the response alongside an *OrderCancelledError.
+func (s *OandaService) CloseTrade(ctx context.Context, tradeID TradeID, req TradeCloseRequest) (*TradeCloseResponse, error) {
+	if req.Units == "" {
+		req.Units = "ALL"
+	}
+
+	var resp TradeCloseResponse
+	if err := s.do(ctx, http.MethodPut, s.accountPath("/trades/"+string(tradeID)+"/close"), nil, req, &resp); err != nil {
+		return nil, fmt.Errorf("failed to close trade %s: %w", tradeID, err)
+	}
+	if cancel := resp.OrderCancelTransaction; cancel != nil {
+		return &resp, &OrderCancelledError{Reason: cancel.Reason, Transaction: *cancel}
+	}
+	return &resp, nil
+}
+
+// ModifyTrade replaces the take profit, stop loss or trailing stop of an open trade
+func (s *OandaService) ModifyTrade(ctx context.Context, tradeID TradeID, req TradeOrdersRequest) (*TradeOrdersResponse, error) {
+	var resp TradeOrdersResponse
+	if err := s.do(ctx, http.MethodPut, s.accountPath("/trades/"+string(tradeID)+"/orders"), nil, req, &resp); err != nil {
+		return nil, fmt.Errorf("failed to modify trade %s: %w", tradeID, err)
+	}
+	return &resp, nil
+}
+
+// OrderFromSignal maps an OPEN signal to an order request, formatting units and prices to the
+// instrument's precision. STOP_LIMIT becomes a STOP order bounded by the signal's LimitPrice.
+// A market order fills away from the signal's price, so its take profit and stop loss are sent
+// as their distance from it, keeping them the same distance from the fill.
+// Oanda only trails at a fixed distance, so ATR and PERCENT trailing stops are fixed at the
+// distance they give at the signal's price. BreakEven has no v20 equivalent and is left to the caller.
+func OrderFromSignal(signal types.Signal, instrument types.Instrument) (OrderRequest, error) {
+	if signal.Type != types.OPEN {
+		return OrderRequest{}, fmt.Errorf("cannot create an order from a %s signal", signal.Type)
+	}
+	if signal.Size <= 0 {
+		return OrderRequest{}, fmt.Errorf("signal size must be positive, got %v", signal.Size)
+	}
+
+	units := instrument.RoundUnits(signal.Size)
+	if signal.Action == types.SELL {
+		units = -units
+	}
+	name := signal.Instrument
+	if name == "" {
+		name = instrument.Name
+	}
+
+	order := OrderRequest{
+		Instrument:   InstrumentName(name),
+		Units:        formatUnits(units, instrument),
+		PositionFill: "DEFAULT",
+	}
+
+	switch signal.OrderType {
+	case types.MARKET, "":
+		order.Type = ORDER_MARKET
+		order.TimeInForce = FOK
+	case types.LIMIT:
+		order.Type = ORDER_LIMIT
+		order.Price = formatPrice(signal.Price, instrument)
+	case types.STOP:
+		order.Type = ORDER_STOP
+		order.Price = formatPrice(signal.Price, instrument)
+	case types.STOP_LIMIT:
+		order.Type = ORDER_STOP
+		order.Price = formatPrice(signal.Price, instrument)
+		order.PriceBound = formatPrice(signal.LimitPrice, instrument)
+	default:
+		return OrderRequest{}, fmt.Errorf("unsupported order type: %s", signal.OrderType)
+	}
+
+	if signal.IsPending() {
+		order.TimeInForce = GTC
+		if !signal.Expiry.IsZero() {
+			order.TimeInForce = GTD
+			order.GtdTime = signal.Expiry.UTC().Format(time.RFC3339)
+		}
+	}
+
+	if signal.IsPending() {
+		if signal.TP != 0 {
+			order.TakeProfitOnFill = &TakeProfitDetails{Price: formatPrice(signal.TP, instrument)}
+		}
+		if signal.SL != 0 {
+			order.StopLossOnFill = &StopLossDetails{Price: formatPrice(signal.SL, instrument)}
+		}
+	} else if signal.TP != 0 || signal.SL != 0 {
+		if signal.Price <= 0 {
+			return OrderRequest{}, fmt.Errorf("market order take profit and stop loss need a signal price to measure from")
+		}
+		if signal.TP != 0 {
+			order.TakeProfitOnFill = &TakeProfitDetails{Distance: formatDistance(signal.Price-signal.TP, instrument)}
+		}
+		if signal.SL != 0 {
+			order.StopLossOnFill = &StopLossDetails{Distance: formatDistance(signal.Price-signal.SL, instrument)}
+		}
+	}
+	if signal.Trailing != nil {
+		distance, err := trailingDistance(*signal.Trailing, signal.Price)
+		if err != nil {
+			return OrderRequest{}, err
+		}
+		order.TrailingStopLossOnFill = &TrailingStopLossDetails{Distance: formatDistance(distance, instrument)}
+	}
+	return order, nil
+}
+
+// TradeOrdersFromSignal maps the levels of a MODIFY signal to a trade orders request, zero levels are left unchanged
+func TradeOrdersFromSignal(signal types.Signal, instrument types.Instrument) (TradeOrdersRequest, error) {
+	if signal.Type != types.MODIFY {
+		return TradeOrdersRequest{}, fmt.Errorf("cannot modify a trade from a %s signal", signal.Type)
+	}
+
+	var req TradeOrdersRequest
+	if signal.TP != 0 {
+		req.TakeProfit = &TakeProfitDetails{Price: formatPrice(signal.TP, instrument)}
+	}
+	if signal.SL != 0 {
+		req.StopLoss = &StopLossDetails{Price: formatPrice(signal.SL, instrument)}
+	}
+	return req, nil
+}
+
+// TradeCloseFromSignal maps a CLOSE signal to a close of a trade holding units, closing the signal's
+// Fraction of it or all of it when Fraction is zero
+func TradeCloseFromSignal(signal types.Signal, units float64, instrument types.Instrument) (TradeCloseRequest, error) {
+	if signal.Type != types.CLOSE {
+		return TradeCloseRequest{}, fmt.Errorf("cannot close a trade from a %s signal", signal.Type)
+	}
+	if signal.Fraction <= 0 || signal.Fraction >= 1 {
+		return TradeCloseRequest{Units: "ALL"}, nil
+	}
+
+	closeUnits := instrument.RoundUnits(math.Abs(units) * signal.Fraction)
+	if closeUnits <= 0 {
+		return TradeCloseRequest{}, fmt.Errorf("closing %v of %v units rounds to nothing", signal.Fraction, units)
+	}
+	return TradeCloseRequest{Units: string(formatUnits(closeUnits, instrument))}, nil
+}
+
+// trailingDistance returns the price distance of a trailing stop at price
+func trailingDistance(trailing types.TrailingStop, price float64) (float64, error) {
+	switch trailing.Mode {
+	case types.TRAIL_FIXED:
+		return trailing.Distance, nil
+	case types.TRAIL_ATR:
+		return trailing.ATR * trailing.Distance, nil
+	case types.TRAIL_PERCENT:
+		return price * trailing.Distance / 100, nil
+	default:
+		return 0, fmt.Errorf("unsupported trailing stop mode: %s", trailing.Mode)
+	}
+}
+
+func formatPrice(price float64, instrument types.Instrument) PriceValue {
+	return PriceValue(strconv.FormatFloat(instrument.RoundPrice(price), 'f', instrument.DisplayPrecision, 64))
+}
+
+// formatDistance formats the size of a price difference to the instrument's precision
+func formatDistance(distance float64, instrument types.Instrument) DecimalNumber {
+	return DecimalNumber(formatPrice(math.Abs(distance), instrument))
+}
+
+func formatUnits(units float64, instrument types.Instrument) DecimalNumber {
+	return DecimalNumber(strconv.FormatFloat(units, 'f', instrument.TradeUnitsPrecision, 64))
+}
@@ -0,0 +1,208 @@
+package oanda
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jwtly10/tradebook/internal/types"
+	"github.com/stretchr/testify/assert"
+)
+
+var gbpusd = types.Instrument{Name: "GBP_USD", PipLocation: -4, DisplayPrecision: 5, TradeUnitsPrecision: 0}
+
+func TestOrderFromSignal(t *testing.T) {
+	expiry := time.Date(2025, 1, 10, 17, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		signal types.Signal
+		want   OrderRequest
+	}{
+		{
+			name: "Market buy with take profit, stop loss and trailing stop",
+			signal: types.Signal{Type: types.OPEN, Action: types.BUY, Price: 1.265, TP: 1.27, SL: 1.26, Size: 1000.7,
+				Trailing: &types.TrailingStop{Mode: types.TRAIL_FIXED, Distance: 0.0025}},
+			want: OrderRequest{Type: ORDER_MARKET, Instrument: "GBP_USD", Units: "1000", TimeInForce: FOK, PositionFill: "DEFAULT",
+				TakeProfitOnFill:       &TakeProfitDetails{Distance: "0.00500"},
+				StopLossOnFill:         &StopLossDetails{Distance: "0.00500"},
+				TrailingStopLossOnFill: &TrailingStopLossDetails{Distance: "0.00250"}},
+		},
+		{
+			name:   "Market sell levels are distances from the signal price",
+			signal: types.Signal{Type: types.OPEN, Action: types.SELL, Price: 1.26512, TP: 1.255, SL: 1.27004, Size: 1000},
+			want: OrderRequest{Type: ORDER_MARKET, Instrument: "GBP_USD", Units: "-1000", TimeInForce: FOK, PositionFill: "DEFAULT",
+				TakeProfitOnFill: &TakeProfitDetails{Distance: "0.01012"},
+				StopLossOnFill:   &StopLossDetails{Distance: "0.00492"}},
+		},
+		{
+			name:   "Pending order levels are prices",
+			signal: types.Signal{Type: types.OPEN, Action: types.BUY, OrderType: types.LIMIT, Price: 1.25, TP: 1.26, SL: 1.245, Size: 100},
+			want: OrderRequest{Type: ORDER_LIMIT, Instrument: "GBP_USD", Units: "100", Price: "1.25000", TimeInForce: GTC, PositionFill: "DEFAULT",
+				TakeProfitOnFill: &TakeProfitDetails{Price: "1.26000"},
+				StopLossOnFill:   &StopLossDetails{Price: "1.24500"}},
+		},
+		{
+			name:   "Limit sell good till the expiry",
+			signal: types.Signal{Type: types.OPEN, Action: types.SELL, OrderType: types.LIMIT, Price: 1.27123456, Size: 500, Expiry: expiry},
+			want: OrderRequest{Type: ORDER_LIMIT, Instrument: "GBP_USD", Units: "-500", Price: "1.27123", TimeInForce: GTD,
+				GtdTime: "2025-01-10T17:00:00Z", PositionFill: "DEFAULT"},
+		},
+		{
+			name:   "Stop limit becomes a bounded stop",
+			signal: types.Signal{Type: types.OPEN, Action: types.BUY, OrderType: types.STOP_LIMIT, Price: 1.27, LimitPrice: 1.2705, Size: 100},
+			want: OrderRequest{Type: ORDER_STOP, Instrument: "GBP_USD", Units: "100", Price: "1.27000", PriceBound: "1.27050",
+				TimeInForce: GTC, PositionFill: "DEFAULT"},
+		},
+		{
+			name: "Percent trailing stop is fixed at the signal price",
+			signal: types.Signal{Type: types.OPEN, Action: types.BUY, Price: 1.25, Size: 100,
+				Trailing: &types.TrailingStop{Mode: types.TRAIL_PERCENT, Distance: 0.2}},
+			want: OrderRequest{Type: ORDER_MARKET, Instrument: "GBP_USD", Units: "100", TimeInForce: FOK, PositionFill: "DEFAULT",
+				TrailingStopLossOnFill: &TrailingStopLossDetails{Distance: "0.00250"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := OrderFromSignal(tt.signal, gbpusd)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err := OrderFromSignal(types.Signal{Type: types.CLOSE, Size: 1}, gbpusd)
+	assert.Error(t, err)
+	_, err = OrderFromSignal(types.Signal{Type: types.OPEN}, gbpusd)
+	assert.ErrorContains(t, err, "size must be positive")
+	_, err = OrderFromSignal(types.Signal{Type: types.OPEN, Size: 1, SL: 1.26}, gbpusd)
+	assert.ErrorContains(t, err, "need a signal price")
+}
+
+func TestTradeCloseFromSignal(t *testing.T) {
+	req, err := TradeCloseFromSignal(types.Signal{Type: types.CLOSE}, 1000, gbpusd)
+	assert.NoError(t, err)
+	assert.Equal(t, "ALL", req.Units)
+
+	req, err = TradeCloseFromSignal(types.Signal{Type: types.CLOSE, Fraction: 0.5}, -1001, gbpusd)
+	assert.NoError(t, err)
+	assert.Equal(t, "500", req.Units)
+}
+
+func TestCreateOrder(t *testing.T) {
+	s := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/v3/accounts/101-001/orders", r.URL.Path)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+		var body map[string]map[string]any
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "MARKET", body["order"]["type"])
+		assert.Equal(t, "-1000", body["order"]["units"])
+		assert.Equal(t, map[string]any{"price": "1.26000"}, body["order"]["stopLossOnFill"])
+		assert.NotContains(t, body["order"], "price")
+
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{
+			"orderCreateTransaction": {"id": "10", "type": "MARKET_ORDER", "instrument": "GBP_USD", "units": "-1000"},
+			"orderFillTransaction": {"id": "11", "type": "ORDER_FILL", "orderID": "10", "price": "1.25500",
+				"tradeOpened": {"tradeID": "11", "units": "-1000", "price": "1.25500"}},
+			"relatedTransactionIDs": ["10", "11", "12"],
+			"lastTransactionID": "12"
+		}`))
+	})
+
+	resp, err := s.CreateOrder(context.Background(), OrderRequest{
+		Type: ORDER_MARKET, Instrument: GBPUSD, Units: "-1000", TimeInForce: FOK,
+		StopLossOnFill: &StopLossDetails{Price: "1.26000"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, TradeID("11"), resp.OrderFillTransaction.TradeOpened.TradeID)
+	assert.Equal(t, PriceValue("1.25500"), resp.OrderFillTransaction.TradeOpened.Price)
+}
+
+func TestCreateOrder_Rejected(t *testing.T) {
+	s := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{
+			"orderRejectTransaction": {"id": "20", "type": "MARKET_ORDER_REJECT", "rejectReason": "STOP_LOSS_ON_FILL_PRICE_DISTANCE_MINIMUM_NOT_MET"},
+			"relatedTransactionIDs": ["20"],
+			"lastTransactionID": "20",
+			"errorMessage": "The Stop Loss on fill specified does not satisfy the minimum price distance requirement"
+		}`))
+	})
+
+	_, err := s.CreateOrder(context.Background(), OrderRequest{Type: ORDER_MARKET, Instrument: GBPUSD, Units: "1"})
+	assert.ErrorContains(t, err, "failed to create MARKET order for GBP_USD")
+	assert.ErrorContains(t, err, "STOP_LOSS_ON_FILL_PRICE_DISTANCE_MINIMUM_NOT_MET")
+	assert.Equal(t, "STOP_LOSS_ON_FILL_PRICE_DISTANCE_MINIMUM_NOT_MET", RejectReason(err))
+
+	var apiErr *APIError
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, TransactionID("20"), apiErr.Rejections[0].ID)
+}
+
+func TestCreateOrder_Cancelled(t *testing.T) {
+	s := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{
+			"orderCreateTransaction": {"id": "30", "type": "MARKET_ORDER"},
+			"orderCancelTransaction": {"id": "31", "type": "ORDER_CANCEL", "orderID": "30", "reason": "INSUFFICIENT_MARGIN"},
+			"lastTransactionID": "31"
+		}`))
+	})
+
+	resp, err := s.CreateOrder(context.Background(), OrderRequest{Type: ORDER_MARKET, Instrument: GBPUSD, Units: "100000000"})
+	assert.NotNil(t, resp)
+
+	var cancelled *OrderCancelledError
+	assert.True(t, errors.As(err, &cancelled))
+	assert.Equal(t, "INSUFFICIENT_MARGIN", cancelled.Reason)
+	assert.Equal(t, OrderID("30"), cancelled.Transaction.OrderID)
+}
+
+func TestCloseTrade(t *testing.T) {
+	s := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPut, r.Method)
+		assert.Equal(t, "/v3/accounts/101-001/trades/11/close", r.URL.Path)
+		body, _ := io.ReadAll(r.Body)
+		assert.JSONEq(t, `{"units": "ALL"}`, string(body))
+
+		w.Write([]byte(`{
+			"orderCreateTransaction": {"id": "40", "type": "MARKET_ORDER", "tradeID": "11"},
+			"orderFillTransaction": {"id": "41", "type": "ORDER_FILL", "tradesClosed": [{"tradeID": "11", "units": "1000", "realizedPL": "12.3400"}]},
+			"lastTransactionID": "41"
+		}`))
+	})
+
+	resp, err := s.CloseTrade(context.Background(), "11", TradeCloseRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, DecimalNumber("12.3400"), resp.OrderFillTransaction.TradesClosed[0].RealizedPL)
+}
+
+func TestModifyTrade(t *testing.T) {
+	s := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPut, r.Method)
+		assert.Equal(t, "/v3/accounts/101-001/trades/11/orders", r.URL.Path)
+		body, _ := io.ReadAll(r.Body)
+		assert.JSONEq(t, `{"stopLoss": {"price": "1.25000"}}`, string(body))
+
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{
+			"stopLossOrderRejectTransaction": {"id": "50", "type": "STOP_LOSS_ORDER_REJECT", "tradeID": "11", "rejectReason": "TRADE_DOESNT_EXIST"},
+			"lastTransactionID": "50",
+			"errorCode": "NO_SUCH_TRADE",
+			"errorMessage": "The Trade specified does not exist"
+		}`))
+	})
+
+	req, err := TradeOrdersFromSignal(types.Signal{Type: types.MODIFY, SL: 1.25}, gbpusd)
+	assert.NoError(t, err)
+
+	_, err = s.ModifyTrade(context.Background(), "11", req)
+	assert.ErrorContains(t, err, "failed to modify trade 11: status code 400: The Trade specified does not exist (NO_SUCH_TRADE)")
+	assert.Equal(t, "TRADE_DOESNT_EXIST", RejectReason(err))
+}
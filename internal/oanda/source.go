@@ -0,0 +1,64 @@
+package oanda
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/jwtly10/tradebook/internal/data"
+	"github.com/jwtly10/tradebook/internal/types"
+)
+
+// Source adapts OandaService to a data.DataSource
+type Source struct {
+	service *OandaService
+}
+
+func NewSource(service *OandaService) *Source {
+	return &Source{service: service}
+}
+
+// FetchBars returns all bars starting in [From, To).
+// The candles endpoint excludes the first candle, so the request starts one bar early.
+func (s *Source) FetchBars(ctx context.Context, req data.Request) ([]types.Bar, error) {
+	period, err := req.Granularity.ToDuration()
+	if err != nil {
+		return nil, err
+	}
+
+	bars, err := s.service.FetchBars(ctx, CandleRequest{
+		Instrument:  InstrumentName(req.Instrument),
+		Granularity: req.Granularity,
+		Price:       req.Price,
+		From:        req.From.Add(-period),
+		To:          req.To,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return data.Between(bars, req.From, req.To), nil
+}
+
+// Instruments returns metadata for every instrument the account can trade
+func (s *Source) Instruments(ctx context.Context) ([]types.Instrument, error) {
+	instruments, err := s.service.FetchInstruments(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(instruments, func(i, j int) bool { return instruments[i].Name < instruments[j].Name })
+	return instruments, nil
+}
+
+func (s *Source) Instrument(ctx context.Context, name string) (types.Instrument, error) {
+	instruments, err := s.service.FetchInstruments(ctx, InstrumentName(name))
+	if err != nil {
+		return types.Instrument{}, err
+	}
+	for _, instrument := range instruments {
+		if instrument.Name == name {
+			return instrument, nil
+		}
+	}
+	return types.Instrument{}, fmt.Errorf("unknown instrument: %s", name)
+}
@@ -0,0 +1,204 @@
+package oanda
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/jwtly10/tradebook/internal/types"
+)
+
+// PriceStream streams prices for instruments from Oanda, reconnecting with backoff when the
+// connection drops or goes quiet
+type PriceStream struct {
+	service     *OandaService
+	Instruments []InstrumentName
+	// HeartbeatTimeout reconnects when nothing arrives for this long, Oanda sends a heartbeat every 5 seconds
+	HeartbeatTimeout time.Duration
+	// MinBackoff is the wait before the first reconnect, doubling on each failure up to MaxBackoff
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// streamMessage is a line of the stream, either a PRICE or a HEARTBEAT
+type streamMessage struct {
+	Type        string         `json:"type"`
+	Time        string         `json:"time"`
+	Instrument  InstrumentName `json:"instrument"`
+	Bids        []PriceBucket  `json:"bids"`
+	Asks        []PriceBucket  `json:"asks"`
+	CloseoutBid PriceValue     `json:"closeoutBid"`
+	CloseoutAsk PriceValue     `json:"closeoutAsk"`
+	Tradeable   bool           `json:"tradeable"`
+}
+
+// PriceBucket is a price available for up to Liquidity units
+type PriceBucket struct {
+	Price     PriceValue `json:"price"`
+	Liquidity int        `json:"liquidity"`
+}
+
+func (s *OandaService) NewPriceStream(instruments ...InstrumentName) *PriceStream {
+	return &PriceStream{
+		service:          s,
+		Instruments:      instruments,
+		HeartbeatTimeout: 20 * time.Second,
+		MinBackoff:       time.Second,
+		MaxBackoff:       time.Minute,
+	}
+}
+
+// Stream returns a channel of ticks, which is closed once ctx is cancelled.
+// Connection errors are logged and retried, so the channel only closes with ctx.
+func (p *PriceStream) Stream(ctx context.Context) <-chan types.Tick {
+	ticks := make(chan types.Tick)
+
+	go func() {
+		defer close(ticks)
+
+		backoff := p.MinBackoff
+		for {
+			received, err := p.connect(ctx, ticks)
+			if ctx.Err() != nil {
+				return
+			}
+			if received {
+				backoff = p.MinBackoff
+			}
+			slog.Warn("Price stream disconnected, reconnecting", "instruments", p.Instruments, "error", err, "backoff", backoff)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, p.MaxBackoff)
+		}
+	}()
+
+	return ticks
+}
+
+// connect streams prices until the connection fails, returning whether any message was received
+func (p *PriceStream) connect(ctx context.Context, ticks chan<- types.Tick) (bool, error) {
+	connCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	names := make([]string, len(p.Instruments))
+	for i, name := range p.Instruments {
+		names[i] = string(name)
+	}
+	params := url.Values{}
+	params.Add("instruments", strings.Join(names, ","))
+	fullURL := p.service.StreamUrl + p.service.accountPath("/pricing/stream") + "?" + params.Encode()
+
+	httpReq, err := http.NewRequestWithContext(connCtx, http.MethodGet, fullURL, nil)
+	if err != nil {
+		return false, err
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+p.service.ApiKey)
+	httpReq.Header.Set("Accept-Datetime-Format", "RFC3339")
+
+	slog.Info("Connecting to price stream", "instruments", p.Instruments)
+
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return false, decodeAPIError(resp.StatusCode, body)
+	}
+
+	// Dropping the connection unblocks the read below when the stream goes quiet
+	var quiet atomic.Bool
+	watchdog := time.AfterFunc(p.HeartbeatTimeout, func() {
+		quiet.Store(true)
+		cancel()
+	})
+	defer watchdog.Stop()
+
+	received := false
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		watchdog.Reset(p.HeartbeatTimeout)
+		received = true
+
+		var msg streamMessage
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			slog.Warn("Skipping undecodable price stream message", "message", scanner.Text(), "error", err)
+			continue
+		}
+		if msg.Type != "PRICE" {
+			continue
+		}
+
+		tick, err := msg.tick()
+		if err != nil {
+			slog.Warn("Skipping invalid price", "instrument", msg.Instrument, "error", err)
+			continue
+		}
+		// A slow consumer isn't a quiet stream
+		watchdog.Stop()
+		select {
+		case ticks <- tick:
+		case <-ctx.Done():
+			return received, ctx.Err()
+		}
+		watchdog.Reset(p.HeartbeatTimeout)
+	}
+
+	watchdog.Stop()
+	if quiet.Load() {
+		return received, fmt.Errorf("no heartbeat for %s", p.HeartbeatTimeout)
+	}
+	if err := scanner.Err(); err != nil {
+		return received, err
+	}
+	return received, errors.New("price stream closed by server")
+}
+
+// tick converts a price to a tick at the best bid and ask, falling back to the closeout prices when there's no depth
+func (m streamMessage) tick() (types.Tick, error) {
+	t, err := time.Parse(time.RFC3339, m.Time)
+	if err != nil {
+		return types.Tick{}, fmt.Errorf("failed to parse price time %s: %w", m.Time, err)
+	}
+
+	bid, ask := m.CloseoutBid, m.CloseoutAsk
+	if len(m.Bids) > 0 {
+		bid = m.Bids[0].Price
+	}
+	if len(m.Asks) > 0 {
+		ask = m.Asks[0].Price
+	}
+
+	bidPrice, err := strconv.ParseFloat(string(bid), 64)
+	if err != nil {
+		return types.Tick{}, fmt.Errorf("failed to parse bid %s: %w", bid, err)
+	}
+	askPrice, err := strconv.ParseFloat(string(ask), 64)
+	if err != nil {
+		return types.Tick{}, fmt.Errorf("failed to parse ask %s: %w", ask, err)
+	}
+
+	return types.Tick{
+		Instrument: string(m.Instrument),
+		Time:       t,
+		Bid:        bidPrice,
+		Ask:        askPrice,
+		Tradeable:  m.Tradeable,
+	}, nil
+}
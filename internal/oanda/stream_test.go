@@ -0,0 +1,135 @@
+package oanda
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jwtly10/tradebook/internal/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestStream returns a stream of GBP_USD prices from a stand-in server, serving each connection with
+// the handler for its attempt number, the last handler serving every attempt after it
+func newTestStream(t *testing.T, handlers ...func(w http.ResponseWriter, r *http.Request)) (*PriceStream, *atomic.Int32) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v3/accounts/101-001/pricing/stream", r.URL.Path)
+		assert.Equal(t, "GBP_USD", r.URL.Query().Get("instruments"))
+		n := int(attempts.Add(1))
+		handlers[min(n, len(handlers))-1](w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	stream := NewOandaService("101-001", "key", server.URL).NewPriceStream(GBPUSD)
+	stream.MinBackoff = time.Millisecond
+	stream.MaxBackoff = 10 * time.Millisecond
+	return stream, &attempts
+}
+
+func writeLine(w http.ResponseWriter, line string) {
+	fmt.Fprintln(w, line)
+	w.(http.Flusher).Flush()
+}
+
+func price(bid, ask string) string {
+	// Messages are newline delimited, so each must stay on one line
+	return `{"type": "PRICE", "time": "2025-01-08T10:15:00.123456789Z", "instrument": "GBP_USD", "tradeable": true, ` +
+		`"bids": [{"price": "` + bid + `", "liquidity": 1000000}], "asks": [{"price": "` + ask + `", "liquidity": 1000000}]}`
+}
+
+// receive returns the next tick, failing the test if none arrives in time
+func receive(t *testing.T, ticks <-chan types.Tick) types.Tick {
+	select {
+	case tick, ok := <-ticks:
+		assert.True(t, ok, "Stream closed early")
+		return tick
+	case <-time.After(2 * time.Second):
+		t.Fatal("No tick received")
+		return types.Tick{}
+	}
+}
+
+func TestPriceStream_ReconnectsWhenTheServerCloses(t *testing.T) {
+	stream, attempts := newTestStream(t,
+		func(w http.ResponseWriter, r *http.Request) {
+			writeLine(w, `{"type": "HEARTBEAT", "time": "2025-01-08T10:14:55Z"}`)
+			writeLine(w, price("1.25000", "1.25020"))
+		},
+		func(w http.ResponseWriter, r *http.Request) {
+			// No depth, so the closeout prices are used
+			writeLine(w, `{"type": "PRICE", "time": "2025-01-08T10:15:01Z", "instrument": "GBP_USD", "closeoutBid": "1.25010", "closeoutAsk": "1.25030"}`)
+			<-r.Context().Done()
+		},
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ticks := stream.Stream(ctx)
+
+	tick := receive(t, ticks)
+	assert.Equal(t, "GBP_USD", tick.Instrument)
+	assert.Equal(t, 1.25, tick.Bid)
+	assert.Equal(t, 1.2502, tick.Ask)
+	assert.True(t, tick.Tradeable)
+	assert.Equal(t, time.Date(2025, 1, 8, 10, 15, 0, 123456789, time.UTC), tick.Time)
+
+	tick = receive(t, ticks)
+	assert.Equal(t, 1.2501, tick.Bid)
+	assert.Equal(t, 1.2503, tick.Ask)
+	assert.Equal(t, int32(2), attempts.Load())
+
+	cancel()
+	for range ticks {
+	}
+}
+
+func TestPriceStream_ReconnectsWhenHeartbeatsStop(t *testing.T) {
+	stream, attempts := newTestStream(t,
+		func(w http.ResponseWriter, r *http.Request) {
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		},
+		func(w http.ResponseWriter, r *http.Request) {
+			writeLine(w, price("1.25000", "1.25020"))
+			<-r.Context().Done()
+		},
+	)
+	stream.HeartbeatTimeout = 50 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	receive(t, stream.Stream(ctx))
+	assert.Equal(t, int32(2), attempts.Load())
+}
+
+func TestPriceStream_RetriesErrorResponses(t *testing.T) {
+	stream, attempts := newTestStream(t,
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		},
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		},
+		func(w http.ResponseWriter, r *http.Request) {
+			writeLine(w, price("1.25000", "1.25020"))
+			<-r.Context().Done()
+		},
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	receive(t, stream.Stream(ctx))
+	assert.Equal(t, int32(3), attempts.Load())
+}
+
+func TestNewOandaService_PairsStreamHost(t *testing.T) {
+	assert.Equal(t, DefaultStreamUrl, NewOandaService("", "", "").StreamUrl)
+	assert.Equal(t, "https://stream-fxtrade.oanda.com", NewOandaService("", "", "https://api-fxtrade.oanda.com").StreamUrl)
+	assert.Equal(t, "http://127.0.0.1:8080", NewOandaService("", "", "http://127.0.0.1:8080").StreamUrl)
+}
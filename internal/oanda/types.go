@@ -1,6 +1,11 @@
 package oanda
 
-import "time"
+import (
+	"strconv"
+	"time"
+
+	"github.com/jwtly10/tradebook/internal/types"
+)
 
 // https://developer.oanda.com/rest-live-v20/pricing-ep/
 
@@ -23,7 +28,8 @@ type CandleStickData struct {
 type PriceValue string
 type InstrumentName string
 
-type CandlestickGranularity string
+type PriceComponent = types.PriceComponent
+type CandlestickGranularity = types.Granularity
 
 type CandlestickResponse struct {
 	Candles     []Candlestick          `json:"candles"`
@@ -35,12 +41,334 @@ type OandaService struct {
 	AccountId string
 	ApiKey    string
 	ApiUrl    string
+	StreamUrl string // Host for streaming endpoints, which Oanda serves separately from the REST API
 }
 
 type CandleRequest struct {
 	Instrument  InstrumentName         `json:"instrument"`
 	Granularity CandlestickGranularity `json:"granularity,omitempty"` // Default S5
+	Price       PriceComponent         `json:"price,omitempty"`       // Default M
 	Count       int                    `json:"count,omitempty"`       // Default 500, max 5000
 	From        time.Time              `json:"from"`                  // RFC 3339
 	To          time.Time              `json:"to"`                    // RFC 3339
 }
+
+// https://developer.oanda.com/rest-live-v20/account-ep/
+
+type InstrumentsResponse struct {
+	Instruments       []Instrument `json:"instruments"`
+	LastTransactionID string       `json:"lastTransactionID"`
+}
+
+type Instrument struct {
+	Name                        InstrumentName      `json:"name"`
+	Type                        string              `json:"type"`
+	DisplayName                 string              `json:"displayName"`
+	PipLocation                 int                 `json:"pipLocation"`
+	DisplayPrecision            int                 `json:"displayPrecision"`
+	TradeUnitsPrecision         int                 `json:"tradeUnitsPrecision"`
+	MinimumTradeSize            DecimalNumber       `json:"minimumTradeSize"`
+	MaximumTrailingStopDistance DecimalNumber       `json:"maximumTrailingStopDistance"`
+	MinimumTrailingStopDistance DecimalNumber       `json:"minimumTrailingStopDistance"`
+	MaximumPositionSize         DecimalNumber       `json:"maximumPositionSize"`
+	MaximumOrderUnits           DecimalNumber       `json:"maximumOrderUnits"`
+	MarginRate                  DecimalNumber       `json:"marginRate"`
+	Financing                   InstrumentFinancing `json:"financing"`
+}
+
+type InstrumentFinancing struct {
+	LongRate   DecimalNumber                  `json:"longRate"`
+	ShortRate  DecimalNumber                  `json:"shortRate"`
+	DaysOfWeek []InstrumentFinancingDayOfWeek `json:"financingDaysOfWeek"`
+}
+
+// InstrumentFinancingDayOfWeek is how many days of financing are charged at a weekday's rollover
+type InstrumentFinancingDayOfWeek struct {
+	DayOfWeek   string `json:"dayOfWeek"`
+	DaysCharged int    `json:"daysCharged"`
+}
+
+// DecimalNumber is a decimal value, which the v20 API sends as a string to avoid float error
+type DecimalNumber string
+
+// Float64 parses the number, an empty value is zero
+func (d DecimalNumber) Float64() (float64, error) {
+	if d == "" {
+		return 0, nil
+	}
+	return strconv.ParseFloat(string(d), 64)
+}
+
+type AccountID string
+type TransactionID string
+type TradeID string
+type OrderID string
+
+// AccountSummary is an account without its trades, positions and orders
+type AccountSummary struct {
+	ID                         AccountID     `json:"id"`
+	Alias                      string        `json:"alias"`
+	Currency                   string        `json:"currency"`
+	Balance                    DecimalNumber `json:"balance"`
+	CreatedTime                string        `json:"createdTime"`
+	PL                         DecimalNumber `json:"pl"`
+	ResettablePL               DecimalNumber `json:"resettablePL"`
+	Financing                  DecimalNumber `json:"financing"`
+	Commission                 DecimalNumber `json:"commission"`
+	MarginRate                 DecimalNumber `json:"marginRate"`
+	OpenTradeCount             int           `json:"openTradeCount"`
+	OpenPositionCount          int           `json:"openPositionCount"`
+	PendingOrderCount          int           `json:"pendingOrderCount"`
+	HedgingEnabled             bool          `json:"hedgingEnabled"`
+	UnrealizedPL               DecimalNumber `json:"unrealizedPL"`
+	NAV                        DecimalNumber `json:"NAV"`
+	MarginUsed                 DecimalNumber `json:"marginUsed"`
+	MarginAvailable            DecimalNumber `json:"marginAvailable"`
+	PositionValue              DecimalNumber `json:"positionValue"`
+	MarginCloseoutUnrealizedPL DecimalNumber `json:"marginCloseoutUnrealizedPL"`
+	MarginCloseoutNAV          DecimalNumber `json:"marginCloseoutNAV"`
+	MarginCloseoutMarginUsed   DecimalNumber `json:"marginCloseoutMarginUsed"`
+	MarginCloseoutPercent      DecimalNumber `json:"marginCloseoutPercent"`
+	WithdrawalLimit            DecimalNumber `json:"withdrawalLimit"`
+	LastTransactionID          TransactionID `json:"lastTransactionID"`
+}
+
+type Account struct {
+	AccountSummary
+	Trades    []TradeSummary `json:"trades"`
+	Positions []Position     `json:"positions"`
+	Orders    []Order        `json:"orders"`
+}
+
+type AccountResponse struct {
+	Account           Account       `json:"account"`
+	LastTransactionID TransactionID `json:"lastTransactionID"`
+}
+
+type AccountSummaryResponse struct {
+	Account           AccountSummary `json:"account"`
+	LastTransactionID TransactionID  `json:"lastTransactionID"`
+}
+
+type TradeSummary struct {
+	ID                      TradeID        `json:"id"`
+	Instrument              InstrumentName `json:"instrument"`
+	Price                   PriceValue     `json:"price"`
+	OpenTime                string         `json:"openTime"`
+	State                   string         `json:"state"` // OPEN, CLOSED, CLOSE_WHEN_TRADEABLE
+	InitialUnits            DecimalNumber  `json:"initialUnits"`
+	CurrentUnits            DecimalNumber  `json:"currentUnits"`
+	RealizedPL              DecimalNumber  `json:"realizedPL"`
+	UnrealizedPL            DecimalNumber  `json:"unrealizedPL"`
+	MarginUsed              DecimalNumber  `json:"marginUsed"`
+	Financing               DecimalNumber  `json:"financing"`
+	CloseTime               string         `json:"closeTime,omitempty"`
+	TakeProfitOrderID       OrderID        `json:"takeProfitOrderID,omitempty"`
+	StopLossOrderID         OrderID        `json:"stopLossOrderID,omitempty"`
+	TrailingStopLossOrderID OrderID        `json:"trailingStopLossOrderID,omitempty"`
+}
+
+type Position struct {
+	Instrument   InstrumentName `json:"instrument"`
+	PL           DecimalNumber  `json:"pl"`
+	UnrealizedPL DecimalNumber  `json:"unrealizedPL"`
+	MarginUsed   DecimalNumber  `json:"marginUsed"`
+	Financing    DecimalNumber  `json:"financing"`
+	Long         PositionSide   `json:"long"`
+	Short        PositionSide   `json:"short"`
+}
+
+type PositionSide struct {
+	Units        DecimalNumber `json:"units"`
+	AveragePrice PriceValue    `json:"averagePrice,omitempty"`
+	TradeIDs     []TradeID     `json:"tradeIDs,omitempty"`
+	PL           DecimalNumber `json:"pl"`
+	UnrealizedPL DecimalNumber `json:"unrealizedPL"`
+}
+
+// Order holds the fields common to every order type
+type Order struct {
+	ID                   OrderID        `json:"id"`
+	CreateTime           string         `json:"createTime"`
+	State                string         `json:"state"` // PENDING, FILLED, TRIGGERED, CANCELLED
+	Type                 string         `json:"type"`
+	Instrument           InstrumentName `json:"instrument,omitempty"`
+	Units                DecimalNumber  `json:"units,omitempty"`
+	Price                PriceValue     `json:"price,omitempty"`
+	Distance             DecimalNumber  `json:"distance,omitempty"`
+	TradeID              TradeID        `json:"tradeID,omitempty"`
+	TimeInForce          string         `json:"timeInForce,omitempty"`
+	GtdTime              string         `json:"gtdTime,omitempty"`
+	FilledTime           string         `json:"filledTime,omitempty"`
+	CancelledTime        string         `json:"cancelledTime,omitempty"`
+	FillingTransactionID TransactionID  `json:"fillingTransactionID,omitempty"`
+}
+
+// Transaction holds the fields common to the transaction types we act on
+type Transaction struct {
+	ID           TransactionID  `json:"id"`
+	Time         string         `json:"time"`
+	Type         string         `json:"type"`
+	Instrument   InstrumentName `json:"instrument,omitempty"`
+	Units        DecimalNumber  `json:"units,omitempty"`
+	Price        PriceValue     `json:"price,omitempty"`
+	PL           DecimalNumber  `json:"pl,omitempty"`
+	Financing    DecimalNumber  `json:"financing,omitempty"`
+	Reason       string         `json:"reason,omitempty"`
+	RejectReason string         `json:"rejectReason,omitempty"`
+	OrderID      OrderID        `json:"orderID,omitempty"`
+	TradeID      TradeID        `json:"tradeID,omitempty"`
+	// Set on ORDER_FILL transactions
+	TradeOpened  *TradeOpen    `json:"tradeOpened,omitempty"`
+	TradeReduced *TradeReduce  `json:"tradeReduced,omitempty"`
+	TradesClosed []TradeReduce `json:"tradesClosed,omitempty"`
+}
+
+// TradeOpen is a trade opened by an order fill
+type TradeOpen struct {
+	TradeID TradeID       `json:"tradeID"`
+	Units   DecimalNumber `json:"units"`
+	Price   PriceValue    `json:"price"`
+}
+
+// TradeReduce is a trade reduced or closed by an order fill
+type TradeReduce struct {
+	TradeID    TradeID       `json:"tradeID"`
+	Units      DecimalNumber `json:"units"`
+	Price      PriceValue    `json:"price"`
+	RealizedPL DecimalNumber `json:"realizedPL"`
+	Financing  DecimalNumber `json:"financing"`
+}
+
+type AccountChangesResponse struct {
+	Changes           AccountChanges      `json:"changes"`
+	State             AccountChangesState `json:"state"`
+	LastTransactionID TransactionID       `json:"lastTransactionID"`
+}
+
+// AccountChanges are what happened to an account since a transaction
+type AccountChanges struct {
+	OrdersCreated   []Order        `json:"ordersCreated"`
+	OrdersCancelled []Order        `json:"ordersCancelled"`
+	OrdersFilled    []Order        `json:"ordersFilled"`
+	OrdersTriggered []Order        `json:"ordersTriggered"`
+	TradesOpened    []TradeSummary `json:"tradesOpened"`
+	TradesReduced   []TradeSummary `json:"tradesReduced"`
+	TradesClosed    []TradeSummary `json:"tradesClosed"`
+	Positions       []Position     `json:"positions"`
+	Transactions    []Transaction  `json:"transactions"`
+}
+
+// AccountChangesState is the account's price dependent state at the time of the request
+type AccountChangesState struct {
+	UnrealizedPL          DecimalNumber             `json:"unrealizedPL"`
+	NAV                   DecimalNumber             `json:"NAV"`
+	MarginUsed            DecimalNumber             `json:"marginUsed"`
+	MarginAvailable       DecimalNumber             `json:"marginAvailable"`
+	PositionValue         DecimalNumber             `json:"positionValue"`
+	MarginCloseoutPercent DecimalNumber             `json:"marginCloseoutPercent"`
+	WithdrawalLimit       DecimalNumber             `json:"withdrawalLimit"`
+	Trades                []CalculatedTradeState    `json:"trades"`
+	Positions             []CalculatedPositionState `json:"positions"`
+}
+
+type CalculatedTradeState struct {
+	ID           TradeID       `json:"id"`
+	UnrealizedPL DecimalNumber `json:"unrealizedPL"`
+	MarginUsed   DecimalNumber `json:"marginUsed"`
+}
+
+type CalculatedPositionState struct {
+	Instrument        InstrumentName `json:"instrument"`
+	NetUnrealizedPL   DecimalNumber  `json:"netUnrealizedPL"`
+	LongUnrealizedPL  DecimalNumber  `json:"longUnrealizedPL"`
+	ShortUnrealizedPL DecimalNumber  `json:"shortUnrealizedPL"`
+	MarginUsed        DecimalNumber  `json:"marginUsed"`
+}
+
+// https://developer.oanda.com/rest-live-v20/order-ep/
+
+const (
+	ORDER_MARKET = "MARKET"
+	ORDER_LIMIT  = "LIMIT"
+	ORDER_STOP   = "STOP"
+
+	// Time in force
+	FOK = "FOK" // Fill or kill, the only option for market orders
+	GTC = "GTC" // Good till cancelled
+	GTD = "GTD" // Good till GtdTime
+)
+
+// OrderRequest creates a market, limit or stop order, with optional take profit, stop loss and trailing stop orders on the trade it opens
+type OrderRequest struct {
+	Type                   string                   `json:"type"`
+	Instrument             InstrumentName           `json:"instrument"`
+	Units                  DecimalNumber            `json:"units"` // Negative to sell
+	Price                  PriceValue               `json:"price,omitempty"`
+	PriceBound             PriceValue               `json:"priceBound,omitempty"` // Worst price a triggered stop order fills at
+	TimeInForce            string                   `json:"timeInForce,omitempty"`
+	GtdTime                string                   `json:"gtdTime,omitempty"`
+	PositionFill           string                   `json:"positionFill,omitempty"`
+	TakeProfitOnFill       *TakeProfitDetails       `json:"takeProfitOnFill,omitempty"`
+	StopLossOnFill         *StopLossDetails         `json:"stopLossOnFill,omitempty"`
+	TrailingStopLossOnFill *TrailingStopLossDetails `json:"trailingStopLossOnFill,omitempty"`
+}
+
+type TakeProfitDetails struct {
+	Price    PriceValue    `json:"price,omitempty"`
+	Distance DecimalNumber `json:"distance,omitempty"`
+}
+
+type StopLossDetails struct {
+	Price    PriceValue    `json:"price,omitempty"`
+	Distance DecimalNumber `json:"distance,omitempty"`
+}
+
+type TrailingStopLossDetails struct {
+	Distance DecimalNumber `json:"distance"`
+}
+
+type createOrderBody struct {
+	Order OrderRequest `json:"order"`
+}
+
+type OrderCreateResponse struct {
+	OrderCreateTransaction Transaction     `json:"orderCreateTransaction"`
+	OrderFillTransaction   *Transaction    `json:"orderFillTransaction,omitempty"`
+	OrderCancelTransaction *Transaction    `json:"orderCancelTransaction,omitempty"`
+	RelatedTransactionIDs  []TransactionID `json:"relatedTransactionIDs"`
+	LastTransactionID      TransactionID   `json:"lastTransactionID"`
+}
+
+// https://developer.oanda.com/rest-live-v20/trade-ep/
+
+// TradeCloseRequest closes some or all of a trade, Units is "ALL" or a positive number of units
+type TradeCloseRequest struct {
+	Units string `json:"units"`
+}
+
+type TradeCloseResponse struct {
+	OrderCreateTransaction Transaction     `json:"orderCreateTransaction"`
+	OrderFillTransaction   *Transaction    `json:"orderFillTransaction,omitempty"`
+	OrderCancelTransaction *Transaction    `json:"orderCancelTransaction,omitempty"`
+	RelatedTransactionIDs  []TransactionID `json:"relatedTransactionIDs"`
+	LastTransactionID      TransactionID   `json:"lastTransactionID"`
+}
+
+// TradeOrdersRequest replaces the take profit, stop loss and trailing stop of a trade, nil leaves them unchanged
+type TradeOrdersRequest struct {
+	TakeProfit       *TakeProfitDetails       `json:"takeProfit,omitempty"`
+	StopLoss         *StopLossDetails         `json:"stopLoss,omitempty"`
+	TrailingStopLoss *TrailingStopLossDetails `json:"trailingStopLoss,omitempty"`
+}
+
+type TradeOrdersResponse struct {
+	TakeProfitOrderCancelTransaction       *Transaction    `json:"takeProfitOrderCancelTransaction,omitempty"`
+	TakeProfitOrderTransaction             *Transaction    `json:"takeProfitOrderTransaction,omitempty"`
+	StopLossOrderCancelTransaction         *Transaction    `json:"stopLossOrderCancelTransaction,omitempty"`
+	StopLossOrderTransaction               *Transaction    `json:"stopLossOrderTransaction,omitempty"`
+	TrailingStopLossOrderCancelTransaction *Transaction    `json:"trailingStopLossOrderCancelTransaction,omitempty"`
+	TrailingStopLossOrderTransaction       *Transaction    `json:"trailingStopLossOrderTransaction,omitempty"`
+	RelatedTransactionIDs                  []TransactionID `json:"relatedTransactionIDs"`
+	LastTransactionID                      TransactionID   `json:"lastTransactionID"`
+}
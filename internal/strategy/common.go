@@ -1,10 +1,12 @@
 package strategy
 
 import (
+	"fmt"
 	"log/slog"
+	"time"
 
 	"github.com/jwtly10/tradebook/internal/account"
-	"github.com/jwtly10/tradebook/internal/oanda"
+	"github.com/jwtly10/tradebook/internal/instrument"
 	"github.com/jwtly10/tradebook/internal/types"
 )
 
@@ -30,6 +32,18 @@ type Strategy interface {
 	GetPeriod() string
 }
 
+// Timeframes holds, per granularity, the higher timeframe bars that had fully closed by the end of the current bar
+type Timeframes map[types.Granularity][]types.Bar
+
+// MultiTimeframeStrategy is a Strategy that also wants bars from other timeframes.
+// The engine calls OnBarWithTimeframes instead of OnBar.
+type MultiTimeframeStrategy interface {
+	Strategy
+
+	Timeframes() []types.Granularity
+	OnBarWithTimeframes(bars []types.Bar, currentIndex int, timeframes Timeframes, account *account.Account) []types.Signal
+}
+
 func NewBaseStrategy(symbol, period string, riskPercentage, riskRatio, balanceToRisk float64, stopLossPips int) *Base {
 	return &Base{
 		symbol,
@@ -59,15 +73,9 @@ func IndicatorsReady(indicators ...Indicator) bool {
 	return true
 }
 
-// GetPipsFromInstr returns the pip size for a given instrument
-func GetPipsFromInstr(ins string) float64 {
-	// TODO: This method will support all broker/data sources
-	// for now just NAS100
-	if ins == string(oanda.NAS100) {
-		return 0.1
-	} else {
-		panic("GetPipsFromInstr: Unsupported instrument " + ins)
-	}
+// getInstrument returns the registry's metadata for the strategy's instrument, erroring if the registry doesn't know it
+func getInstrument(s Strategy) (types.Instrument, error) {
+	return instrument.Lookup(s.GetSymbol())
 }
 
 // pipsToPrice converts pips to price units based on the symbol's pip size
@@ -75,15 +83,99 @@ func PipsToPrice(pips int, pipSize float64) float64 {
 	return float64(pips) * pipSize
 }
 
-// OpenLong creates a long trade signal based on the strategy configuration and current bar
-func OpenLong(s Strategy, bar types.Bar, acc *account.Account) types.Signal {
+// SignalOption adds stop management to a signal created by OpenLong or OpenShort
+type SignalOption func(s Strategy, signal *types.Signal) error
+
+// WithTrailingStopPips trails the stop a fixed number of pips behind the best price
+func WithTrailingStopPips(pips int) SignalOption {
+	return func(s Strategy, signal *types.Signal) error {
+		instrument, err := getInstrument(s)
+		if err != nil {
+			return err
+		}
+		signal.Trailing = &types.TrailingStop{
+			Mode:     types.TRAIL_FIXED,
+			Distance: PipsToPrice(pips, instrument.PipSize()),
+		}
+		return nil
+	}
+}
+
+// WithATRTrailingStop trails the stop a multiple of the ATR behind the best price, the ATR keeps updating while the position is open
+func WithATRTrailingStop(multiplier float64, atr *ATR) SignalOption {
+	return func(s Strategy, signal *types.Signal) error {
+		signal.Trailing = &types.TrailingStop{
+			Mode:      types.TRAIL_ATR,
+			Distance:  multiplier,
+			ATR:       atr.Value(),
+			ATRPeriod: atr.period,
+		}
+		return nil
+	}
+}
+
+// WithPercentTrailingStop trails the stop a percentage of price behind the best price
+func WithPercentTrailingStop(percent float64) SignalOption {
+	return func(s Strategy, signal *types.Signal) error {
+		signal.Trailing = &types.TrailingStop{
+			Mode:     types.TRAIL_PERCENT,
+			Distance: percent,
+		}
+		return nil
+	}
+}
+
+// WithBreakEvenPips moves the stop to entry plus offsetPips once the position is triggerPips in profit
+func WithBreakEvenPips(triggerPips, offsetPips int) SignalOption {
+	return func(s Strategy, signal *types.Signal) error {
+		instrument, err := getInstrument(s)
+		if err != nil {
+			return err
+		}
+		signal.BreakEven = &types.BreakEven{
+			Trigger: PipsToPrice(triggerPips, instrument.PipSize()),
+			Offset:  PipsToPrice(offsetPips, instrument.PipSize()),
+		}
+		return nil
+	}
+}
+
+// WithBreakEvenR moves the stop to entry once the position is r times its initial risk in profit
+func WithBreakEvenR(r float64) SignalOption {
+	return func(s Strategy, signal *types.Signal) error {
+		signal.BreakEven = &types.BreakEven{TriggerR: r}
+		return nil
+	}
+}
+
+func applyOptions(s Strategy, signal types.Signal, opts []SignalOption) (types.Signal, error) {
+	for _, opt := range opts {
+		if err := opt(s, &signal); err != nil {
+			return types.Signal{}, err
+		}
+	}
+	return signal, nil
+}
+
+// OpenLong creates a long trade signal based on the strategy configuration and current bar.
+// Returns an error if the instrument registry doesn't know the strategy's instrument, or the account can't
+// convert into its quote currency, so the signal can be skipped.
+func OpenLong(s Strategy, bar types.Bar, acc *account.Account, opts ...SignalOption) (types.Signal, error) {
+	instrument, err := getInstrument(s)
+	if err != nil {
+		return types.Signal{}, err
+	}
 	entryPrice := bar.Close
-	stopLoss := entryPrice - PipsToPrice(s.GetStopLossPips(), GetPipsFromInstr(s.GetSymbol()))
-	takeProfit := entryPrice + PipsToPrice(s.GetStopLossPips(), GetPipsFromInstr(s.GetSymbol()))*s.GetRiskRatio()
+	// Levels are rounded to the instrument's tick, as the broker would reject anything finer
+	stopLoss := instrument.RoundPrice(entryPrice - PipsToPrice(s.GetStopLossPips(), instrument.PipSize()))
+	takeProfit := instrument.RoundPrice(entryPrice + PipsToPrice(s.GetStopLossPips(), instrument.PipSize())*s.GetRiskRatio())
 
-	size := calculatePositionSize(s, acc, entryPrice, stopLoss)
+	size, err := calculatePositionSize(s, instrument, acc, bar.Timestamp, entryPrice, stopLoss)
+	if err != nil {
+		return types.Signal{}, err
+	}
 
-	return types.Signal{
+	signal := types.Signal{
 		Type:   types.OPEN,
 		Action: types.BUY,
 		Price:  entryPrice,
@@ -91,17 +183,28 @@ func OpenLong(s Strategy, bar types.Bar, acc *account.Account) types.Signal {
 		TP:     takeProfit,
 		Size:   size,
 	}
+	return applyOptions(s, signal, opts)
 }
 
-// OpenShort creates a short trade signal based on the strategy configuration and current bar
-func OpenShort(s Strategy, bar types.Bar, acc *account.Account) types.Signal {
+// OpenShort creates a short trade signal based on the strategy configuration and current bar.
+// Returns an error if the instrument registry doesn't know the strategy's instrument, or the account can't
+// convert into its quote currency, so the signal can be skipped.
+func OpenShort(s Strategy, bar types.Bar, acc *account.Account, opts ...SignalOption) (types.Signal, error) {
+	instrument, err := getInstrument(s)
+	if err != nil {
+		return types.Signal{}, err
+	}
 	entryPrice := bar.Close
-	stopLoss := entryPrice + PipsToPrice(s.GetStopLossPips(), GetPipsFromInstr(s.GetSymbol()))
-	takeProfit := entryPrice - PipsToPrice(s.GetStopLossPips(), GetPipsFromInstr(s.GetSymbol()))*s.GetRiskRatio()
+	// Levels are rounded to the instrument's tick, as the broker would reject anything finer
+	stopLoss := instrument.RoundPrice(entryPrice + PipsToPrice(s.GetStopLossPips(), instrument.PipSize()))
+	takeProfit := instrument.RoundPrice(entryPrice - PipsToPrice(s.GetStopLossPips(), instrument.PipSize())*s.GetRiskRatio())
 
-	size := calculatePositionSize(s, acc, entryPrice, stopLoss)
+	size, err := calculatePositionSize(s, instrument, acc, bar.Timestamp, entryPrice, stopLoss)
+	if err != nil {
+		return types.Signal{}, err
+	}
 
-	return types.Signal{
+	signal := types.Signal{
 		Type:   types.OPEN,
 		Action: types.SELL,
 		Price:  entryPrice,
@@ -109,11 +212,51 @@ func OpenShort(s Strategy, bar types.Bar, acc *account.Account) types.Signal {
 		TP:     takeProfit,
 		Size:   size,
 	}
+	return applyOptions(s, signal, opts)
+}
+
+// ClosePosition creates a signal closing the position with the given ID on the next bar
+func ClosePosition(id int, bar types.Bar) types.Signal {
+	return types.Signal{
+		Type:       types.CLOSE,
+		PositionID: id,
+		Price:      bar.Close,
+	}
+}
+
+// ClosePartial creates a signal closing a fraction of the position with the given ID on the next bar
+func ClosePartial(id int, fraction float64, bar types.Bar) types.Signal {
+	signal := ClosePosition(id, bar)
+	signal.Fraction = fraction
+	return signal
+}
+
+// ClosePositions creates a signal closing every position opened with the given action, or all positions if it's empty
+func ClosePositions(action types.Action, bar types.Bar) types.Signal {
+	return types.Signal{
+		Type:   types.CLOSE,
+		Action: action,
+		Price:  bar.Close,
+	}
+}
+
+// ModifyPosition creates a signal moving the stop loss and take profit of the position with the given ID.
+// Pass zero to leave a level unchanged.
+func ModifyPosition(id int, stopLoss, takeProfit float64) types.Signal {
+	return types.Signal{
+		Type:       types.MODIFY,
+		PositionID: id,
+		SL:         stopLoss,
+		TP:         takeProfit,
+	}
 }
 
 // calculatePositionSize calculates the position size based on risk management parameters
-// based on the strategy and account state
-func calculatePositionSize(s Strategy, acc *account.Account, entryPrice, stopLoss float64) float64 {
+// based on the strategy and account state. The amount at risk is in the account currency,
+// so it's converted into the instrument's quote currency before dividing by the stop distance.
+// Sizes below the instrument's minimum are returned as is, and rejected by the account.
+// Returns an error if the account can't convert its currency into the instrument's quote currency.
+func calculatePositionSize(s Strategy, instrument types.Instrument, acc *account.Account, timestamp time.Time, entryPrice, stopLoss float64) (float64, error) {
 	// Using static balance if available
 	// (So ever trade has the same risk - it doesn't scale based on balance)
 	balanceToUse := s.GetBalanceToRisk()
@@ -122,9 +265,15 @@ func calculatePositionSize(s Strategy, acc *account.Account, entryPrice, stopLos
 	}
 
 	riskAmount := balanceToUse * (s.GetRiskPercentage() / 100)
+	quoteRate, err := acc.QuoteRate(s.GetSymbol(), timestamp)
+	if err != nil {
+		return 0, fmt.Errorf("failed to size position: %w", err)
+	}
+	quoteRiskAmount := riskAmount * quoteRate
 	stopDistance := Abs(entryPrice - stopLoss)
-	size := riskAmount / stopDistance
-	slog.Debug("Calculated position size", "size", size, "riskAmount", riskAmount, "entryPrice", entryPrice, "stopLoss", stopLoss, "stopDistance", stopDistance)
+	// Rounded down to tradeable units so we never risk more than asked
+	size := instrument.RoundUnits(quoteRiskAmount / stopDistance)
+	slog.Debug("Calculated position size", "size", size, "riskAmount", riskAmount, "quoteRiskAmount", quoteRiskAmount, "entryPrice", entryPrice, "stopLoss", stopLoss, "stopDistance", stopDistance)
 
-	return size
+	return size, nil
 }
@@ -0,0 +1,70 @@
+package strategy
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jwtly10/tradebook/internal/account"
+	"github.com/jwtly10/tradebook/internal/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// testStrategy risks 1% of the account at a 1:2 risk ratio
+type testStrategy struct {
+	symbol       string
+	stopLossPips int
+}
+
+func (s testStrategy) OnBar(bars []types.Bar, currentIndex int, acc *account.Account) []types.Signal {
+	return nil
+}
+
+func (s testStrategy) GetRiskPercentage() float64 { return 1 }
+func (s testStrategy) GetRiskRatio() float64      { return 2 }
+func (s testStrategy) GetBalanceToRisk() float64  { return 0 }
+func (s testStrategy) GetStopLossPips() int       { return s.stopLossPips }
+func (s testStrategy) GetSymbol() string          { return s.symbol }
+func (s testStrategy) GetPeriod() string          { return "M15" }
+
+// usdRates converts USD into GBP at 0.8, and has no other rates
+type usdRates struct{}
+
+func (usdRates) Rate(from, to string, at time.Time) (float64, error) {
+	if from == "USD" && to == "GBP" {
+		return 0.8, nil
+	}
+	return 0, errors.New("no rate")
+}
+
+func TestOpenLong_SizesInTheQuoteCurrency(t *testing.T) {
+	// NAS100_USD pips are whole points
+	s := testStrategy{symbol: "NAS100_USD", stopLossPips: 20}
+	bar := types.Bar{Timestamp: time.Date(2024, 1, 8, 10, 0, 0, 0, time.UTC), Close: 20000}
+
+	acc := account.NewAccount(10000)
+	signal, err := OpenLong(s, bar, acc)
+	assert.NoError(t, err)
+	assert.Equal(t, 19980.0, signal.SL)
+	assert.Equal(t, 20040.0, signal.TP)
+	assert.Equal(t, 5.0, signal.Size, "$100 at risk over 20 points")
+
+	// £100 at risk is $125, 6.25 units rounds down to the 0.1 units NAS100_USD trades in
+	acc.Conversion = &account.Conversion{Home: "GBP", QuoteCurrencies: map[string]string{"NAS100_USD": "USD"}, Rates: usdRates{}}
+	signal, err = OpenShort(s, bar, acc)
+	assert.NoError(t, err)
+	assert.Equal(t, 20020.0, signal.SL)
+	assert.Equal(t, 6.2, signal.Size)
+
+	// Without a rate there's no way to size the position
+	acc.Conversion.Home = "EUR"
+	_, err = OpenLong(s, bar, acc)
+	assert.ErrorContains(t, err, "failed to size position")
+}
+
+func TestOpenLong_UnknownInstrument(t *testing.T) {
+	s := testStrategy{symbol: "UNKNOWN", stopLossPips: 20}
+
+	_, err := OpenLong(s, types.Bar{Close: 100}, account.NewAccount(10000))
+	assert.ErrorContains(t, err, "unknown instrument: UNKNOWN")
+}
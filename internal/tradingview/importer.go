@@ -0,0 +1,155 @@
+package tradingview
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jwtly10/tradebook/internal/data"
+	"github.com/jwtly10/tradebook/internal/types"
+)
+
+// ChartExport is a TradingView "Export chart data" file, with any indicator plots kept alongside the bars
+type ChartExport struct {
+	Bars []types.Bar
+	// Indicators holds each non OHLCV column by header name, aligned by index with Bars.
+	// Missing values are NaN. Duplicate header names are suffixed with " (2)", " (3)" etc.
+	Indicators map[string][]float64
+	// Columns lists the indicator names in file order
+	Columns []string
+}
+
+// Mismatch is a bar where a calculated indicator value differs from the chart
+type Mismatch struct {
+	Index     int
+	Timestamp time.Time
+	Chart     float64
+	Value     float64
+}
+
+// ImportChartCSV reads a TradingView chart export. Times may be exported as unix seconds or ISO 8601.
+func ImportChartCSV(r io.Reader) (*ChartExport, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := csv.NewReader(bytes.NewReader(raw)).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chart export: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("chart export is empty")
+	}
+
+	header := records[0]
+	columns := data.ColumnsFromHeader(header)
+
+	layout := time.RFC3339
+	if len(records) > 1 && columns.Timestamp > 0 {
+		if _, err := strconv.ParseInt(records[1][columns.Timestamp-1], 10, 64); err == nil {
+			layout = data.UnixSeconds
+		}
+	}
+
+	bars, err := data.ReadCSV(bytes.NewReader(raw), data.CSVFormat{
+		Delimiter:  ',',
+		HasHeader:  true,
+		Columns:    columns,
+		TimeLayout: layout,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chart export: %w", err)
+	}
+
+	export := &ChartExport{
+		Bars:       bars,
+		Indicators: make(map[string][]float64),
+	}
+
+	for i, name := range header {
+		if isBarColumn(i+1, columns) {
+			continue
+		}
+
+		name = uniqueName(strings.TrimSpace(name), export.Indicators)
+		values := make([]float64, len(bars))
+		for row, record := range records[1:] {
+			values[row] = math.NaN()
+			if i >= len(record) {
+				continue
+			}
+			if v, err := strconv.ParseFloat(strings.TrimSpace(record[i]), 64); err == nil {
+				values[row] = v
+			}
+		}
+
+		export.Columns = append(export.Columns, name)
+		export.Indicators[name] = values
+	}
+
+	return export, nil
+}
+
+func ImportChartFile(path string) (*ChartExport, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ImportChartCSV(f)
+}
+
+// CompareIndicator checks calculated values against an indicator column, returning every bar
+// where both have a value and they differ by more than tolerance
+func (c *ChartExport) CompareIndicator(name string, values []float64, tolerance float64) ([]Mismatch, error) {
+	chart, ok := c.Indicators[name]
+	if !ok {
+		return nil, fmt.Errorf("chart export has no indicator %q", name)
+	}
+	if len(values) != len(chart) {
+		return nil, fmt.Errorf("expected %d values for %q, got %d", len(chart), name, len(values))
+	}
+
+	var mismatches []Mismatch
+	for i := range chart {
+		if math.IsNaN(chart[i]) || math.IsNaN(values[i]) {
+			continue
+		}
+		if math.Abs(chart[i]-values[i]) > tolerance {
+			mismatches = append(mismatches, Mismatch{
+				Index:     i,
+				Timestamp: c.Bars[i].Timestamp,
+				Chart:     chart[i],
+				Value:     values[i],
+			})
+		}
+	}
+	return mismatches, nil
+}
+
+func isBarColumn(column int, columns data.CSVColumns) bool {
+	switch column {
+	case columns.Timestamp, columns.Date, columns.Time, columns.Open, columns.High, columns.Low, columns.Close, columns.Volume:
+		return true
+	}
+	return false
+}
+
+func uniqueName(name string, existing map[string][]float64) string {
+	if _, ok := existing[name]; !ok {
+		return name
+	}
+	for n := 2; ; n++ {
+		candidate := fmt.Sprintf("%s (%d)", name, n)
+		if _, ok := existing[candidate]; !ok {
+			return candidate
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package tradingview
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImportChartCSV(t *testing.T) {
+	input := `time,open,high,low,close,EMA,Plot,Plot,Volume
+1735828200,21000.5,21010,20990.2,21005,20995.1,1,,321
+1735829100,21005,21020,21000,21015,20998.3,0,NaN,100
+`
+
+	export, err := ImportChartCSV(strings.NewReader(input))
+	assert.NoError(t, err)
+
+	assert.Equal(t, 2, len(export.Bars))
+	assert.Equal(t, time.Date(2025, 1, 2, 14, 30, 0, 0, time.UTC), export.Bars[0].Timestamp)
+	assert.Equal(t, float64(21005), export.Bars[0].Close)
+	assert.Equal(t, float64(321), export.Bars[0].Volume)
+
+	assert.Equal(t, []string{"EMA", "Plot", "Plot (2)"}, export.Columns)
+	assert.Equal(t, []float64{20995.1, 20998.3}, export.Indicators["EMA"])
+	assert.True(t, math.IsNaN(export.Indicators["Plot (2)"][0]))
+	assert.True(t, math.IsNaN(export.Indicators["Plot (2)"][1]))
+
+	mismatches, err := export.CompareIndicator("EMA", []float64{20995.1, 20999}, 0.01)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(mismatches))
+	assert.Equal(t, 1, mismatches[0].Index)
+	assert.Equal(t, float64(20998.3), mismatches[0].Chart)
+}
+
+func TestImportChartCSV_ISOTimes(t *testing.T) {
+	input := `time,open,high,low,close
+2025-01-02T09:30:00-05:00,1,2,0.5,1.5
+`
+
+	export, err := ImportChartCSV(strings.NewReader(input))
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 1, 2, 14, 30, 0, 0, time.UTC), export.Bars[0].Timestamp)
+	assert.Equal(t, 0, len(export.Columns))
+}
@@ -0,0 +1,86 @@
+package types
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	S5  Granularity = "S5"
+	S10 Granularity = "S10"
+	S15 Granularity = "S15"
+	S30 Granularity = "S30"
+	M1  Granularity = "M1"
+	M2  Granularity = "M2"
+	M4  Granularity = "M4"
+	M5  Granularity = "M5"
+	M10 Granularity = "M10"
+	M15 Granularity = "M15"
+	M30 Granularity = "M30"
+	H1  Granularity = "H1"
+	H2  Granularity = "H2"
+	H3  Granularity = "H3"
+	H4  Granularity = "H4"
+	H6  Granularity = "H6"
+	H8  Granularity = "H8"
+	H12 Granularity = "H12"
+	D   Granularity = "D"
+	W   Granularity = "W"
+	M   Granularity = "M"
+
+	MID         PriceComponent = "M"
+	BID         PriceComponent = "B"
+	ASK         PriceComponent = "A"
+	BID_ASK     PriceComponent = "BA"
+	MID_BID_ASK PriceComponent = "MBA"
+)
+
+// Granularity is the period of a bar, using Oanda's naming
+type Granularity string
+
+// PriceComponent selects which prices a bar carries, any combination of M, B and A
+type PriceComponent string
+
+var granularityToDuration = map[Granularity]time.Duration{
+	S5:  5 * time.Second,
+	S10: 10 * time.Second,
+	S15: 15 * time.Second,
+	S30: 30 * time.Second,
+	M1:  1 * time.Minute,
+	M2:  2 * time.Minute,
+	M4:  4 * time.Minute,
+	M5:  5 * time.Minute,
+	M10: 10 * time.Minute,
+	M15: 15 * time.Minute,
+	M30: 30 * time.Minute,
+	H1:  1 * time.Hour,
+	H2:  2 * time.Hour,
+	H3:  3 * time.Hour,
+	H4:  4 * time.Hour,
+	H6:  6 * time.Hour,
+	H8:  8 * time.Hour,
+	H12: 12 * time.Hour,
+	D:   24 * time.Hour,
+	W:   7 * 24 * time.Hour,
+	M:   30 * 24 * time.Hour, // Approx
+}
+
+func (g Granularity) ToDuration() (time.Duration, error) {
+	duration, ok := granularityToDuration[g]
+	if !ok {
+		return 0, fmt.Errorf("invalid granularity: %s", g)
+	}
+	return duration, nil
+}
+
+func (g Granularity) MustToDuration() time.Duration {
+	duration, err := g.ToDuration()
+	if err != nil {
+		panic(err)
+	}
+	return duration
+}
+
+func (g Granularity) String() string {
+	return string(g)
+}
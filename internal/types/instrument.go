@@ -0,0 +1,48 @@
+package types
+
+import (
+	"math"
+	"time"
+)
+
+// Instrument describes a tradeable instrument, independent of where its data comes from
+type Instrument struct {
+	Name                        string
+	DisplayName                 string
+	Type                        string       // CURRENCY, CFD, METAL
+	PipLocation                 int          // Pip size is 10^PipLocation, eg -4 for GBP_USD
+	DisplayPrecision            int          // Decimal places prices are quoted to
+	MarginRate                  float64      // Fraction of notional held as margin, 0.05 is 20:1 leverage
+	QuoteCurrency               string       // Currency prices, and so PnL, are in
+	TradeUnitsPrecision         int          // Decimal places units can be traded in, 0 for whole units
+	MinimumTradeSize            float64      // Smallest number of units that can be traded, zero for no minimum
+	MaximumOrderUnits           float64      // Largest number of units in one order, zero for no maximum
+	MinimumStopDistance         float64      // Closest a stop loss can be to the entry price, zero for no minimum
+	MinimumTrailingStopDistance float64      // Closest a trailing stop can trail the price, zero for no minimum
+	FinancingLong               float64      // Annual financing rate on long positions, negative rates are charges
+	FinancingShort              float64      // Annual financing rate on short positions
+	FinancingTripleDay          time.Weekday // Weekday charged three days of financing for the weekend, Sunday (zero) when unknown
+}
+
+// PipSize returns the price movement of a single pip
+func (i Instrument) PipSize() float64 {
+	return math.Pow10(i.PipLocation)
+}
+
+// TickSize returns the smallest price increment
+func (i Instrument) TickSize() float64 {
+	return math.Pow10(-i.DisplayPrecision)
+}
+
+// RoundPrice rounds the price to the nearest tick
+func (i Instrument) RoundPrice(price float64) float64 {
+	scale := math.Pow10(i.DisplayPrecision)
+	return math.Round(price*scale) / scale
+}
+
+// RoundUnits rounds units down to the precision they can be traded in, so a position is never larger than asked for
+func (i Instrument) RoundUnits(units float64) float64 {
+	scale := math.Pow10(i.TradeUnitsPrecision)
+	// Nudge up before truncating so 0.3 doesn't become 0.2 through float error
+	return math.Trunc(units*scale+1e-9) / scale
+}
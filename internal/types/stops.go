@@ -0,0 +1,27 @@
+package types
+
+const (
+	TRAIL_FIXED   TrailMode = "FIXED"
+	TRAIL_ATR     TrailMode = "ATR"
+	TRAIL_PERCENT TrailMode = "PERCENT"
+)
+
+type TrailMode string
+
+// TrailingStop follows price at a distance from the best price reached since entry, only ever tightening the stop
+type TrailingStop struct {
+	Mode TrailMode
+	// Distance is a price distance for FIXED, a multiple of ATR for ATR and a percentage of price for PERCENT
+	Distance float64
+	// ATR is the average true range when the signal was created, only used by ATR
+	ATR float64
+	// ATRPeriod keeps the ATR up to date bar by bar with the same smoothing as strategy.ATR, zero leaves it fixed
+	ATRPeriod int
+}
+
+// BreakEven moves the stop to entry, plus an offset, once the position is far enough in profit
+type BreakEven struct {
+	Trigger  float64 // Profit in price that triggers the move
+	TriggerR float64 // Profit in multiples of the initial risk that triggers the move, used when Trigger is zero. Needs a stop loss
+	Offset   float64 // Profit in price to lock in beyond entry
+}
@@ -8,7 +8,14 @@ const (
 	BUY  Action = "BUY"
 	SELL Action = "SELL"
 
-	OPEN Type = "OPEN_TRADE"
+	OPEN   Type = "OPEN_TRADE"
+	CLOSE  Type = "CLOSE_TRADE"
+	MODIFY Type = "MODIFY_TRADE"
+
+	MARKET     OrderType = "MARKET"
+	LIMIT      OrderType = "LIMIT"
+	STOP       OrderType = "STOP"
+	STOP_LIMIT OrderType = "STOP_LIMIT"
 )
 
 type Bar struct {
@@ -18,16 +25,79 @@ type Bar struct {
 	Low       float64
 	Close     float64
 	Volume    float64
+	Spread    float64 // Spread at the close of the bar, zero when unknown
+	Bid       OHLC    // Zero when bid prices weren't loaded
+	Ask       OHLC    // Zero when ask prices weren't loaded
+	Partial   bool    // The bar's period hadn't finished, or wasn't fully covered by the data it was built from
+}
+
+// OHLC holds the prices for one side of a bar
+type OHLC struct {
+	Open  float64
+	High  float64
+	Low   float64
+	Close float64
+}
+
+// HasQuotes returns true if the bar carries both bid and ask prices
+func (b Bar) HasQuotes() bool {
+	return b.Bid != (OHLC{}) && b.Ask != (OHLC{})
+}
+
+// Mid returns the bar's mid prices
+func (b Bar) Mid() OHLC {
+	return OHLC{Open: b.Open, High: b.High, Low: b.Low, Close: b.Close}
+}
+
+// Side returns the prices the given action trades at, buys on the ask and sells on the bid.
+// Bars without quotes fall back to mid prices.
+func (b Bar) Side(action Action) OHLC {
+	if !b.HasQuotes() {
+		return b.Mid()
+	}
+	if action == SELL {
+		return b.Bid
+	}
+	return b.Ask
 }
 
 type Action string
 type Type string
+type OrderType string
 
 type Signal struct {
-	Type   Type   // OPEN_TRADE
-	Action Action // "BUY", "SELL"
-	Price  float64
-	TP     float64
-	SL     float64
-	Size   float64 // Lot size
+	Instrument string    // Empty trades the instrument the strategy is running on
+	Type       Type      // OPEN_TRADE, CLOSE_TRADE, MODIFY_TRADE
+	Action     Action    // "BUY", "SELL". For CLOSE and MODIFY without a PositionID, selects positions opened with this action, empty selects all
+	OrderType  OrderType // MARKET (default), LIMIT, STOP, STOP_LIMIT
+	Price      float64   // Entry price for market orders, trigger price for pending orders, reference exit price for CLOSE
+	LimitPrice float64   // Limit price once a STOP_LIMIT order has triggered
+	Expiry     time.Time // Pending orders are cancelled once reached, zero is good till cancelled
+	TP         float64   // For MODIFY, zero leaves the take profit unchanged
+	SL         float64   // For MODIFY, zero leaves the stop loss unchanged
+	Size       float64   // Lot size
+	PositionID int       // Position a CLOSE or MODIFY applies to, zero applies to every position matching Action
+	Fraction   float64   // Portion of each position a CLOSE exits, zero closes it all
+
+	Trailing  *TrailingStop // Optional stop management for OPEN signals
+	BreakEven *BreakEven
+}
+
+// IsPending returns true if the signal should rest as a working order rather than fill at market
+func (s Signal) IsPending() bool {
+	return s.OrderType == LIMIT || s.OrderType == STOP || s.OrderType == STOP_LIMIT
+}
+
+// Tick is a single price update for an instrument
+type Tick struct {
+	Instrument string
+	Time       time.Time
+	Bid        float64
+	Ask        float64
+	Tradeable  bool // False while the market is closed or halted
+}
+
+// Mid returns the midpoint of the bid and ask
+func (t Tick) Mid() float64 {
+	return (t.Bid + t.Ask) / 2
 }